@@ -0,0 +1,167 @@
+package main
+
+import (
+	pb "DID/proto"
+	"DID/utils"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// maxMessageSize 与服务器一致，仪式状态远超 gRPC 默认的 4MB
+const maxMessageSize = 256 << 20
+
+// ceremony 由每个机构运行：连接匹配服务器上的可信设置仪式，轮到自己时在当前状态上贡献随机性并签名提交，
+// 直到两个阶段都结束。指定 -audit 时只重放服务器写入的仪式记录，检查得到的验证密钥与产物目录一致。
+func main() {
+	name := flag.String("name", "", "机构名称，证书与 BLS 密钥对位于 certs/<name>/")
+	server := flag.String("server", "localhost:5000", "匹配服务器地址")
+	audit := flag.String("audit", "", "审计仪式记录与产物所在的目录，不参与仪式")
+	authoritiesPath := flag.String("authorities", "authorities.json", "受信任机构的公钥列表（SaveAuthorities 格式），审计时必须与仪式记录中的机构一致")
+	flag.Parse()
+
+	if *audit != "" {
+		auditCeremony(*audit, *authoritiesPath)
+		return
+	}
+	if *name == "" {
+		log.Fatalf("[Ceremony] 需要通过 -name 指定机构名称")
+	}
+
+	certFile := fmt.Sprintf("certs/%s/%s.pem", *name, *name)
+	keyFile := fmt.Sprintf("certs/%s/%s.key", *name, *name)
+	caFile := "certs/ca/ca.pem"
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法加载证书或私钥: %v", err)
+	}
+	caCertData, err := os.ReadFile(caFile)
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法加载 CA 根证书: %v", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCertData) {
+		log.Fatalf("[Ceremony] 将 CA 根证书添加到 CertPool 失败")
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+		ServerName:   "localhost",
+	})
+
+	signer, err := utils.LoadKeypair(fmt.Sprintf("certs/%s/%s_keypair.json", *name, *name))
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法加载 BLS 密钥对: %v", err)
+	}
+	pkBytes := signer.PublicKey.Bytes()
+
+	conn, err := grpc.Dial(*server, grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)))
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法连接到匹配服务器 %s: %v", *server, err)
+	}
+	defer conn.Close()
+	client := pb.NewCeremonyServiceClient(conn)
+
+	for {
+		// 领取一次性挑战并签名，然后等待轮到本机构，可能需要等待其他机构完成贡献
+		challenge, err := client.Challenge(context.Background(), &pb.ChallengeRequest{Participant: pkBytes[:]})
+		if err != nil {
+			log.Fatalf("[Ceremony] Challenge 出错: %v", err)
+		}
+		sig, err := utils.Sign(signer.PrivateKey, utils.CeremonyRequestMessage(pkBytes[:], challenge.Challenge))
+		if err != nil {
+			log.Fatalf("[Ceremony] 无法签名贡献权请求: %v", err)
+		}
+		sigBytes := sig.Bytes()
+		state, err := client.NextContribution(context.Background(), &pb.ContributionRequest{Participant: pkBytes[:], Challenge: challenge.Challenge, Signature: sigBytes[:]})
+		if err != nil {
+			log.Fatalf("[Ceremony] NextContribution 出错: %v", err)
+		}
+		if state.Phase == 0 {
+			break
+		}
+		curve, err := ecc.IDFromString(state.Curve)
+		if err != nil {
+			log.Fatalf("[Ceremony] 服务器返回未知曲线 %q", state.Curve)
+		}
+		log.Printf("[Ceremony] 在阶段 %d 第 %d 次贡献上加入随机性（%s）...", state.Phase, state.Index, state.Curve)
+		start := time.Now()
+		contribution, err := utils.ContributeCeremony(curve, int(state.Phase), state.State, signer.PrivateKey, signer.PublicKey)
+		if err != nil {
+			log.Fatalf("[Ceremony] 贡献失败: %v", err)
+		}
+		receipt, err := client.SubmitContribution(context.Background(), &pb.Contribution{
+			Phase:       state.Phase,
+			Participant: contribution.Participant,
+			Signature:   contribution.Signature,
+			Data:        contribution.Data,
+		})
+		if err != nil {
+			log.Fatalf("[Ceremony] SubmitContribution 出错: %v", err)
+		}
+		hash := sha256.Sum256(contribution.Data)
+		if receipt.Index != state.Index || string(receipt.Hash) != string(hash[:]) {
+			log.Fatalf("[Ceremony] 服务器回执与贡献不一致")
+		}
+		log.Printf("[Ceremony] 阶段 %d 第 %d 次贡献已接受（用时 %v），sha256=%x", state.Phase, receipt.Index, time.Since(start), receipt.Hash)
+	}
+
+	resp, err := client.GetTranscript(context.Background(), &pb.TranscriptRequest{})
+	if err != nil {
+		log.Fatalf("[Ceremony] GetTranscript 出错: %v", err)
+	}
+	var transcript utils.CeremonyTranscript
+	if err := json.Unmarshal(resp.Transcript, &transcript); err != nil {
+		log.Fatalf("[Ceremony] 无法解析仪式记录: %v", err)
+	}
+	fmt.Printf("仪式结束：%d + %d 次贡献，vk sha256=%s\n", len(transcript.Phase1), len(transcript.Phase2), transcript.VKHash)
+}
+
+// auditCeremony 重放目录 dir 中的仪式记录，检查记录的机构与文件 authoritiesPath 中信任的机构一致、
+// 每次贡献及其签名，并比较得到的验证密钥与产物
+func auditCeremony(dir, authoritiesPath string) {
+	authorities, err := utils.LoadAuthorities(authoritiesPath)
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法加载机构公钥: %v", err)
+	}
+	transcript, err := utils.LoadCeremonyTranscript(dir)
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法加载仪式记录: %v", err)
+	}
+	m, vk, err := utils.LoadVerifyingKey(dir)
+	if err != nil {
+		log.Fatalf("[Ceremony] 无法加载验证密钥: %v", err)
+	}
+	log.Printf("[Ceremony] 重放电路 %s 的仪式记录（%d + %d 次贡献）...", transcript.Manifest.Key(), len(transcript.Phase1), len(transcript.Phase2))
+	audited, err := transcript.Verify(authorities)
+	if err != nil {
+		log.Fatalf("[Ceremony] 仪式记录验证失败: %v", err)
+	}
+	digest, err := audited.VerifyingKey().Digest()
+	if err != nil {
+		log.Fatalf("[Ceremony] %v", err)
+	}
+	expected, err := vk.Digest()
+	if err != nil {
+		log.Fatalf("[Ceremony] %v", err)
+	}
+	if digest != expected || digest != m.Digests["vk"] {
+		log.Fatalf("[Ceremony] 仪式记录得到的验证密钥 %s 与产物目录中的 %s 不一致", digest, expected)
+	}
+	for _, c := range transcript.Phase2 {
+		fmt.Printf("贡献方: %x\n", c.Participant)
+	}
+	fmt.Printf("Ceremony transcript verified for circuit %s, vk sha256=%s\n", m.Key(), digest)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"DID/utils"
 	"crypto/rand"
 	"testing"
 
@@ -59,13 +60,15 @@ func TestBLSAggregateSignature(t *testing.T) {
 	}
 
 	// Step 2: 每个签名者签名
-	signatures := SignMessage(signers, message)
-	if len(signatures) != numSigners {
-		t.Fatalf("expected %d signatures, got %d", numSigners, len(signatures))
+	pubKeys := make([]bls12381.G1Affine, 0, numSigners)
+	signatures := make([]bls12381.G2Affine, 0, numSigners)
+	for _, signer := range signers {
+		pubKeys = append(pubKeys, signer.PublicKey)
+		signatures = append(signatures, SignMessage(signer, message))
 	}
 
 	// Step 3: 聚合公钥和签名
-	aggPubKey, aggSignature := Aggregate(signers, signatures)
+	aggPubKey, aggSignature := utils.Aggregate(pubKeys, signatures)
 
 	// Step 4: 使用 PairingCheck 验证聚合签名
 	isValid := VerifyAggregateSignatureWithPairingCheck(aggPubKey, aggSignature, message)
@@ -74,6 +77,10 @@ func TestBLSAggregateSignature(t *testing.T) {
 	} else {
 		t.Log("✅ Aggregate signature verified successfully with PairingCheck!")
 	}
+	// 与机构端使用的验证一致
+	if !utils.VerifyAggregateSignature(aggPubKey, aggSignature, message) {
+		t.Error("aggregate signature rejected by utils.VerifyAggregateSignature")
+	}
 
 	// 可选：测试错误消息是否被拒绝
 	wrongMessage := []byte("different message")
@@ -7,7 +7,6 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -16,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -77,14 +77,54 @@ func (s *chatServiceServer) Chat(stream pb.ChatService_ChatServer) error {
 // 	}
 // }
 
+// coSignTreeHead 对签发日志的当前树头签名并提交
+func coSignTreeHead(ctx context.Context, logClient pb.TransparencyLogServiceClient, signer Signer) error {
+	resp, err := logClient.GetTreeHead(ctx, &pb.TreeHeadRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Head == nil {
+		return fmt.Errorf("transparency log is empty")
+	}
+	th := utils.TreeHead{Size: resp.Head.Size, Timestamp: resp.Head.Timestamp, Root: resp.Head.Root}
+	signature := SignMessage(signer, th.Message())
+	sig, pk := signature.Bytes(), signer.PublicKey.Bytes()
+	_, err = logClient.SignTreeHead(ctx, &pb.TreeHeadSignature{Head: resp.Head, PublicKey: pk[:], Signature: sig[:]})
+	if err != nil {
+		return err
+	}
+	log.Printf("[Client] 已对大小为 %d 的签发日志树头签名", th.Size)
+	return nil
+}
+
 func main() {
 
 	if len(os.Args) < 3 {
-		log.Fatalf("go run client.go <certs path> <Attribute>")
+		log.Fatalf("go run client.go <certs path> <Attribute> [setup dir]")
 	}
 
 	clientName := os.Args[1]
 	secret := os.Args[2]
+	// 签发会话的凭证类型取自可信设置清单，与服务端的 -curve、-hash、-mode、-holder 一致
+	setupDir := "setup-artifacts"
+	if len(os.Args) > 3 {
+		setupDir = os.Args[3]
+	}
+	manifest, err := utils.LoadSetupManifest(setupDir)
+	if err != nil {
+		log.Fatalf("[Client] 无法加载可信设置清单: %v", err)
+	}
+	ct, err := manifest.CredentialType()
+	if err != nil {
+		log.Fatalf("[Client] %v", err)
+	}
+	profile := ct.Profile()
+	if profile.CurveID() != ecc.BN254 {
+		log.Fatalf("[Client] 签发会话的份额在 BN254 上计算，不支持曲线 %s", profile.CurveID())
+	}
+	if ct.Holder && ct.Attributes != 1 {
+		log.Fatalf("[Client] 凭证类型 %q 绑定 %d 个属性，客户端只提交一个属性", ct.Name, ct.Attributes)
+	}
 	certFile := fmt.Sprintf("certs/%s/%s.pem", clientName, clientName)
 	keyFile := fmt.Sprintf("certs/%s/%s.key", clientName, clientName)
 	caFile := "certs/ca/ca.pem"
@@ -160,26 +200,31 @@ func main() {
 	if err != nil {
 		log.Fatalf("[Client] 打开 Chat 双向流失败: %v", err)
 	}
-	//计算hash在本地拆分
-	Xhash := utils.Mimc(secret)
-	fmt.Printf("%s: %s 的 MiMC 哈希 = %s\n", clientName, secret, Xhash)
+	//加载BLS公私钥，持有者秘密由私钥派生，已有的密钥对不能覆盖，文件不存在时生成
+	keypair, err := utils.LoadKeypair(fmt.Sprintf("certs/%s/%s_keypair.json", clientName, clientName))
+	if err != nil {
+		log.Fatalf("[Client] 无法加载 BLS 密钥对: %v", err)
+	}
+	sk, pk := keypair.PrivateKey, keypair.PublicKey
+	// 服务器只接受运营方写入其 -authorities 列表的机构公钥
+	pkBytes := pk.Bytes()
+	log.Printf("[Client] BLS 公钥: %x", pkBytes[:])
+	//叶子数据为属性的哈希；绑定持有者时为 H(holder_secret, 属性的哈希)，持有者秘密由私钥派生，计算后在本地拆分
+	leaf := profile.Sum(secret)
+	if ct.Holder {
+		skBytes := sk.Bytes()
+		holderSecret := utils.HolderSecret(profile, skBytes[:])
+		if leaf, err = utils.HolderLeaf(profile, holderSecret, [][]byte{leaf}); err != nil {
+			log.Fatalf("[Client] 计算叶子失败: %v", err)
+		}
+	}
+	// 服务端的缓存树直接使用叶子哈希，与 ct.NewTree 对叶子数据计算的一致
+	Xhash := new(fr.Element).SetBytes(ct.Mode.LeafSum(profile.NewHash(), leaf))
+	fmt.Printf("%s: %s 的叶子哈希 = %s\n", clientName, secret, Xhash)
 	x1Bytes, x2Bytes := Disassemble(Xhash)
-	//计算BLS公私钥
-	sk, pk := GenerateKeyPair()
 	signer := Signer{
 		PrivateKey: sk,
 		PublicKey:  pk}
-	jsonData, err := json.MarshalIndent(signer, "", "  ")
-	if err != nil {
-		fmt.Printf("JSON 序列化失败: %v\n", err)
-		return
-	}
-	filename := fmt.Sprintf("certs/%s/%s_keypair.json", clientName, clientName)
-	err = os.WriteFile(filename, jsonData, 0644)
-	if err != nil {
-		fmt.Printf("写入文件失败: %v\n", err)
-		return
-	}
 
 	var x2y []byte
 	var wg sync.WaitGroup
@@ -237,6 +282,10 @@ func main() {
 			var merkleRootElm fr.Element
 			merkleRootElm.SetBytes(aggrResult.MerkleRoot)
 			log.Println("[Client] Merkle Root:", &merkleRootElm)
+			//对签发日志的新树头签名，全部机构签名后服务器聚合为联合签名的树头
+			if err := coSignTreeHead(resCtx, pb.NewTransparencyLogServiceClient(connMatch), signer); err != nil {
+				log.Printf("[Client] 树头签名出错: %v", err)
+			}
 			return
 		}
 	}()
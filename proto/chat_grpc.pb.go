@@ -188,6 +188,216 @@ var MatchService_ServiceDesc = grpc.ServiceDesc{
 	Metadata: "proto/chat.proto",
 }
 
+const (
+	TransparencyLogService_GetTreeHead_FullMethodName         = "/proto.TransparencyLogService/GetTreeHead"
+	TransparencyLogService_SignTreeHead_FullMethodName        = "/proto.TransparencyLogService/SignTreeHead"
+	TransparencyLogService_GetEntry_FullMethodName            = "/proto.TransparencyLogService/GetEntry"
+	TransparencyLogService_GetConsistencyProof_FullMethodName = "/proto.TransparencyLogService/GetConsistencyProof"
+)
+
+// TransparencyLogServiceClient is the client API for TransparencyLogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TransparencyLogServiceClient interface {
+	// 返回日志的当前树头，以及最近一次由机构联合签名的树头
+	GetTreeHead(ctx context.Context, in *TreeHeadRequest, opts ...grpc.CallOption) (*TreeHeadResponse, error)
+	// 机构对当前树头签名后提交，收齐签名后服务器将其聚合为联合签名的树头
+	SignTreeHead(ctx context.Context, in *TreeHeadSignature, opts ...grpc.CallOption) (*TreeHeadResponse, error)
+	// 返回一条签发记录及其在给定大小的树中的包含证明
+	GetEntry(ctx context.Context, in *EntryRequest, opts ...grpc.CallOption) (*EntryResponse, error)
+	// 返回两个树大小之间的一致性证明
+	GetConsistencyProof(ctx context.Context, in *ConsistencyRequest, opts ...grpc.CallOption) (*ConsistencyResponse, error)
+}
+
+type transparencyLogServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransparencyLogServiceClient(cc grpc.ClientConnInterface) TransparencyLogServiceClient {
+	return &transparencyLogServiceClient{cc}
+}
+
+func (c *transparencyLogServiceClient) GetTreeHead(ctx context.Context, in *TreeHeadRequest, opts ...grpc.CallOption) (*TreeHeadResponse, error) {
+	out := new(TreeHeadResponse)
+	err := c.cc.Invoke(ctx, TransparencyLogService_GetTreeHead_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transparencyLogServiceClient) SignTreeHead(ctx context.Context, in *TreeHeadSignature, opts ...grpc.CallOption) (*TreeHeadResponse, error) {
+	out := new(TreeHeadResponse)
+	err := c.cc.Invoke(ctx, TransparencyLogService_SignTreeHead_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transparencyLogServiceClient) GetEntry(ctx context.Context, in *EntryRequest, opts ...grpc.CallOption) (*EntryResponse, error) {
+	out := new(EntryResponse)
+	err := c.cc.Invoke(ctx, TransparencyLogService_GetEntry_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transparencyLogServiceClient) GetConsistencyProof(ctx context.Context, in *ConsistencyRequest, opts ...grpc.CallOption) (*ConsistencyResponse, error) {
+	out := new(ConsistencyResponse)
+	err := c.cc.Invoke(ctx, TransparencyLogService_GetConsistencyProof_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TransparencyLogServiceServer is the server API for TransparencyLogService service.
+// All implementations must embed UnimplementedTransparencyLogServiceServer
+// for forward compatibility
+type TransparencyLogServiceServer interface {
+	// 返回日志的当前树头，以及最近一次由机构联合签名的树头
+	GetTreeHead(context.Context, *TreeHeadRequest) (*TreeHeadResponse, error)
+	// 机构对当前树头签名后提交，收齐签名后服务器将其聚合为联合签名的树头
+	SignTreeHead(context.Context, *TreeHeadSignature) (*TreeHeadResponse, error)
+	// 返回一条签发记录及其在给定大小的树中的包含证明
+	GetEntry(context.Context, *EntryRequest) (*EntryResponse, error)
+	// 返回两个树大小之间的一致性证明
+	GetConsistencyProof(context.Context, *ConsistencyRequest) (*ConsistencyResponse, error)
+	mustEmbedUnimplementedTransparencyLogServiceServer()
+}
+
+// UnimplementedTransparencyLogServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTransparencyLogServiceServer struct {
+}
+
+func (UnimplementedTransparencyLogServiceServer) GetTreeHead(context.Context, *TreeHeadRequest) (*TreeHeadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTreeHead not implemented")
+}
+func (UnimplementedTransparencyLogServiceServer) SignTreeHead(context.Context, *TreeHeadSignature) (*TreeHeadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTreeHead not implemented")
+}
+func (UnimplementedTransparencyLogServiceServer) GetEntry(context.Context, *EntryRequest) (*EntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEntry not implemented")
+}
+func (UnimplementedTransparencyLogServiceServer) GetConsistencyProof(context.Context, *ConsistencyRequest) (*ConsistencyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConsistencyProof not implemented")
+}
+func (UnimplementedTransparencyLogServiceServer) mustEmbedUnimplementedTransparencyLogServiceServer() {
+}
+
+// UnsafeTransparencyLogServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransparencyLogServiceServer will
+// result in compilation errors.
+type UnsafeTransparencyLogServiceServer interface {
+	mustEmbedUnimplementedTransparencyLogServiceServer()
+}
+
+func RegisterTransparencyLogServiceServer(s grpc.ServiceRegistrar, srv TransparencyLogServiceServer) {
+	s.RegisterService(&TransparencyLogService_ServiceDesc, srv)
+}
+
+func _TransparencyLogService_GetTreeHead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TreeHeadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransparencyLogServiceServer).GetTreeHead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransparencyLogService_GetTreeHead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransparencyLogServiceServer).GetTreeHead(ctx, req.(*TreeHeadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransparencyLogService_SignTreeHead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TreeHeadSignature)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransparencyLogServiceServer).SignTreeHead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransparencyLogService_SignTreeHead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransparencyLogServiceServer).SignTreeHead(ctx, req.(*TreeHeadSignature))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransparencyLogService_GetEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransparencyLogServiceServer).GetEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransparencyLogService_GetEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransparencyLogServiceServer).GetEntry(ctx, req.(*EntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransparencyLogService_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConsistencyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransparencyLogServiceServer).GetConsistencyProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TransparencyLogService_GetConsistencyProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransparencyLogServiceServer).GetConsistencyProof(ctx, req.(*ConsistencyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TransparencyLogService_ServiceDesc is the grpc.ServiceDesc for TransparencyLogService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TransparencyLogService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.TransparencyLogService",
+	HandlerType: (*TransparencyLogServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTreeHead",
+			Handler:    _TransparencyLogService_GetTreeHead_Handler,
+		},
+		{
+			MethodName: "SignTreeHead",
+			Handler:    _TransparencyLogService_SignTreeHead_Handler,
+		},
+		{
+			MethodName: "GetEntry",
+			Handler:    _TransparencyLogService_GetEntry_Handler,
+		},
+		{
+			MethodName: "GetConsistencyProof",
+			Handler:    _TransparencyLogService_GetConsistencyProof_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
+
 const (
 	ChatService_Chat_FullMethodName = "/proto.ChatService/Chat"
 )
@@ -311,3 +521,212 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "proto/chat.proto",
 }
+
+const (
+	CeremonyService_Challenge_FullMethodName          = "/proto.CeremonyService/Challenge"
+	CeremonyService_NextContribution_FullMethodName   = "/proto.CeremonyService/NextContribution"
+	CeremonyService_SubmitContribution_FullMethodName = "/proto.CeremonyService/SubmitContribution"
+	CeremonyService_GetTranscript_FullMethodName      = "/proto.CeremonyService/GetTranscript"
+)
+
+// CeremonyServiceClient is the client API for CeremonyService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CeremonyServiceClient interface {
+	// 机构领取一次性的随机挑战，签名后用于 NextContribution
+	Challenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeResponse, error)
+	// 机构调用本方法并阻塞直到轮到自己，返回需要在其上贡献随机性的当前状态
+	NextContribution(ctx context.Context, in *ContributionRequest, opts ...grpc.CallOption) (*ContributionState, error)
+	// 机构提交贡献，服务器验证贡献与签名后追加到仪式记录中
+	SubmitContribution(ctx context.Context, in *Contribution, opts ...grpc.CallOption) (*ContributionReceipt, error)
+	// 返回仪式记录，任何人都可以据此重新验证全部贡献并复现最终密钥
+	GetTranscript(ctx context.Context, in *TranscriptRequest, opts ...grpc.CallOption) (*TranscriptResponse, error)
+}
+
+type ceremonyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCeremonyServiceClient(cc grpc.ClientConnInterface) CeremonyServiceClient {
+	return &ceremonyServiceClient{cc}
+}
+
+func (c *ceremonyServiceClient) Challenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeResponse, error) {
+	out := new(ChallengeResponse)
+	err := c.cc.Invoke(ctx, CeremonyService_Challenge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceremonyServiceClient) NextContribution(ctx context.Context, in *ContributionRequest, opts ...grpc.CallOption) (*ContributionState, error) {
+	out := new(ContributionState)
+	err := c.cc.Invoke(ctx, CeremonyService_NextContribution_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceremonyServiceClient) SubmitContribution(ctx context.Context, in *Contribution, opts ...grpc.CallOption) (*ContributionReceipt, error) {
+	out := new(ContributionReceipt)
+	err := c.cc.Invoke(ctx, CeremonyService_SubmitContribution_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ceremonyServiceClient) GetTranscript(ctx context.Context, in *TranscriptRequest, opts ...grpc.CallOption) (*TranscriptResponse, error) {
+	out := new(TranscriptResponse)
+	err := c.cc.Invoke(ctx, CeremonyService_GetTranscript_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CeremonyServiceServer is the server API for CeremonyService service.
+// All implementations must embed UnimplementedCeremonyServiceServer
+// for forward compatibility
+type CeremonyServiceServer interface {
+	// 机构领取一次性的随机挑战，签名后用于 NextContribution
+	Challenge(context.Context, *ChallengeRequest) (*ChallengeResponse, error)
+	// 机构调用本方法并阻塞直到轮到自己，返回需要在其上贡献随机性的当前状态
+	NextContribution(context.Context, *ContributionRequest) (*ContributionState, error)
+	// 机构提交贡献，服务器验证贡献与签名后追加到仪式记录中
+	SubmitContribution(context.Context, *Contribution) (*ContributionReceipt, error)
+	// 返回仪式记录，任何人都可以据此重新验证全部贡献并复现最终密钥
+	GetTranscript(context.Context, *TranscriptRequest) (*TranscriptResponse, error)
+	mustEmbedUnimplementedCeremonyServiceServer()
+}
+
+// UnimplementedCeremonyServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCeremonyServiceServer struct {
+}
+
+func (UnimplementedCeremonyServiceServer) Challenge(context.Context, *ChallengeRequest) (*ChallengeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Challenge not implemented")
+}
+func (UnimplementedCeremonyServiceServer) NextContribution(context.Context, *ContributionRequest) (*ContributionState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NextContribution not implemented")
+}
+func (UnimplementedCeremonyServiceServer) SubmitContribution(context.Context, *Contribution) (*ContributionReceipt, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitContribution not implemented")
+}
+func (UnimplementedCeremonyServiceServer) GetTranscript(context.Context, *TranscriptRequest) (*TranscriptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTranscript not implemented")
+}
+func (UnimplementedCeremonyServiceServer) mustEmbedUnimplementedCeremonyServiceServer() {}
+
+// UnsafeCeremonyServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CeremonyServiceServer will
+// result in compilation errors.
+type UnsafeCeremonyServiceServer interface {
+	mustEmbedUnimplementedCeremonyServiceServer()
+}
+
+func RegisterCeremonyServiceServer(s grpc.ServiceRegistrar, srv CeremonyServiceServer) {
+	s.RegisterService(&CeremonyService_ServiceDesc, srv)
+}
+
+func _CeremonyService_Challenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeremonyServiceServer).Challenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CeremonyService_Challenge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeremonyServiceServer).Challenge(ctx, req.(*ChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeremonyService_NextContribution_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContributionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeremonyServiceServer).NextContribution(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CeremonyService_NextContribution_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeremonyServiceServer).NextContribution(ctx, req.(*ContributionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeremonyService_SubmitContribution_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Contribution)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeremonyServiceServer).SubmitContribution(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CeremonyService_SubmitContribution_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeremonyServiceServer).SubmitContribution(ctx, req.(*Contribution))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CeremonyService_GetTranscript_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranscriptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CeremonyServiceServer).GetTranscript(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CeremonyService_GetTranscript_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CeremonyServiceServer).GetTranscript(ctx, req.(*TranscriptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CeremonyService_ServiceDesc is the grpc.ServiceDesc for CeremonyService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CeremonyService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.CeremonyService",
+	HandlerType: (*CeremonyServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Challenge",
+			Handler:    _CeremonyService_Challenge_Handler,
+		},
+		{
+			MethodName: "NextContribution",
+			Handler:    _CeremonyService_NextContribution_Handler,
+		},
+		{
+			MethodName: "SubmitContribution",
+			Handler:    _CeremonyService_SubmitContribution_Handler,
+		},
+		{
+			MethodName: "GetTranscript",
+			Handler:    _CeremonyService_GetTranscript_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
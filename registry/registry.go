@@ -0,0 +1,38 @@
+package main
+
+import (
+	"DID/utils"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// registry 列出电路注册表中的全部电路版本，或弃用其中一个版本。
+// 注册表目录的每个子目录是 setup 命令生成的一组产物。
+func main() {
+	dir := flag.String("dir", "registry", "电路注册表目录")
+	deprecate := flag.String("deprecate", "", "要弃用的电路名，为空时只列出电路")
+	version := flag.Int("version", 1, "要弃用的电路版本")
+	reason := flag.String("reason", "", "弃用原因")
+	flag.Parse()
+
+	registry, err := utils.LoadRegistry(*dir)
+	if err != nil {
+		log.Fatalf("[Registry] 无法加载电路注册表: %v", err)
+	}
+	if *deprecate != "" {
+		key := utils.CircuitKey{ID: *deprecate, Version: *version}
+		if err := registry.Deprecate(key, *reason); err != nil {
+			log.Fatalf("[Registry] 弃用 %s 失败: %v", key, err)
+		}
+		log.Printf("[Registry] 已弃用 %s: %s", key, *reason)
+	}
+	for _, key := range registry.Keys() {
+		e, _ := registry.Lookup(key)
+		status := "active"
+		if e.Deprecated != "" {
+			status = "deprecated: " + e.Deprecated
+		}
+		fmt.Printf("%s\t%s\t%s\tvk=%s\t%s\n", key, e.VK.Backend, e.VK.Curve, e.VKHash, status)
+	}
+}
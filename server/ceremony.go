@@ -0,0 +1,233 @@
+package main
+
+import (
+	"DID/utils"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "DID/proto"
+)
+
+// ceremonyChallengeTTL 是服务器下发的挑战的有效期
+const ceremonyChallengeTTL = time.Minute
+
+// ceremonyChallenge 是发给某个机构、尚未使用的挑战
+type ceremonyChallenge struct {
+	value   []byte
+	expires time.Time
+}
+
+// ceremonyServer 实现 CeremonyServiceServer：机构依次领取当前状态、贡献随机性并提交，
+// 同一时间只有一个机构持有贡献权，超过 lease 未提交时其他机构可以接替。
+// 领取贡献权的请求必须由仪式的机构对服务器下发的一次性挑战签名，否则任何人都可以冒用机构的公钥
+// 或重放截获的请求占用贡献权
+type ceremonyServer struct {
+	pb.UnimplementedCeremonyServiceServer
+
+	ceremony *utils.Ceremony
+	out      string        // 仪式结束后写入密钥与仪式记录的目录
+	lease    time.Duration // 持有贡献权的最长时间
+
+	mu      sync.Mutex
+	holder  *bls12381.G1Affine // 当前持有贡献权的机构，nil 表示空闲
+	expires time.Time
+	changed chan struct{} // 状态变化时关闭并替换，唤醒等待的机构
+
+	challenges map[string]ceremonyChallenge // 按机构公钥记录尚未使用的挑战
+}
+
+func newCeremonyServer(c *utils.Ceremony, out string, lease time.Duration) *ceremonyServer {
+	return &ceremonyServer{
+		ceremony: c,
+		out:      out,
+		lease:    lease,
+		changed:  make(chan struct{}),
+
+		challenges: make(map[string]ceremonyChallenge),
+	}
+}
+
+func participantKey(b []byte) (bls12381.G1Affine, error) {
+	var pk bls12381.G1Affine
+	if _, err := pk.SetBytes(b); err != nil {
+		return pk, status.Errorf(codes.InvalidArgument, "invalid participant key: %v", err)
+	}
+	return pk, nil
+}
+
+// broadcast 释放贡献权并唤醒所有等待的机构，调用时需持有 s.mu
+func (s *ceremonyServer) broadcast() {
+	s.holder = nil
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Challenge 为仪式的机构生成随机挑战，机构签名后在 ceremonyChallengeTTL 内用于 NextContribution；
+// 新的挑战替换该机构之前未使用的挑战
+func (s *ceremonyServer) Challenge(ctx context.Context, req *pb.ChallengeRequest) (*pb.ChallengeResponse, error) {
+	pk, err := participantKey(req.Participant)
+	if err != nil {
+		return nil, err
+	}
+	if !s.ceremony.Authorized(pk) {
+		return nil, status.Error(codes.PermissionDenied, "participant is not an authority of the ceremony")
+	}
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate challenge: %v", err)
+	}
+	s.mu.Lock()
+	s.challenges[string(req.Participant)] = ceremonyChallenge{value: challenge, expires: time.Now().Add(ceremonyChallengeTTL)}
+	s.mu.Unlock()
+	return &pb.ChallengeResponse{Challenge: challenge}, nil
+}
+
+// authorize 检查领取贡献权的请求来自仪式的机构：请求对服务器为其下发、未过期的挑战签名；
+// 挑战无论验证是否通过都只能使用一次
+func (s *ceremonyServer) authorize(req *pb.ContributionRequest) (bls12381.G1Affine, error) {
+	pk, err := participantKey(req.Participant)
+	if err != nil {
+		return pk, err
+	}
+	if !s.ceremony.Authorized(pk) {
+		return pk, status.Error(codes.PermissionDenied, "participant is not an authority of the ceremony")
+	}
+	s.mu.Lock()
+	challenge, ok := s.challenges[string(req.Participant)]
+	delete(s.challenges, string(req.Participant))
+	s.mu.Unlock()
+	if !ok || !bytes.Equal(challenge.value, req.Challenge) {
+		return pk, status.Error(codes.FailedPrecondition, "request does not answer an issued challenge")
+	}
+	if time.Now().After(challenge.expires) {
+		return pk, status.Error(codes.DeadlineExceeded, "challenge expired")
+	}
+	var sig bls12381.G2Affine
+	if _, err := sig.SetBytes(req.Signature); err != nil {
+		return pk, status.Errorf(codes.InvalidArgument, "invalid request signature: %v", err)
+	}
+	if !utils.VerifyAggregateSignature(pk, sig, utils.CeremonyRequestMessage(req.Participant, challenge.value)) {
+		return pk, status.Error(codes.Unauthenticated, "request signature does not match the participant key")
+	}
+	return pk, nil
+}
+
+// NextContribution 阻塞直到轮到调用方贡献；已在当前阶段贡献过的机构等待下一阶段，仪式结束后返回阶段 0
+func (s *ceremonyServer) NextContribution(ctx context.Context, req *pb.ContributionRequest) (*pb.ContributionState, error) {
+	pk, err := s.authorize(req)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		s.mu.Lock()
+		phase, index, state := s.ceremony.State()
+		if phase == 0 {
+			s.mu.Unlock()
+			return &pb.ContributionState{}, nil
+		}
+		if !s.ceremony.Contributed(pk) && (s.holder == nil || s.holder.Equal(&pk) || time.Now().After(s.expires)) {
+			if s.holder != nil && !s.holder.Equal(&pk) {
+				log.Printf("[Ceremony] 机构 %x 的贡献权已超时", s.holder.Bytes())
+			}
+			s.holder = &pk
+			s.expires = time.Now().Add(s.lease)
+			s.mu.Unlock()
+			log.Printf("[Ceremony] 阶段 %d 第 %d 次贡献交给机构 %x", phase, index, req.Participant)
+			return &pb.ContributionState{
+				Phase: uint32(phase),
+				Index: uint32(index),
+				Curve: s.ceremony.Curve().String(),
+				State: state,
+			}, nil
+		}
+		changed, wait := s.changed, time.Until(s.expires)
+		s.mu.Unlock()
+		if wait <= 0 {
+			wait = s.lease
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// SubmitContribution 验证持有贡献权的机构提交的贡献；仪式结束后将密钥与仪式记录写入输出目录
+func (s *ceremonyServer) SubmitContribution(ctx context.Context, req *pb.Contribution) (*pb.ContributionReceipt, error) {
+	pk, err := participantKey(req.Participant)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder == nil || !s.holder.Equal(&pk) {
+		return nil, status.Error(codes.FailedPrecondition, "participant does not hold the turn, call NextContribution first")
+	}
+	_, index, _ := s.ceremony.State()
+	err = s.ceremony.Submit(int(req.Phase), &utils.CeremonyContribution{
+		Participant: req.Participant,
+		Signature:   req.Signature,
+		Data:        req.Data,
+	})
+	// 无论贡献是否有效都释放贡献权，避免无效贡献阻塞仪式
+	s.broadcast()
+	if err != nil {
+		log.Printf("[Ceremony] 拒绝机构 %x 的贡献: %v", req.Participant, err)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid contribution: %v", err)
+	}
+	log.Printf("[Ceremony] 接受机构 %x 对阶段 %d 的第 %d 次贡献", req.Participant, req.Phase, index)
+
+	if phase, _, _ := s.ceremony.State(); phase == 0 {
+		s.save()
+	}
+	hash := sha256.Sum256(req.Data)
+	return &pb.ContributionReceipt{Index: uint32(index), Hash: hash[:]}, nil
+}
+
+// save 写入仪式生成的密钥与可审计的仪式记录
+func (s *ceremonyServer) save() {
+	artifacts, err := s.ceremony.Artifacts()
+	if err != nil {
+		log.Printf("[Ceremony] %v", err)
+		return
+	}
+	if err := artifacts.Save(s.out); err != nil {
+		log.Printf("[Ceremony] 写入产物失败: %v", err)
+		return
+	}
+	transcript := s.ceremony.Transcript()
+	if err := transcript.Save(s.out); err != nil {
+		log.Printf("[Ceremony] 写入仪式记录失败: %v", err)
+		return
+	}
+	log.Printf("[Ceremony] 仪式结束，产物与仪式记录已写入 %s，vk sha256=%s", s.out, transcript.VKHash)
+}
+
+// GetTranscript 返回目前为止的仪式记录（JSON）
+func (s *ceremonyServer) GetTranscript(ctx context.Context, req *pb.TranscriptRequest) (*pb.TranscriptResponse, error) {
+	s.mu.Lock()
+	phase, _, _ := s.ceremony.State()
+	transcript := s.ceremony.Transcript()
+	s.mu.Unlock()
+	data, err := json.Marshal(transcript)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal transcript: %v", err)
+	}
+	return &pb.TranscriptResponse{Complete: phase == 0, Transcript: data}, nil
+}
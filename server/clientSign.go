@@ -28,34 +28,6 @@ func SignMessage(signers []Signer, message []byte) []bls12381.G2Affine {
 	return signatures
 }
 
-// // 聚合公钥和签名
-func Aggregate(pks []bls12381.G1Affine, signatures []bls12381.G2Affine) (bls12381.G1Affine, bls12381.G2Affine) {
-	// 聚合公钥 (apk = Σ pk_i)
-	var aggPublicKey bls12381.G1Jac
-	for _, signer := range pks {
-		var pkJac bls12381.G1Jac
-		pkJac.FromAffine(&signer)
-		aggPublicKey.AddAssign(&pkJac)
-	}
-
-	// 聚合签名 (asig = Σ σ_i)
-	var aggSignature bls12381.G2Jac
-	for _, sig := range signatures {
-		var sigJac bls12381.G2Jac
-		sigJac.FromAffine(&sig)
-		aggSignature.AddAssign(&sigJac)
-	}
-
-	// 转回仿射坐标
-	var aggPKAff bls12381.G1Affine
-	aggPKAff.FromJacobian(&aggPublicKey)
-
-	var aggSigAff bls12381.G2Affine
-	aggSigAff.FromJacobian(&aggSignature)
-
-	return aggPKAff, aggSigAff
-}
-
 // VerifyAggregateSignatureWithPairingCheck 使用 bls12381.PairingCheck 验证聚合签名
 func VerifyAggregateSignature(aggPubKey bls12381.G1Affine, aggSignature bls12381.G2Affine, message []byte) bool {
 	dst := []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_NUL_")
@@ -3,20 +3,26 @@ package main
 import (
 	"DID/utils"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/consensys/gnark-crypto/ecc"
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
-	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 
 	pb "DID/proto"
 )
@@ -29,9 +35,12 @@ type waitingClient struct {
 
 type ResultSession struct {
 	rmu      sync.Mutex
-	expected int // 期望收到的客户端数
+	id       uint64            // 签发会话编号，会话开始时分配，签发日志以此检测同一会话的冲突签发
+	expected int               // 期望收到的客户端数
+	config   utils.ShareConfig // 会话的分享方式，由第一个提交的客户端确定
 	results  map[int][]byte
-	readyCh  chan []byte
+	readyCh  chan []byte // 还原失败时发送 nil，错误记录在 err 中
+	err      error
 }
 
 type SignSession struct {
@@ -42,6 +51,13 @@ type SignSession struct {
 	rmu      sync.Mutex
 }
 
+// expectedSigners 是每次签发的机构数，签发日志的树头同样需要全部机构联合签名
+const expectedSigners = 4
+
+// authoritiesFile 是受信任机构公钥列表的默认路径。列表由运营方预先配置（SaveAuthorities 格式），
+// 服务器只读取，不会由请求中的数据生成；user 与 verify 命令用同一列表检查聚合公钥
+const authoritiesFile = "authorities.json"
+
 // matchServer 实现 MatchServiceServer 接口
 type matchServer struct {
 	pb.UnimplementedMatchServiceServer
@@ -56,6 +72,23 @@ type matchServer struct {
 
 	muSigns  sync.Mutex
 	signSess map[int]*SignSession
+
+	// 签发日志：记录每次签发的 merkle root 与聚合签名，供审计方检查
+	tlog     *utils.TransparencyLog
+	logs     *logServer
+	roots    map[int]issuance // 每个分组最近一次计算出的 merkle root，受 muResults 保护
+	sessions uint64           // 已开始的签发会话数，受 muResults 保护
+
+	// 签发会话的凭证类型，决定凭证树的哈希与哈希方式，客户端按同一类型计算叶子
+	ct utils.CredentialType
+	// 运营方配置的受信任机构公钥，只有这些机构可以参与签发
+	authorities []bls12381.G1Affine
+}
+
+// issuance 是一次签发会话还原出的 merkle root
+type issuance struct {
+	session uint64
+	root    []byte
 }
 
 type AggregateMsg struct {
@@ -63,10 +96,15 @@ type AggregateMsg struct {
 	Signature bls12381.G2Affine `json:"signature"`
 }
 
-func newMatchServer() *matchServer {
+func newMatchServer(ct utils.CredentialType, authorities []bls12381.G1Affine, tlog *utils.TransparencyLog, logs *logServer) *matchServer {
 	return &matchServer{
-		resultSess: make(map[int]*ResultSession),
-		signSess:   make(map[int]*SignSession),
+		ct:          ct,
+		authorities: authorities,
+		resultSess:  make(map[int]*ResultSession),
+		signSess:    make(map[int]*SignSession),
+		tlog:        tlog,
+		logs:        logs,
+		roots:       make(map[int]issuance),
 	}
 }
 
@@ -115,10 +153,67 @@ func (s *matchServer) Register(ctx context.Context, req *pb.RegisterRequest) (*p
 	}
 }
 
+// shareConfig 根据请求中的分享元数据得到会话的分享方式，未填写的字段取默认值
+func shareConfig(req *pb.ResultRequest) (utils.ShareConfig, error) {
+	cfg := utils.DefaultShareConfig
+	switch req.ShareScheme {
+	case pb.ShareScheme_SHARE_SCHEME_ADDITIVE:
+		cfg.Scheme = utils.ShareSchemeAdditive
+	case pb.ShareScheme_SHARE_SCHEME_SHAMIR:
+		cfg.Scheme = utils.ShareSchemeShamir
+	default:
+		return cfg, fmt.Errorf("unknown share scheme %v", req.ShareScheme)
+	}
+	if req.ShareCount != 0 {
+		cfg.Parties = int(req.ShareCount)
+	}
+	cfg.Threshold = cfg.Parties
+	if req.Threshold != 0 {
+		cfg.Threshold = int(req.Threshold)
+	}
+	return cfg, cfg.Validate()
+}
+
+// combineResults 按序号排序后每 Parties 个客户端一组还原叶子哈希，并按序号顺序构建凭证类型 ct 的 merkle 树。
+// 每组必须有全部 Parties 个提交，Shamir 分享的门限不能容忍缺少的客户端（见 utils.ShareSchemeShamir）
+func combineResults(ct utils.CredentialType, cfg utils.ShareConfig, results map[int][]byte) ([]byte, error) {
+	seqs := make([]int, 0, len(results))
+	for seq := range results {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	if len(seqs)%cfg.Parties != 0 {
+		return nil, fmt.Errorf("%d results cannot be split into groups of %d", len(seqs), cfg.Parties)
+	}
+	mTree := utils.New(ct.Profile().NewHash())
+	if err := mTree.SetHashMode(ct.Mode); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(seqs); i += cfg.Parties {
+		group := make([][]byte, cfg.Parties)
+		for j := range group {
+			group[j] = results[seqs[i+j]]
+		}
+		leaves, err := cfg.Combine(group)
+		if err != nil {
+			return nil, fmt.Errorf("group starting at sequence %d: %v", seqs[i], err)
+		}
+		for j := range leaves {
+			leaf := leaves[j].Bytes()
+			mTree.Push(leaf[:])
+		}
+	}
+	return mTree.Root(), nil
+}
+
 func (s *matchServer) SubmitResult(ctx context.Context, req *pb.ResultRequest) (*pb.ResultResponse, error) {
 	const expectedClients = 4
-	if len(req.ResultData) != 64 {
-		return nil, fmt.Errorf("invalid data length: expected 64 bytes, got %d", len(req.ResultData))
+	cfg, err := shareConfig(req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid share metadata: %v", err)
+	}
+	if _, err := cfg.ParseShares(req.ResultData); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid result data: %v", err)
 	}
 
 	seq := int(req.Sequence) // 客户端唯一 ID
@@ -127,8 +222,11 @@ func (s *matchServer) SubmitResult(ctx context.Context, req *pb.ResultRequest) (
 	s.muResults.Lock()
 	sess, ok := s.resultSess[groupKey]
 	if !ok {
+		s.sessions++
 		sess = &ResultSession{
+			id:       s.sessions,
 			expected: expectedClients,
+			config:   cfg,
 			results:  make(map[int][]byte),
 			readyCh:  make(chan []byte, expectedClients),
 		}
@@ -136,18 +234,25 @@ func (s *matchServer) SubmitResult(ctx context.Context, req *pb.ResultRequest) (
 	}
 	s.muResults.Unlock()
 	sess.rmu.Lock()
+	if sess.config != cfg {
+		sess.rmu.Unlock()
+		return nil, status.Errorf(codes.InvalidArgument, "share metadata %+v does not match session %+v", cfg, sess.config)
+	}
 	if _, exists := sess.results[seq]; !exists {
 		sess.results[seq] = req.ResultData
 		log.Printf("[MatchServer] 收到客户端 %d 的数据: %x\n", seq, req.ResultData)
 	}
 	if len(sess.results) == sess.expected {
-		hashMethod := mimc.NewMiMC()
-		mTree := utils.New(hashMethod)
-		for _, v := range sess.results {
-			mTree.Push(v)
+		root, err := combineResults(s.ct, sess.config, sess.results)
+		if err != nil {
+			// 通知所有等待的客户端本次会话失败
+			sess.err = status.Errorf(codes.InvalidArgument, "failed to recombine shares: %v", err)
+		} else {
+			log.Printf("Merkle Root: %x\n", root)
+			s.muResults.Lock()
+			s.roots[groupKey] = issuance{session: sess.id, root: root}
+			s.muResults.Unlock()
 		}
-		root := mTree.Root()
-		log.Printf("Merkle Root: %x\n", root)
 		for i := 0; i < sess.expected; i++ {
 			sess.readyCh <- root
 		}
@@ -158,6 +263,11 @@ func (s *matchServer) SubmitResult(ctx context.Context, req *pb.ResultRequest) (
 		s.muResults.Lock()
 		delete(s.resultSess, groupKey)
 		s.muResults.Unlock()
+		if aggregated == nil {
+			sess.rmu.Lock()
+			defer sess.rmu.Unlock()
+			return nil, sess.err
+		}
 		return &pb.ResultResponse{ProcessedData: aggregated}, nil
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -165,16 +275,37 @@ func (s *matchServer) SubmitResult(ctx context.Context, req *pb.ResultRequest) (
 }
 
 func (s *matchServer) SignMessage(ctx context.Context, req *pb.SignRequest) (*pb.SignResponse, error) {
-	const expectedClients = 4
+	const expectedClients = expectedSigners
 	groupKey := 42 // 固定值，也可以每轮生成唯一key
+	var signature bls12381.G2Affine
+	var pk bls12381.G1Affine
+	if err := signature.Unmarshal(req.SignedMessage); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to unmarshal signature: %v", err)
+	}
+	if err := pk.Unmarshal(req.PublicKey); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to unmarshal public key: %v", err)
+	}
+	// 只接受运营方配置的机构，且签名必须针对本次还原出的 merkle root
+	if !containsKey(s.authorities, &pk) {
+		return nil, status.Error(codes.PermissionDenied, "unknown authority")
+	}
+	s.muResults.Lock()
+	issued, ok := s.roots[groupKey]
+	s.muResults.Unlock()
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no merkle root to sign")
+	}
+	if !utils.VerifyAggregateSignature(pk, signature, issued.root) {
+		return nil, status.Error(codes.InvalidArgument, "invalid signature over the merkle root")
+	}
 	//初始化
 	s.muSigns.Lock()
 	sess, ok := s.signSess[groupKey]
 	if !ok {
 		sess = &SignSession{
 			expected: expectedClients,
-			sigs:     make([]bls12381.G2Affine, expectedClients),
-			pks:      make([]bls12381.G1Affine, expectedClients),
+			sigs:     make([]bls12381.G2Affine, 0, expectedClients),
+			pks:      make([]bls12381.G1Affine, 0, expectedClients),
 			readyCh:  make(chan []byte, expectedClients),
 		}
 		s.signSess[groupKey] = sess
@@ -182,35 +313,20 @@ func (s *matchServer) SignMessage(ctx context.Context, req *pb.SignRequest) (*pb
 	s.muSigns.Unlock()
 	//存入当前服务器
 	sess.rmu.Lock()
-	var signature bls12381.G2Affine
-	var pk bls12381.G1Affine
-	err := signature.Unmarshal(req.SignedMessage)
-	if err != nil {
+	if containsKey(sess.pks, &pk) {
 		sess.rmu.Unlock()
-		return nil, fmt.Errorf("failed to unmarshal signature: %v", err)
+		return nil, status.Error(codes.AlreadyExists, "authority already signed this root")
 	}
 	sess.sigs = append(sess.sigs, signature)
-	err = pk.Unmarshal(req.PublicKey)
-	if err != nil {
-		sess.rmu.Unlock()
-		return nil, fmt.Errorf("failed to unmarshal public key: %v", err)
-	}
 	sess.pks = append(sess.pks, pk)
 
 	if len(sess.sigs) == sess.expected && len(sess.pks) == sess.expected {
-		aggPK, aggSig := Aggregate(sess.pks, sess.sigs)
+		aggPK, aggSig := utils.Aggregate(sess.pks, sess.sigs)
 		aggSigBytes := aggSig.Marshal()
-		if err != nil {
-			sess.rmu.Unlock()
-			return nil, fmt.Errorf("failed to marshal aggregated signature: %v", err)
-		}
 		aggPKBytes := aggPK.Marshal()
-		if err != nil {
-			sess.rmu.Unlock()
-			return nil, fmt.Errorf("failed to marshal aggregated public key: %v", err)
-		}
 		log.Printf("[MatchServer] 聚合签名完成: %x\n", aggSigBytes)
 		log.Printf("[MatchServer] 聚合公钥完成: %x\n", aggPKBytes)
+		s.logIssuance(groupKey, aggSig, sess.pks)
 		for i := 0; i < sess.expected; i++ {
 			sess.readyCh <- aggSigBytes
 		}
@@ -230,10 +346,68 @@ func (s *matchServer) SignMessage(ctx context.Context, req *pb.SignRequest) (*pb
 	if err != nil {
 		return nil, fmt.Errorf("failed to write aggregate message to file: %v", err)
 	}
-	return nil, nil
+	// 等待全部机构签名，之后机构对签发日志的新树头签名
+	select {
+	case aggregated := <-sess.readyCh:
+		s.muSigns.Lock()
+		if s.signSess[groupKey] == sess {
+			delete(s.signSess, groupKey)
+		}
+		s.muSigns.Unlock()
+		s.muResults.Lock()
+		root := s.roots[groupKey].root
+		s.muResults.Unlock()
+		return &pb.SignResponse{AggrResult: aggregated, MerkleRoot: root}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// logIssuance 将本次签发写入签发日志
+func (s *matchServer) logIssuance(groupKey int, aggSig bls12381.G2Affine, pks []bls12381.G1Affine) {
+	s.muResults.Lock()
+	issued, ok := s.roots[groupKey]
+	s.muResults.Unlock()
+	if !ok {
+		log.Printf("[MatchServer] 分组 %d 没有 merkle root，跳过签发日志", groupKey)
+		return
+	}
+	entry := utils.LogEntry{
+		Session:   issued.session,
+		Root:      issued.root,
+		Signature: aggSig,
+		Signers:   pks,
+	}
+	index, err := s.tlog.Append(entry)
+	if err != nil {
+		// 同一会话的第二次签发（无论 root 是否相同）都会被日志拒绝
+		log.Printf("[MatchServer] 写入签发日志失败: %v", err)
+		return
+	}
+	s.logs.appended()
+	log.Printf("[MatchServer] 会话 %d 已写入签发日志，索引 %d", issued.session, index)
 }
 
+// maxMessageSize 是 gRPC 消息的大小上限，仪式状态随电路规模增长，远超默认的 4MB
+const maxMessageSize = 256 << 20
+
 func main() {
+	authoritiesPath := flag.String("authorities", authoritiesFile, "运营方配置的受信任机构公钥列表（SaveAuthorities 格式），只有其中的机构可以参与签发与仪式")
+	participants := flag.Int("ceremony", 0, "可信设置仪式的机构数，0 表示不运行仪式")
+	ceremonyOut := flag.String("ceremony-out", "setup-artifacts", "仪式结束后写入密钥与仪式记录的目录")
+	ceremonyLease := flag.Duration("ceremony-lease", 10*time.Minute, "机构持有贡献权的最长时间，超时后由其他机构接替")
+	name := flag.String("name", "valid", "凭证类型（仪式电路）名称")
+	version := flag.Int("version", 1, "凭证类型（仪式电路）版本")
+	curveName := flag.String("curve", "bn254", "凭证类型所在曲线，签发会话只支持 bn254")
+	hashName := flag.String("hash", "mimc", "哈希函数：mimc 或 poseidon2")
+	modeName := flag.String("mode", "plain", "哈希方式：plain、rfc6962 或 domain-tag")
+	depth := flag.Int("depth", 2, "merkle 证明的最大深度")
+	signature := flag.Bool("signature", false, "在电路内验证机构的聚合签名")
+	holder := flag.Bool("holder", false, "叶子绑定持有者，证明需要持有者秘密（见 utils.ProveHolder）")
+	attributes := flag.Int("attributes", 1, "绑定持有者的叶子中的属性数，仅在 -holder 时使用")
+	nullifier := flag.Bool("nullifier", false, "证明输出验证方域内的 nullifier 并回应其挑战（见 utils.ProveNullifier），需要 -holder")
+	flag.Parse()
+
 	certFile := "certs/server/server.pem"
 	keyFile := "certs/server/server.key"
 	caFile := "certs/ca/ca.pem"
@@ -265,9 +439,49 @@ func main() {
 	if err != nil {
 		log.Fatalf("[MatchServer] 无法监听 :5000: %v", err)
 	}
-	grpcServer := grpc.NewServer(grpc.Creds(creds))
-	matchSrv := newMatchServer()
+	grpcServer := grpc.NewServer(grpc.Creds(creds), grpc.MaxRecvMsgSize(maxMessageSize), grpc.MaxSendMsgSize(maxMessageSize))
+	// 签发会话与仪式使用同一凭证类型；份额在 BN254 上计算，凭证树也必须在 BN254 上
+	profile, err := utils.ParseProfile(*curveName, *hashName)
+	if err != nil {
+		log.Fatalf("[MatchServer] %v", err)
+	}
+	mode, err := utils.ParseHashMode(*modeName)
+	if err != nil {
+		log.Fatalf("[MatchServer] %v", err)
+	}
+	ct := utils.CredentialType{Name: *name, Curve: profile.Curve, Hash: profile.Hash, Mode: mode, Signature: *signature, Nullifier: *nullifier, Version: *version}
+	if *holder {
+		ct.Holder, ct.Attributes = true, *attributes
+	}
+	if err := ct.Validate(); err != nil {
+		log.Fatalf("[MatchServer] %v", err)
+	}
+	if profile.CurveID() != ecc.BN254 {
+		log.Fatalf("[MatchServer] 签发会话的份额在 BN254 上计算，不支持曲线 %s", profile.CurveID())
+	}
+
+	authorities, err := utils.LoadAuthorities(*authoritiesPath)
+	if err != nil {
+		log.Fatalf("[MatchServer] 无法加载受信任机构公钥: %v", err)
+	}
+	if len(authorities) != expectedSigners {
+		log.Fatalf("[MatchServer] %s 中有 %d 个机构公钥，签发需要 %d 个", *authoritiesPath, len(authorities), expectedSigners)
+	}
+
+	tlog := utils.NewTransparencyLog(sha256.New())
+	logSrv := newLogServer(tlog, authorities)
+	matchSrv := newMatchServer(ct, authorities, tlog, logSrv)
 	pb.RegisterMatchServiceServer(grpcServer, matchSrv)
+	pb.RegisterTransparencyLogServiceServer(grpcServer, logSrv)
+
+	if *participants > 0 {
+		ceremony, err := utils.NewCeremony(ct, *depth, *participants, authorities, nil)
+		if err != nil {
+			log.Fatalf("[Ceremony] 无法开始仪式: %v", err)
+		}
+		pb.RegisterCeremonyServiceServer(grpcServer, newCeremonyServer(ceremony, *ceremonyOut, *ceremonyLease))
+		log.Printf("[Ceremony] 电路 %s（%v, %v, 深度 %d）的可信设置仪式已开始，等待 %d 个机构贡献", *name, profile, mode, *depth, *participants)
+	}
 
 	log.Println("[MatchServer] TLS 已启用，监听端口 :5000，等待客户端注册......")
 	if err := grpcServer.Serve(lis); err != nil {
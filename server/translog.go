@@ -0,0 +1,159 @@
+package main
+
+import (
+	"DID/utils"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "DID/proto"
+)
+
+// logServer 实现 TransparencyLogServiceServer：审计方读取签发日志的树头、记录与证明，
+// 机构对每次签发后的树头签名，收齐 signers 个签名后聚合为联合签名的树头
+type logServer struct {
+	pb.UnimplementedTransparencyLogServiceServer
+
+	tlog    *utils.TransparencyLog
+	trusted []bls12381.G1Affine // 运营方配置的受信任机构公钥，全部机构联合签名树头
+	signers int                 // 联合签名树头需要的机构数
+
+	mu      sync.Mutex
+	pending *utils.TreeHead // 等待机构签名的最新树头
+	pks     []bls12381.G1Affine
+	sigs    []bls12381.G2Affine
+	signed  *utils.SignedTreeHead // 最近一次联合签名的树头
+}
+
+func newLogServer(tlog *utils.TransparencyLog, trusted []bls12381.G1Affine) *logServer {
+	return &logServer{tlog: tlog, trusted: trusted, signers: len(trusted)}
+}
+
+// appended 在追加签发记录后调用，以新的树头等待机构签名
+func (s *logServer) appended() {
+	th, err := s.tlog.TreeHead(time.Now().Unix())
+	if err != nil {
+		log.Printf("[TransparencyLog] %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = &th
+	s.pks, s.sigs = nil, nil
+}
+
+func containsKey(keys []bls12381.G1Affine, pk *bls12381.G1Affine) bool {
+	for i := range keys {
+		if keys[i].Equal(pk) {
+			return true
+		}
+	}
+	return false
+}
+
+func treeHeadToProto(th *utils.TreeHead) *pb.TreeHead {
+	return &pb.TreeHead{Size: th.Size, Timestamp: th.Timestamp, Root: th.Root}
+}
+
+// response 返回当前树头与最近一次联合签名的树头，调用时需持有 s.mu
+func (s *logServer) response() *pb.TreeHeadResponse {
+	resp := &pb.TreeHeadResponse{}
+	if s.pending != nil {
+		resp.Head = treeHeadToProto(s.pending)
+	}
+	if s.signed != nil {
+		sig := s.signed.Signature.Bytes()
+		resp.Signed = &pb.SignedTreeHead{
+			Head:      treeHeadToProto(&s.signed.TreeHead),
+			Signature: sig[:],
+		}
+		for i := range s.signed.Signers {
+			pk := s.signed.Signers[i].Bytes()
+			resp.Signed.Signers = append(resp.Signed.Signers, pk[:])
+		}
+	}
+	return resp
+}
+
+func (s *logServer) GetTreeHead(ctx context.Context, req *pb.TreeHeadRequest) (*pb.TreeHeadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.response(), nil
+}
+
+// SignTreeHead 收集机构对当前树头的签名。签名机构必须是受信任机构，且每个机构只计一次
+func (s *logServer) SignTreeHead(ctx context.Context, req *pb.TreeHeadSignature) (*pb.TreeHeadResponse, error) {
+	var pk bls12381.G1Affine
+	var sig bls12381.G2Affine
+	if _, err := pk.SetBytes(req.PublicKey); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid public key: %v", err)
+	}
+	if _, err := sig.SetBytes(req.Signature); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid signature: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	th := s.pending
+	if th == nil || req.Head == nil || req.Head.Size != th.Size || req.Head.Timestamp != th.Timestamp || string(req.Head.Root) != string(th.Root) {
+		return nil, status.Error(codes.FailedPrecondition, "signature is not for the current tree head")
+	}
+	if !containsKey(s.trusted, &pk) {
+		return nil, status.Error(codes.PermissionDenied, "unknown authority")
+	}
+	if containsKey(s.pks, &pk) {
+		return s.response(), nil
+	}
+	if !utils.VerifyAggregateSignature(pk, sig, th.Message()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid signature over the tree head")
+	}
+	s.pks = append(s.pks, pk)
+	s.sigs = append(s.sigs, sig)
+	if len(s.pks) == s.signers {
+		sth, err := utils.CoSign(*th, s.pks, s.sigs)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "co-sign tree head: %v", err)
+		}
+		if err := sth.Verify(s.trusted, s.signers); err != nil {
+			return nil, status.Errorf(codes.Internal, "co-signed tree head: %v", err)
+		}
+		s.signed = sth
+		log.Printf("[TransparencyLog] 大小为 %d 的树头已由 %d 个机构联合签名，root=%x", th.Size, s.signers, th.Root)
+	}
+	return s.response(), nil
+}
+
+// GetEntry 返回一条签发记录及其包含证明，审计方据此检查同一会话没有相互冲突的签发
+func (s *logServer) GetEntry(ctx context.Context, req *pb.EntryRequest) (*pb.EntryResponse, error) {
+	index := req.Index
+	if req.Session != 0 {
+		i, ok := s.tlog.Lookup(req.Session)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "session %d not logged", req.Session)
+		}
+		index = i
+	}
+	size := req.TreeSize
+	if size == 0 {
+		size = s.tlog.Size()
+	}
+	proof, err := s.tlog.InclusionProof(index, size)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return &pb.EntryResponse{Index: index, TreeSize: size, Entry: proof.Leaf, Path: proof.Path}, nil
+}
+
+// GetConsistencyProof 返回两个树大小之间的一致性证明
+func (s *logServer) GetConsistencyProof(ctx context.Context, req *pb.ConsistencyRequest) (*pb.ConsistencyResponse, error) {
+	path, err := s.tlog.ConsistencyProof(req.First, req.Second)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return &pb.ConsistencyResponse{Path: path}, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"DID/utils"
+	"flag"
+	"log"
+)
+
+// setup 只需运行一次：编译凭证电路并生成 Groth16 或 PLONK 的证明密钥与验证密钥，
+// 写入输出目录，证明者与验证者随后从该目录加载。
+func main() {
+	out := flag.String("out", "setup-artifacts", "产物输出目录")
+	name := flag.String("name", "valid", "电路名称，用于产物文件名")
+	version := flag.Int("version", 1, "电路版本，同名电路变化时递增，记录在证明信封中")
+	curveName := flag.String("curve", "bn254", "电路所在曲线：bn254 或 bls12_381，原生哈希使用其标量域")
+	hashName := flag.String("hash", "mimc", "哈希函数：mimc 或 poseidon2")
+	modeName := flag.String("mode", "plain", "哈希方式：plain、rfc6962 或 domain-tag")
+	depth := flag.Int("depth", 2, "merkle 证明的最大深度，深度更小的证明补齐后使用同一电路")
+	backendName := flag.String("backend", "groth16", "证明后端：groth16 或 plonk")
+	srsPath := flag.String("srs", "", "plonk 使用的 KZG SRS 文件（canonical 形式），为空时在本地生成，仅用于测试")
+	signature := flag.Bool("signature", false, "在电路内验证机构的聚合签名（约束数显著增加）")
+	holder := flag.Bool("holder", false, "叶子绑定持有者，证明需要持有者秘密（见 utils.ProveHolder）")
+	attributes := flag.Int("attributes", 1, "绑定持有者的叶子中的属性数，仅在 -holder 时使用")
+	nullifier := flag.Bool("nullifier", false, "证明输出验证方域内的 nullifier 并回应其挑战（见 utils.ProveNullifier），需要 -holder")
+	flag.Parse()
+
+	profile, err := utils.ParseProfile(*curveName, *hashName)
+	if err != nil {
+		log.Fatalf("[Setup] %v", err)
+	}
+	mode, err := utils.ParseHashMode(*modeName)
+	if err != nil {
+		log.Fatalf("[Setup] %v", err)
+	}
+	backend, err := utils.ParseBackend(*backendName)
+	if err != nil {
+		log.Fatalf("[Setup] %v", err)
+	}
+
+	ct := utils.CredentialType{Name: *name, Curve: profile.Curve, Hash: profile.Hash, Mode: mode, Signature: *signature, Nullifier: *nullifier, Version: *version}
+	if *holder {
+		ct.Holder, ct.Attributes = true, *attributes
+	}
+	log.Printf("[Setup] 编译电路 %s（%v, %v, %v, 深度 %d）", *name, backend, profile, mode, *depth)
+	var artifacts *utils.SetupArtifacts
+	if backend == utils.BackendPlonk {
+		srs := utils.SRSProvider(utils.UnsafeSRS)
+		if *srsPath != "" {
+			srs = utils.FileSRS(*srsPath)
+		} else {
+			log.Printf("[Setup] 未指定 -srs，在本地生成 KZG SRS，产物不可用于生产环境")
+		}
+		artifacts, err = utils.SetupPlonk(ct, *depth, srs)
+	} else {
+		artifacts, err = utils.Setup(ct, *depth)
+	}
+	if err != nil {
+		log.Fatalf("[Setup] %v", err)
+	}
+	if err := artifacts.Save(*out); err != nil {
+		log.Fatalf("[Setup] 写入产物失败: %v", err)
+	}
+	for kind, file := range artifacts.Manifest.Files {
+		log.Printf("[Setup] %s: %s sha256=%s", kind, file, artifacts.Manifest.Digests[kind])
+	}
+}
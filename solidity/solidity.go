@@ -0,0 +1,82 @@
+package main
+
+import (
+	"DID/utils"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// solidity 将可信设置的验证密钥导出为 Solidity 验证合约（仅支持 bn254），
+// 同时指定 -envelope（或 -proof 与 -public）时输出调用该合约验证证明的 calldata。
+func main() {
+	setupDir := flag.String("setup", "setup-artifacts", "setup 命令生成的产物目录")
+	out := flag.String("out", "Verifier.sol", "合约输出文件")
+	proofPath := flag.String("proof", "", "user 命令写入的证明文件，为空时只导出合约")
+	publicPath := flag.String("public", "", "user 命令写入的公开输入文件（JSON）")
+	envelopePath := flag.String("envelope", "", "user 命令写入的证明信封，可代替 -proof 与 -public")
+	flag.Parse()
+
+	m, vk, err := utils.LoadVerifyingKey(*setupDir)
+	if err != nil {
+		log.Fatalf("[Solidity] 无法加载验证密钥: %v", err)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("[Solidity] %v", err)
+	}
+	if err := utils.ExportSolidity(vk, f); err != nil {
+		f.Close()
+		log.Fatalf("[Solidity] 导出合约失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("[Solidity] %v", err)
+	}
+	log.Printf("[Solidity] 电路 %s（%s）的合约 %s 已写入 %s", m.Circuit, vk.Backend, utils.SolidityContract(vk.Backend), *out)
+
+	var proof *utils.ZKProof
+	var public *utils.PublicInputs
+	switch {
+	case *envelopePath != "":
+		data, err := os.ReadFile(*envelopePath)
+		if err != nil {
+			log.Fatalf("[Solidity] 无法读取证明信封: %v", err)
+		}
+		envelope, err := utils.DecodeEnvelope(data)
+		if err != nil {
+			log.Fatalf("[Solidity] 无法解析证明信封: %v", err)
+		}
+		if proof, err = envelope.ZKProof(); err != nil {
+			log.Fatalf("[Solidity] %v", err)
+		}
+		if public, err = envelope.PublicInputs(); err != nil {
+			log.Fatalf("[Solidity] %v", err)
+		}
+	case *proofPath != "":
+		data, err := os.ReadFile(*proofPath)
+		if err != nil {
+			log.Fatalf("[Solidity] 无法读取证明: %v", err)
+		}
+		proof = new(utils.ZKProof)
+		if err := proof.UnmarshalBinary(data); err != nil {
+			log.Fatalf("[Solidity] 无法解析证明: %v", err)
+		}
+		data, err = os.ReadFile(*publicPath)
+		if err != nil {
+			log.Fatalf("[Solidity] 无法读取公开输入: %v", err)
+		}
+		public = new(utils.PublicInputs)
+		if err := json.Unmarshal(data, public); err != nil {
+			log.Fatalf("[Solidity] 无法解析公开输入: %v", err)
+		}
+	default:
+		return
+	}
+	calldata, err := utils.SolidityCalldata(proof, public)
+	if err != nil {
+		log.Fatalf("[Solidity] 编码 calldata 失败: %v", err)
+	}
+	fmt.Printf("0x%x\n", calldata)
+}
@@ -2,13 +2,14 @@ package main
 
 import (
 	"DID/utils"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
-	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
 )
 
 type AggregateMsg struct {
@@ -17,8 +18,35 @@ type AggregateMsg struct {
 }
 
 func main() {
-	h := mimc.NewMiMC()
-	tree := utils.New1(h)
+	setupDir := flag.String("setup", "setup-artifacts", "setup 命令生成的产物目录")
+	proofOut := flag.String("proof", "", "证明输出文件，为空时不写入")
+	publicOut := flag.String("public", "", "公开输入输出文件（JSON），为空时不写入")
+	envelopeOut := flag.String("envelope", "", "证明信封输出文件，为空时不写入")
+	formatName := flag.String("format", "json", "证明信封的编码：json 或 cbor")
+	keypairPath := flag.String("keypair", "", "持有者的 BLS 密钥对（客户端写入的 *_keypair.json），电路绑定持有者时必需")
+	authoritiesPath := flag.String("authorities", "authorities.json", "受信任机构的公钥列表（SaveAuthorities 格式）")
+	domainName := flag.String("domain", "", "验证方的 nullifier 域名称（如 vote-2026），电路输出 nullifier 时必需")
+	nonceHex := flag.String("nonce", "", "验证方发来的挑战（十六进制，见 verify -new-nonce），电路输出 nullifier 时必需")
+	flag.Parse()
+
+	format, err := utils.ParseEnvelopeFormat(*formatName)
+	if err != nil {
+		log.Fatalf("[User] %v", err)
+	}
+
+	//加载可信设置产物，树的哈希由其 Profile 决定
+	setup, err := utils.LoadSetup(*setupDir)
+	if err != nil {
+		log.Fatalf("[User] 无法加载可信设置产物: %v", err)
+	}
+	ct, err := setup.Manifest.CredentialType()
+	if err != nil {
+		log.Fatalf("[User] %v", err)
+	}
+	tree, err := ct.NewTree()
+	if err != nil {
+		log.Fatalf("[User] %v", err)
+	}
 
 	_ = tree.SetIndex(2) // 现在设置你想生成 proof 的叶子索引
 
@@ -29,11 +57,45 @@ func main() {
 		[]byte("world"),
 	}
 
+	// 电路绑定持有者时，该叶子为 H(持有者秘密, 属性...)，持有者秘密由密钥对派生，属性为数据的哈希
+	var holderSecret []byte
+	var attributes [][]byte
+	if ct.Holder {
+		if *keypairPath == "" {
+			log.Fatalf("[User] 电路 %s 绑定持有者，需要通过 -keypair 指定密钥对", setup.Manifest.Key())
+		}
+		keypair, err := utils.LoadKeypair(*keypairPath)
+		if err != nil {
+			log.Fatalf("[User] 无法加载 BLS 密钥对: %v", err)
+		}
+		sk := keypair.PrivateKey.Bytes()
+		holderSecret = utils.HolderSecret(ct.Profile(), sk[:])
+		for i := 0; i < ct.Attributes; i++ {
+			attributes = append(attributes, ct.Profile().Sum(string(data[(2+i)%len(data)])))
+		}
+		if data[2], err = utils.HolderLeaf(ct.Profile(), holderSecret, attributes); err != nil {
+			log.Fatalf("[User] 计算叶子失败: %v", err)
+		}
+	}
+
+	// 电路输出 nullifier 时，证明回应验证方在其域内的挑战
+	var statement utils.NullifierStatement
+	if ct.Nullifier {
+		if *domainName == "" || *nonceHex == "" {
+			log.Fatalf("[User] 电路 %s 输出 nullifier，需要通过 -domain 与 -nonce 指定验证方的域与挑战", setup.Manifest.Key())
+		}
+		nonce, err := hex.DecodeString(*nonceHex)
+		if err != nil {
+			log.Fatalf("[User] 无法解析挑战: %v", err)
+		}
+		statement = utils.NullifierStatement{Domain: utils.NullifierDomain(ct.Profile(), *domainName), Nonce: nonce}
+	}
+
 	for _, d := range data {
 		tree.Push1(d)
 	}
 
-	root, proofSet, proofIndex, _ := tree.Prove1()
+	root, proofSet, proofIndex, numLeaves := tree.Prove1()
 	fmt.Printf("Merkle Root: %x\n", root)
 	fmt.Printf("Proof Set: \n")
 	for i, p := range proofSet {
@@ -52,6 +114,72 @@ func main() {
 		log.Fatalf("[User] 无法解析聚合消息文件: %v", err)
 	}
 	//生成zkproof
-	utils.GenerateZKProof(aggMsg.PubKey, aggMsg.Signature, data[2], proofSet, proofIndex, root)
+	var proof *utils.ZKProof
+	var public *utils.PublicInputs
+	switch {
+	case ct.Nullifier:
+		proof, public, err = utils.ProveNullifier(setup, aggMsg.PubKey, aggMsg.Signature, statement, holderSecret, attributes, proofSet, proofIndex, numLeaves, root)
+	case ct.Holder:
+		proof, public, err = utils.ProveHolder(setup, aggMsg.PubKey, aggMsg.Signature, holderSecret, attributes, proofSet, proofIndex, numLeaves, root)
+	default:
+		proof, public, err = utils.Prove(setup, aggMsg.PubKey, aggMsg.Signature, data[2], proofSet, proofIndex, numLeaves, root)
+	}
+	if err != nil {
+		log.Fatalf("[User] 生成证明失败: %v", err)
+	}
+	proofBytes, err := proof.MarshalBinary()
+	if err != nil {
+		log.Fatalf("[User] 序列化证明失败: %v", err)
+	}
+	fmt.Printf("ZK Proof: %x\n", proofBytes)
+	if public.Nullifier != nil {
+		fmt.Printf("Nullifier: %x\n", public.Nullifier.Nullifier)
+	}
+	if *proofOut != "" {
+		if err := os.WriteFile(*proofOut, proofBytes, 0644); err != nil {
+			log.Fatalf("[User] 写入证明失败: %v", err)
+		}
+	}
+	if *publicOut != "" {
+		publicData, err := json.MarshalIndent(public, "", "  ")
+		if err != nil {
+			log.Fatalf("[User] 序列化公开输入失败: %v", err)
+		}
+		if err := os.WriteFile(*publicOut, publicData, 0644); err != nil {
+			log.Fatalf("[User] 写入公开输入失败: %v", err)
+		}
+	}
 
+	//封装为自描述的证明信封
+	envelope, err := utils.NewEnvelope(&setup.Manifest, setup.VerifyingKey(), proof, public)
+	if err != nil {
+		log.Fatalf("[User] 封装证明失败: %v", err)
+	}
+	envelopeData, err := envelope.Encode(format)
+	if err != nil {
+		log.Fatalf("[User] 编码证明信封失败: %v", err)
+	}
+	if *envelopeOut != "" {
+		if err := os.WriteFile(*envelopeOut, envelopeData, 0644); err != nil {
+			log.Fatalf("[User] 写入证明信封失败: %v", err)
+		}
+	}
+
+	//验证方只需验证密钥、受信任机构公钥与证明信封
+	authorities, err := utils.LoadAuthorities(*authoritiesPath)
+	if err != nil {
+		log.Fatalf("[User] 无法加载受信任机构公钥: %v", err)
+	}
+	_, vk, err := utils.LoadVerifyingKey(*setupDir)
+	if err != nil {
+		log.Fatalf("[User] 无法加载验证密钥: %v", err)
+	}
+	received, err := utils.DecodeEnvelope(envelopeData)
+	if err != nil {
+		log.Fatalf("[User] 无法解析证明信封: %v", err)
+	}
+	if err := utils.VerifyEnvelope(vk, received, authorities); err != nil {
+		log.Fatalf("[User] 证明验证失败: %v", err)
+	}
+	fmt.Println("ZK Proof verified")
 }
@@ -9,8 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"hash"
-
-	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 )
 
 // A Tree takes data as leaves and returns the Merkle root. Each call to 'Push'
@@ -109,7 +107,7 @@ func nodeSum1(h hash.Hash, a, b []byte) []byte {
 }
 
 // joinSubTrees combines two equal sized subTrees into a larger subTree.
-func joinSubTrees1(h hash.Hash, a, b *subTree) *subTree {
+func joinSubTrees1(h hash.Hash, mode HashMode, a, b *subTree) *subTree {
 	// if DEBUG {
 	// 	if b.next != a {
 	// 		panic("invalid subtree join - 'a' is not paired with 'b'")
@@ -122,7 +120,7 @@ func joinSubTrees1(h hash.Hash, a, b *subTree) *subTree {
 	return &subTree{
 		next:   a.next,
 		height: a.height + 1,
-		sum:    nodeSum1(h, a.sum, b.sum),
+		sum:    mode.NodeSum(h, a.sum, b.sum),
 	}
 }
 
@@ -222,9 +220,8 @@ func (t *Tree) Push1(data []byte) {
 	if t.cachedTree {
 		t.head.sum = data
 	} else {
-		t.head.sum = leafSum1(t.hash, data)
+		t.head.sum = t.mode.LeafSum(t.hash, data)
 	}
-	fmt.Println("hash:", new(fr.Element).SetBytes(t.head.sum))
 	// Join subTrees if possible.
 	t.joinAllSubTrees1()
 
@@ -304,7 +301,7 @@ func (t *Tree) joinAllSubTrees1() {
 	// 防止 proofSet 是空的情况下死循环
 	if len(t.proofSet) == 0 {
 		for t.head.next != nil && t.head.height == t.head.next.height {
-			t.head = joinSubTrees1(t.hash, t.head.next, t.head)
+			t.head = joinSubTrees1(t.hash, t.mode, t.head.next, t.head)
 		}
 		return
 	}
@@ -319,6 +316,6 @@ func (t *Tree) joinAllSubTrees1() {
 				t.proofSet = append(t.proofSet, t.head.next.sum)
 			}
 		}
-		t.head = joinSubTrees1(t.hash, t.head.next, t.head)
+		t.head = joinSubTrees1(t.hash, t.mode, t.head.next, t.head)
 	}
 }
@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"fmt"
+	"bytes"
 	"testing"
 
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
@@ -25,7 +25,12 @@ func TestMimc(t *testing.T) {
 		tree.Push1(d)
 	}
 
-	// 获取 Merkle Root
+	// Merkle Root 应为 H(H(H(d0), H(d1)), H(H(d2), H(d3)))
 	root := tree.Root1()
-	fmt.Printf("Merkle Root: %x\n", root)
+	m := HashModePlain
+	left := m.NodeSum(h, m.LeafSum(h, data[0]), m.LeafSum(h, data[1]))
+	right := m.NodeSum(h, m.LeafSum(h, data[2]), m.LeafSum(h, data[3]))
+	if expected := m.NodeSum(h, left, right); !bytes.Equal(root, expected) {
+		t.Fatalf("merkle root %x, expected %x", root, expected)
+	}
 }
@@ -0,0 +1,345 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	bls12381fr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	bn254fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/algebra"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_bls12381"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_bn254"
+	"github.com/consensys/gnark/std/math/emulated"
+	stdgroth16 "github.com/consensys/gnark/std/recursion/groth16"
+)
+
+// AggregateCurve 是聚合证明所在的曲线，内层证明在其上以模拟算术验证
+// AggregateCurve is the curve of aggregate proofs. Inner proofs of any
+// supported curve are verified on it with emulated arithmetic.
+const AggregateCurve = ecc.BN254
+
+// AggregateCircuit 在电路内验证 N 个同一电路（验证密钥固定）的 Groth16 证明。
+// 唯一的公开输入 Digest 是所有内层公开输入的哈希，验证方由各证明的 PublicInputs 重新计算。
+// An AggregateCircuit verifies in-circuit N Groth16 proofs of the credential
+// circuit whose verifying key is fixed at compile time. Its only public input
+// is Digest, the MiMC hash over AggregateCurve of the limbs of every inner
+// public input, which verifiers recompute from the inner PublicInputs.
+type AggregateCircuit[FR emulated.FieldParams, G1El algebra.G1ElementT, G2El algebra.G2ElementT, GtEl algebra.GtElementT] struct {
+	Digest frontend.Variable `gnark:",public"`
+
+	Proofs    []stdgroth16.Proof[G1El, G2El]
+	Witnesses []stdgroth16.Witness[FR] // 内层证明的公开输入
+
+	// 内层验证密钥作为常量编译进电路，不属于 witness
+	vk stdgroth16.VerifyingKey[G1El, G2El, GtEl]
+}
+
+func (c *AggregateCircuit[FR, G1El, G2El, GtEl]) Define(api frontend.API) error {
+	if len(c.Proofs) != len(c.Witnesses) {
+		return fmt.Errorf("%d proofs for %d witnesses", len(c.Proofs), len(c.Witnesses))
+	}
+	verifier, err := stdgroth16.NewVerifier[FR, G1El, G2El, GtEl](api)
+	if err != nil {
+		return err
+	}
+	var limbs []frontend.Variable
+	for i := range c.Proofs {
+		// 证明由聚合方提供，必须检查其位于正确的子群
+		if err := verifier.AssertProof(c.vk, c.Proofs[i], c.Witnesses[i], stdgroth16.WithSubgroupCheck()); err != nil {
+			return fmt.Errorf("proof %d: %v", i, err)
+		}
+		for _, pub := range c.Witnesses[i].Public {
+			limbs = append(limbs, pub.Limbs...)
+		}
+	}
+	digest, err := circuitSum(api, Profile{Curve: AggregateCurve}, limbs...)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(digest, c.Digest)
+	return nil
+}
+
+// aggregateOps 封装与内层曲线相关的泛型实例
+type aggregateOps interface {
+	placeholder(ccs constraint.ConstraintSystem, vk groth16.VerifyingKey, n int) (frontend.Circuit, error)
+	assignment(proofs []groth16.Proof, publics []witness.Witness, digest []byte) (frontend.Circuit, error)
+	limbs() (nbLimbs, nbBits uint)
+}
+
+type aggregateCurve[FR emulated.FieldParams, G1El algebra.G1ElementT, G2El algebra.G2ElementT, GtEl algebra.GtElementT] struct{}
+
+func aggregateOpsFor(inner ecc.ID) (aggregateOps, error) {
+	switch inner {
+	case ecc.BN254:
+		return aggregateCurve[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl]{}, nil
+	case ecc.BLS12_381:
+		return aggregateCurve[sw_bls12381.ScalarField, sw_bls12381.G1Affine, sw_bls12381.G2Affine, sw_bls12381.GTEl]{}, nil
+	default:
+		return nil, fmt.Errorf("aggregation of proofs on %s is not supported", inner)
+	}
+}
+
+func (aggregateCurve[FR, G1El, G2El, GtEl]) placeholder(ccs constraint.ConstraintSystem, vk groth16.VerifyingKey, n int) (frontend.Circuit, error) {
+	fixed, err := stdgroth16.ValueOfVerifyingKeyFixed[G1El, G2El, GtEl](vk)
+	if err != nil {
+		return nil, err
+	}
+	c := &AggregateCircuit[FR, G1El, G2El, GtEl]{
+		Proofs:    make([]stdgroth16.Proof[G1El, G2El], n),
+		Witnesses: make([]stdgroth16.Witness[FR], n),
+		vk:        fixed,
+	}
+	for i := 0; i < n; i++ {
+		c.Proofs[i] = stdgroth16.PlaceholderProof[G1El, G2El](ccs)
+		c.Witnesses[i] = stdgroth16.PlaceholderWitness[FR](ccs)
+	}
+	return c, nil
+}
+
+func (aggregateCurve[FR, G1El, G2El, GtEl]) assignment(proofs []groth16.Proof, publics []witness.Witness, digest []byte) (frontend.Circuit, error) {
+	c := &AggregateCircuit[FR, G1El, G2El, GtEl]{
+		Digest:    BytesToVariable(digest),
+		Proofs:    make([]stdgroth16.Proof[G1El, G2El], len(proofs)),
+		Witnesses: make([]stdgroth16.Witness[FR], len(publics)),
+	}
+	for i := range proofs {
+		p, err := stdgroth16.ValueOfProof[G1El, G2El](proofs[i])
+		if err != nil {
+			return nil, fmt.Errorf("proof %d: %v", i, err)
+		}
+		w, err := stdgroth16.ValueOfWitness[FR](publics[i])
+		if err != nil {
+			return nil, fmt.Errorf("witness %d: %v", i, err)
+		}
+		c.Proofs[i], c.Witnesses[i] = p, w
+	}
+	return c, nil
+}
+
+func (aggregateCurve[FR, G1El, G2El, GtEl]) limbs() (uint, uint) {
+	return emulated.GetEffectiveFieldParams[FR](AggregateCurve.ScalarField())
+}
+
+// aggregateDigest 与 AggregateCircuit 相同：按模拟算术的分段方式拆分每个公开输入后做 MiMC 哈希
+func aggregateDigest(ops aggregateOps, publics []witness.Witness) ([]byte, error) {
+	nbLimbs, nbBits := ops.limbs()
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), nbBits), big.NewInt(1))
+	var limbs [][]byte
+	for i, w := range publics {
+		values, err := witnessValues(w)
+		if err != nil {
+			return nil, fmt.Errorf("witness %d: %v", i, err)
+		}
+		for _, v := range values {
+			for j := uint(0); j < nbLimbs; j++ {
+				limb := new(big.Int).Rsh(v, j*nbBits)
+				limbs = append(limbs, limb.And(limb, mask).FillBytes(make([]byte, (nbBits+7)/8)))
+			}
+		}
+	}
+	return sum(Profile{Curve: AggregateCurve}.NewHash(), limbs...), nil
+}
+
+// witnessValues 返回 witness 中的域元素
+func witnessValues(w witness.Witness) ([]*big.Int, error) {
+	var values []*big.Int
+	switch vec := w.Vector().(type) {
+	case bn254fr.Vector:
+		for i := range vec {
+			values = append(values, vec[i].BigInt(new(big.Int)))
+		}
+	case bls12381fr.Vector:
+		for i := range vec {
+			values = append(values, vec[i].BigInt(new(big.Int)))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported witness vector %T", vec)
+	}
+	return values, nil
+}
+
+// aggregatePublic 与 AggregateCircuit 的公开部分结构相同，用于构造公开 witness
+type aggregatePublic struct {
+	Digest frontend.Variable `gnark:",public"`
+}
+
+// Define 不会被调用，aggregatePublic 只用于构造 witness
+func (c *aggregatePublic) Define(frontend.API) error { return nil }
+
+// Aggregator 将同一凭证电路的 N 个证明折叠为一个证明，验证方只需一次 Groth16 验证
+// An Aggregator folds N Groth16 proofs of one credential circuit into a
+// single Groth16 proof over AggregateCurve. Like Setup it is meant to run
+// once per credential setup and N; the outer keys are those of the
+// AggregateCircuit compiled with the inner verifying key.
+type Aggregator struct {
+	N     int
+	Inner *VerifyingKey // 内层凭证电路的验证密钥
+	CCS   constraint.ConstraintSystem
+	PK    Artifact
+	VK    Artifact
+}
+
+// NewAggregator 为 inner 的凭证电路编译聚合 n 个证明的电路并运行 groth16.Setup。
+// 内层必须是 Groth16，签名不在电路内验证且不输出 nullifier。
+// NewAggregator compiles the AggregateCircuit folding 'n' proofs made with
+// the setup 'inner' and runs the Groth16 setup for it. The inner setup must
+// use Groth16, leave the signature out of the circuit and not output
+// nullifiers.
+func NewAggregator(inner *SetupArtifacts, n int) (*Aggregator, error) {
+	if inner == nil {
+		return nil, errNoSetup
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of proofs %d", n)
+	}
+	innerVK := inner.VerifyingKey()
+	if innerVK.Backend != BackendGroth16 {
+		return nil, fmt.Errorf("aggregation of %v proofs is not supported", innerVK.Backend)
+	}
+	if inner.Manifest.Signature {
+		// SignedCircuit 的承诺使用原生哈希，与递归验证不兼容
+		return nil, errors.New("aggregation of proofs with an in-circuit signature is not supported")
+	}
+	if inner.Manifest.Nullifier {
+		// 每个 nullifier 需要验证方逐一对照自己的挑战并记录
+		return nil, errors.New("aggregation of nullifier proofs is not supported")
+	}
+	ops, err := aggregateOpsFor(innerVK.Curve)
+	if err != nil {
+		return nil, err
+	}
+	circuit, err := ops.placeholder(inner.CCS, innerVK.Key.(groth16.VerifyingKey), n)
+	if err != nil {
+		return nil, err
+	}
+	ccs, err := frontend.Compile(AggregateCurve.ScalarField(), r1cs.NewBuilder, circuit)
+	if err != nil {
+		return nil, fmt.Errorf("compile aggregate circuit: %v", err)
+	}
+	pk, vk, err := BackendGroth16.setup(ccs, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v setup: %v", BackendGroth16, err)
+	}
+	return &Aggregator{N: n, Inner: innerVK, CCS: ccs, PK: pk, VK: vk}, nil
+}
+
+// AggregateVerifyingKey 是验证聚合证明所需的全部密钥
+// An AggregateVerifyingKey is what a verifier needs to check aggregate
+// proofs: the outer key and the curve and number of the inner proofs.
+type AggregateVerifyingKey struct {
+	N     int
+	Inner ecc.ID
+	Key   *VerifyingKey
+}
+
+// VerifyingKey returns the key verifiers use with VerifyAggregate.
+func (a *Aggregator) VerifyingKey() *AggregateVerifyingKey {
+	return &AggregateVerifyingKey{
+		N:     a.N,
+		Inner: a.Inner.Curve,
+		Key:   &VerifyingKey{Backend: BackendGroth16, Curve: AggregateCurve, Key: a.VK},
+	}
+}
+
+// Aggregate 验证每个内层证明后生成聚合证明，proofs 与 publics 一一对应
+// Aggregate checks each of the N proofs made by Prove against its public
+// inputs and the trusted authority keys, and folds them into one proof over
+// AggregateCurve. The publics are what a verifier passes to VerifyAggregate,
+// in the same order.
+func (a *Aggregator) Aggregate(proofs []*ZKProof, publics []*PublicInputs, authorities []bls12381.G1Affine) (*ZKProof, error) {
+	if len(proofs) != a.N || len(publics) != a.N {
+		return nil, fmt.Errorf("aggregator folds %d proofs, got %d proofs and %d public inputs", a.N, len(proofs), len(publics))
+	}
+	ops, err := aggregateOpsFor(a.Inner.Curve)
+	if err != nil {
+		return nil, err
+	}
+	inner := make([]groth16.Proof, a.N)
+	witnesses := make([]witness.Witness, a.N)
+	for i := range proofs {
+		// 提前检查，避免在昂贵的证明生成中才发现错误
+		if err := Verify(a.Inner, proofs[i], publics[i], authorities); err != nil {
+			return nil, fmt.Errorf("proof %d: %v", i, err)
+		}
+		inner[i] = proofs[i].Proof.(groth16.Proof)
+		if witnesses[i], err = publics[i].Witness(a.Inner.Curve); err != nil {
+			return nil, err
+		}
+	}
+	digest, err := aggregateDigest(ops, witnesses)
+	if err != nil {
+		return nil, err
+	}
+	assignment, err := ops.assignment(inner, witnesses, digest)
+	if err != nil {
+		return nil, err
+	}
+	w, err := frontend.NewWitness(assignment, AggregateCurve.ScalarField())
+	if err != nil {
+		return nil, fmt.Errorf("build witness: %v", err)
+	}
+	proof, err := BackendGroth16.prove(a.CCS, a.PK, w)
+	if err != nil {
+		return nil, fmt.Errorf("%v prove: %v", BackendGroth16, err)
+	}
+	return &ZKProof{Backend: BackendGroth16, Curve: AggregateCurve, Proof: proof}, nil
+}
+
+// VerifyAggregate 验证聚合证明：检查各凭证的聚合公钥来自受信任的机构及其对 root 的聚合签名后，
+// 用一次验证检查全部内层证明
+// VerifyAggregate checks an aggregate proof made by Aggregator.Aggregate
+// against the public inputs of the inner proofs, in order. Besides the
+// aggregate signatures over the credential roots, whose aggregate public keys
+// must be the one of the trusted 'authorities' (see Verify), it runs a single
+// Groth16 verification for all of them.
+func VerifyAggregate(vk *AggregateVerifyingKey, proof *ZKProof, publics []*PublicInputs, authorities []bls12381.G1Affine) error {
+	if vk == nil || vk.Key == nil || proof == nil || proof.Proof == nil {
+		return errors.New("missing verifying key or proof")
+	}
+	if len(publics) != vk.N {
+		return fmt.Errorf("aggregate proof covers %d proofs, got %d public inputs", vk.N, len(publics))
+	}
+	if proof.Backend != vk.Key.Backend || proof.Curve != vk.Key.Curve {
+		return fmt.Errorf("%v proof on %s does not match the aggregate verifying key", proof.Backend, proof.Curve)
+	}
+	ops, err := aggregateOpsFor(vk.Inner)
+	if err != nil {
+		return err
+	}
+	witnesses := make([]witness.Witness, len(publics))
+	for i, public := range publics {
+		if public == nil || public.AggSig == nil {
+			return fmt.Errorf("public inputs %d: missing aggregate signature", i)
+		}
+		if err := checkAuthorities(public.AggPK, authorities); err != nil {
+			return fmt.Errorf("public inputs %d: %v", i, err)
+		}
+		if !VerifyAggregateSignature(public.AggPK, *public.AggSig, public.MerkleRoot) {
+			return fmt.Errorf("public inputs %d: invalid aggregate signature over the merkle root", i)
+		}
+		if witnesses[i], err = public.Witness(vk.Inner); err != nil {
+			return fmt.Errorf("build public witness: %v", err)
+		}
+	}
+	digest, err := aggregateDigest(ops, witnesses)
+	if err != nil {
+		return err
+	}
+	w, err := frontend.NewWitness(&aggregatePublic{Digest: BytesToVariable(digest)}, AggregateCurve.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return fmt.Errorf("build public witness: %v", err)
+	}
+	if err := proof.Backend.verify(proof.Proof, vk.Key.Key, w); err != nil {
+		return fmt.Errorf("%v verify: %v", proof.Backend, err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"testing"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// testInnerProofs 返回 n 个不同凭证树上的证明、公开输入以及签发凭证的机构公钥
+func testInnerProofs(t *testing.T, setup *SetupArtifacts, ct CredentialType, n int) ([]*ZKProof, []*PublicInputs, []bls12381.G1Affine) {
+	sks, pks := testAuthorities(t, 3)
+	var proofs []*ZKProof
+	var publics []*PublicInputs
+	for i := 0; i < n; i++ {
+		tree, err := ct.NewTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = tree.SetIndex(uint64(i))
+		data := testLeaves(4 + i)
+		for _, d := range data[i:] {
+			tree.Push(d)
+		}
+		root, proofSet, proofIndex, numLeaves := tree.Prove()
+		aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+		proof, public, err := Prove(setup, aggPK, aggSig, data[2*i], proofSet, proofIndex, numLeaves, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proofs = append(proofs, proof)
+		publics = append(publics, public)
+	}
+	return proofs, publics, pks
+}
+
+func TestAggregateCircuit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("emulated pairing is slow")
+	}
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	setup, err := Setup(ct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, publics, _ := testInnerProofs(t, setup, ct, 2)
+
+	ops, err := aggregateOpsFor(ct.Profile().CurveID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 求解会修改电路，每次检查使用新的 placeholder
+	solve := func(assignment frontend.Circuit) error {
+		circuit, err := ops.placeholder(setup.CCS, setup.VK.(groth16.VerifyingKey), len(proofs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return test.IsSolved(circuit, assignment, AggregateCurve.ScalarField())
+	}
+	inner := make([]groth16.Proof, len(proofs))
+	witnesses := make([]witness.Witness, len(proofs))
+	for i := range proofs {
+		inner[i] = proofs[i].Proof.(groth16.Proof)
+		if witnesses[i], err = publics[i].Witness(ct.Profile().CurveID()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	digest, err := aggregateDigest(ops, witnesses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment, err := ops.assignment(inner, witnesses, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solve(assignment); err != nil {
+		t.Fatalf("aggregate rejected: %v", err)
+	}
+
+	// 摘要绑定内层公开输入的顺序
+	swapped, _ := aggregateDigest(ops, []witness.Witness{witnesses[1], witnesses[0]})
+	assignment, _ = ops.assignment(inner, witnesses, swapped)
+	if err := solve(assignment); err == nil {
+		t.Fatal("aggregate accepted with another digest")
+	}
+	// 内层证明与公开输入不对应时拒绝
+	assignment, _ = ops.assignment([]groth16.Proof{inner[1], inner[0]}, witnesses, digest)
+	if err := solve(assignment); err == nil {
+		t.Fatal("aggregate accepted with swapped proofs")
+	}
+}
+
+func TestNewAggregator(t *testing.T) {
+	ct := CredentialType{Name: "test"}
+	setup, err := setupBackend(BackendPlonk, ct, 2, UnsafeSRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAggregator(setup, 2); err == nil {
+		t.Fatal("aggregator built for plonk proofs")
+	}
+	setup, err = Setup(ct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAggregator(setup, 0); err == nil {
+		t.Fatal("aggregator built for no proofs")
+	}
+	signed := *setup
+	signed.Manifest.Signature = true
+	if _, err := NewAggregator(&signed, 2); err == nil {
+		t.Fatal("aggregator built for signed credentials")
+	}
+
+	proofs, publics, authorities := testInnerProofs(t, setup, ct, 2)
+	ops, _ := aggregateOpsFor(ct.Profile().CurveID())
+	digest := func(publics []*PublicInputs) []byte {
+		var ws []witness.Witness
+		for _, p := range publics {
+			w, err := p.Witness(ct.Profile().CurveID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws = append(ws, w)
+		}
+		d, err := aggregateDigest(ops, ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	if string(digest(publics)) == string(digest([]*PublicInputs{publics[1], publics[0]})) {
+		t.Fatal("digest does not depend on the order of the proofs")
+	}
+
+	// 聚合前拒绝无效的内层证明，无需编译聚合电路
+	a := &Aggregator{N: 2, Inner: setup.VerifyingKey()}
+	if _, err := a.Aggregate(proofs, []*PublicInputs{publics[1], publics[0]}, authorities); err == nil {
+		t.Fatal("aggregated proofs that do not match their public inputs")
+	}
+	if _, err := a.Aggregate(proofs[:1], publics[:1], authorities); err == nil {
+		t.Fatal("aggregated a wrong number of proofs")
+	}
+	// 其他机构签发的凭证
+	_, foreign := testAuthorities(t, 3)
+	if _, err := a.Aggregate(proofs, publics, foreign); err == nil {
+		t.Fatal("aggregated proofs signed by untrusted authorities")
+	}
+	vk := &AggregateVerifyingKey{N: 2, Inner: ct.Profile().CurveID(), Key: &VerifyingKey{Curve: AggregateCurve}}
+	if err := VerifyAggregate(vk, proofs[0], publics[:1], authorities); err == nil {
+		t.Fatal("aggregate verified with a wrong number of public inputs")
+	}
+}
+
+// 端到端：两个内层证明聚合为一个证明，验证方只需聚合验证密钥与各自的公开输入
+func TestAggregateEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("aggregate setup and proving are slow")
+	}
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	setup, err := Setup(ct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, publics, authorities := testInnerProofs(t, setup, ct, 2)
+	a, err := NewAggregator(setup, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := a.Aggregate(proofs, publics, authorities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vk := a.VerifyingKey()
+	if err := VerifyAggregate(vk, proof, publics, authorities); err != nil {
+		t.Fatalf("aggregate proof rejected: %v", err)
+	}
+	if err := VerifyAggregate(vk, proof, []*PublicInputs{publics[1], publics[0]}, authorities); err == nil {
+		t.Fatal("aggregate proof accepted with swapped public inputs")
+	}
+	// 其他机构的聚合公钥
+	_, foreign := testAuthorities(t, 3)
+	if err := VerifyAggregate(vk, proof, publics, foreign); err == nil {
+		t.Fatal("aggregate proof accepted from untrusted authorities")
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+)
+
+// AllowList 是公开的允许值集合（如欧盟国家、{doctor, nurse}），以 merkle root 承诺。
+// 叶子数按重复最后一个值补齐为 2 的幂，所有成员证明的深度相同。
+// An AllowList is a public set of attribute values, e.g. the EU countries,
+// committed as the root of a tree hashed like the credentials of its type.
+// The list is padded to a power of two by repeating its last value so every
+// membership path has the same depth.
+type AllowList struct {
+	ct     CredentialType
+	values [][]byte
+	tree   *BatchTree
+}
+
+// NewAllowList 为凭证类型 ct 创建允许值集合，每个值必须是单个域元素的大端序编码
+// NewAllowList commits to 'values' for credentials of type 'ct'. Each value
+// is leaf data as pushed in credential trees and must encode a single field
+// element of the type's profile.
+func NewAllowList(ct CredentialType, values [][]byte) (*AllowList, error) {
+	if err := ct.Validate(); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("empty allow-list")
+	}
+	for i, v := range values {
+		if err := checkAttributeElement(ct.Profile(), v); err != nil {
+			return nil, fmt.Errorf("value %d: %v", i, err)
+		}
+	}
+	padded := append([][]byte(nil), values...)
+	for len(padded)&(len(padded)-1) != 0 {
+		padded = append(padded, values[len(values)-1])
+	}
+	tree, err := BuildBatchTree(ct.Profile().NewHash, ct.Mode, padded, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &AllowList{ct: ct, values: append([][]byte(nil), values...), tree: tree}, nil
+}
+
+// checkAttributeElement 检查属性数据在电路内可以表示为同一个域元素
+func checkAttributeElement(p Profile, data []byte) error {
+	if len(data) == 0 || len(data) > 32 {
+		return fmt.Errorf("attribute of %d bytes is not a field element", len(data))
+	}
+	if new(big.Int).SetBytes(data).Cmp(p.Field()) >= 0 {
+		return errors.New("attribute exceeds the field modulus")
+	}
+	return nil
+}
+
+// Root returns the commitment to the list.
+func (a *AllowList) Root() []byte {
+	return a.tree.Root()
+}
+
+// Depth returns the depth of the membership paths.
+func (a *AllowList) Depth() int {
+	depth := 0
+	for n := a.tree.Len(); n > 1; n >>= 1 {
+		depth++
+	}
+	return depth
+}
+
+// Index returns the position of 'value' in the list.
+func (a *AllowList) Index(value []byte) (uint64, bool) {
+	for i, v := range a.values {
+		if bytes.Equal(v, value) {
+			return uint64(i), true
+		}
+	}
+	return 0, false
+}
+
+// SetCircuit 证明凭证树中某个属性属于公开的允许值集合，属性值本身不公开。
+// 属性值在电路内同时计算凭证树与集合树的叶子哈希，从而与两个 root 绑定。
+// A SetCircuit proves that the attribute committed in a leaf of the credential
+// tree with root MerkleRoot is a member of the AllowList with root SetRoot.
+// Value is private and hashed in-circuit into both trees.
+type SetCircuit struct {
+	MerkleRoot frontend.Variable `gnark:",public"`
+	SetRoot    frontend.Variable `gnark:",public"`
+
+	Value     frontend.Variable   // 属性值
+	Path      []frontend.Variable // 凭证树中的 Merkle 路径，按最大深度补 0
+	Helper    []frontend.Variable // 方向位 (0=左, 1=右)，按最大深度补 0
+	Depth     frontend.Variable   // 凭证树路径的实际深度，见 MerkleWitness
+	SetPath   []frontend.Variable // 集合树中的 Merkle 路径
+	SetHelper []frontend.Variable
+
+	Mode  HashMode `gnark:"-"`
+	Hash  HashFunc `gnark:"-"`
+	Curve ecc.ID   `gnark:"-"`
+}
+
+// SetCircuit 返回凭证类型 c 上的 SetCircuit，depth 为凭证树的最大深度，setDepth 为 AllowList.Depth
+// SetCircuit returns a SetCircuit placeholder for the type, for credential
+// proofs of depth up to 'depth'.
+func (c CredentialType) SetCircuit(depth, setDepth int) SetCircuit {
+	return SetCircuit{
+		Path:      make([]frontend.Variable, depth),
+		Helper:    make([]frontend.Variable, depth),
+		SetPath:   make([]frontend.Variable, setDepth),
+		SetHelper: make([]frontend.Variable, setDepth),
+		Mode:      c.Mode,
+		Hash:      c.Hash,
+		Curve:     c.Curve,
+	}
+}
+
+func (c *SetCircuit) Define(api frontend.API) error {
+	p := Profile{Curve: c.Curve, Hash: c.Hash}
+	leaf, err := circuitLeafSum(api, p, c.Mode, c.Value)
+	if err != nil {
+		return err
+	}
+	root, err := circuitPaddedPathRoot(api, p, c.Mode, leaf, c.Path, c.Helper, c.Depth)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(root, c.MerkleRoot)
+
+	// 集合树与凭证树的哈希方式相同，叶子哈希可以复用
+	setRoot, err := circuitPathRoot(api, p, c.Mode, leaf, c.SetPath, c.SetHelper)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(setRoot, c.SetRoot)
+	return nil
+}
+
+// Assign 由原生 merkle 证明生成 SetCircuit 的赋值，maxDepth 为电路中凭证树的最大深度，属性不在集合中时返回错误
+// Assign returns the assignment of the SetCircuit of credential depth
+// 'maxDepth' for the leaf proofSet[0] of a credential tree of 'numLeaves'
+// leaves built with the list's type, as returned by Tree.Prove.
+func (a *AllowList) Assign(root []byte, proofSet [][]byte, proofIndex, numLeaves uint64, maxDepth int) (*SetCircuit, error) {
+	if len(proofSet) == 0 {
+		return nil, errors.New("empty merkle proof")
+	}
+	value := proofSet[0]
+	if err := checkAttributeElement(a.ct.Profile(), value); err != nil {
+		return nil, err
+	}
+	index, ok := a.Index(value)
+	if !ok {
+		return nil, errors.New("attribute is not in the allow-list")
+	}
+	mw, err := a.ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	setProof, err := a.tree.Prove(index)
+	if err != nil {
+		return nil, err
+	}
+	return &SetCircuit{
+		MerkleRoot: BytesToVariable(root),
+		SetRoot:    BytesToVariable(a.Root()),
+		Value:      BytesToVariable(value),
+		Path:       mw.Path,
+		Helper:     mw.Helper,
+		Depth:      mw.Depth,
+		SetPath:    BytesArrayToVariables(setProof.Path),
+		SetHelper:  IndexToHelper(index, a.Depth()),
+	}, nil
+}
+
+// Witness 返回验证方使用的公开 witness，只包含凭证 root 与集合 root
+// Witness returns the public witness of SetCircuit for the list and the
+// credential tree with root 'root'.
+func (a *AllowList) Witness(root []byte, curve ecc.ID) (witness.Witness, error) {
+	assignment := SetCircuit{
+		MerkleRoot: BytesToVariable(root),
+		SetRoot:    BytesToVariable(a.Root()),
+	}
+	return frontend.NewWitness(&assignment, curve.ScalarField(), frontend.PublicOnly())
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+func TestSetCircuit(t *testing.T) {
+	roles := [][]byte{[]byte("doctor"), []byte("nurse"), []byte("pharmacist")}
+	for _, mode := range []HashMode{HashModePlain, HashModeRFC6962} {
+		ct := CredentialType{Name: "test", Mode: mode}
+		list, err := NewAllowList(ct, roles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list.Depth() != 2 {
+			t.Fatalf("unexpected depth %d", list.Depth())
+		}
+
+		const maxDepth = 3
+		credential := func(role string, n, index uint64) ([]byte, [][]byte, uint64, uint64) {
+			tree, err := ct.NewTree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = tree.SetIndex(index)
+			leaves := testLeaves(int(n))
+			leaves[index] = []byte(role)
+			for _, d := range leaves {
+				tree.Push(d)
+			}
+			return tree.Prove()
+		}
+		circuit := ct.SetCircuit(maxDepth, list.Depth())
+
+		// 叶子数不是 2 的幂时最后的叶子落单，路径较短
+		for _, pos := range []struct{ n, index uint64 }{{4, 1}, {5, 4}, {6, 4}, {6, 5}, {7, 6}} {
+			root, proofSet, proofIndex, numLeaves := credential("pharmacist", pos.n, pos.index)
+			assignment, err := list.Assign(root, proofSet, proofIndex, numLeaves, maxDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("%v n=%d index=%d: member rejected: %v", mode, pos.n, pos.index, err)
+			}
+		}
+
+		root, proofSet, proofIndex, numLeaves := credential("admin", 4, 1)
+		if _, err := list.Assign(root, proofSet, proofIndex, numLeaves, maxDepth); err == nil {
+			t.Fatalf("%v: assignment built for a non member", mode)
+		}
+		// 使用集合中其他成员的路径，电路同样拒绝
+		forged, _ := list.Assign(root, append([][]byte{[]byte("nurse")}, proofSet[1:]...), proofIndex, numLeaves, maxDepth)
+		forged.Value = BytesToVariable([]byte("admin"))
+		if err := test.IsSolved(&circuit, forged, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: non member accepted", mode)
+		}
+	}
+
+	if _, err := NewAllowList(CredentialType{}, [][]byte{make([]byte, 33)}); err == nil {
+		t.Fatal("value larger than a field element accepted")
+	}
+}
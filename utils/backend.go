@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381kzg "github.com/consensys/gnark-crypto/ecc/bls12-381/kzg"
+	bn254kzg "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+// Backend 是证明系统的后端。Groth16 需要针对每个电路的可信设置，
+// PLONK 使用通用的 KZG SRS，电路变化后无需重新进行仪式。
+// A Backend is the proving system used for a circuit. Groth16 needs a
+// circuit specific trusted setup; PLONK only needs a universal KZG SRS, so
+// circuits can change without a new ceremony. The zero value is Groth16.
+type Backend uint8
+
+const (
+	// BackendGroth16 compiles circuits to R1CS and proves with Groth16.
+	BackendGroth16 Backend = iota
+	// BackendPlonk compiles circuits to sparse R1CS and proves with PLONK
+	// over a KZG commitment.
+	BackendPlonk
+)
+
+// String returns the name of the backend.
+func (b Backend) String() string {
+	switch b {
+	case BackendGroth16:
+		return "groth16"
+	case BackendPlonk:
+		return "plonk"
+	default:
+		return fmt.Sprintf("Backend(%d)", uint8(b))
+	}
+}
+
+// ParseBackend returns the backend with the given name. The empty string is
+// Groth16.
+func ParseBackend(name string) (Backend, error) {
+	switch name {
+	case "", "groth16":
+		return BackendGroth16, nil
+	case "plonk":
+		return BackendPlonk, nil
+	default:
+		return 0, fmt.Errorf("unknown backend %q", name)
+	}
+}
+
+// Valid returns true if b is a known backend.
+func (b Backend) Valid() bool {
+	return b == BackendGroth16 || b == BackendPlonk
+}
+
+// Artifact 是各后端的密钥与证明共同的序列化接口
+// An Artifact is a proving key, verifying key or proof of either backend.
+type Artifact interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// newBuilder 返回后端使用的约束系统构建器
+func (b Backend) newBuilder() frontend.NewBuilder {
+	if b == BackendPlonk {
+		return scs.NewBuilder
+	}
+	return r1cs.NewBuilder
+}
+
+// constraintKind 是约束系统产物的类型名，同时用作文件扩展名
+func (b Backend) constraintKind() string {
+	if b == BackendPlonk {
+		return "scs"
+	}
+	return "r1cs"
+}
+
+func (b Backend) newCS(curve ecc.ID) constraint.ConstraintSystem {
+	if b == BackendPlonk {
+		return plonk.NewCS(curve)
+	}
+	return groth16.NewCS(curve)
+}
+
+func (b Backend) newProvingKey(curve ecc.ID) Artifact {
+	if b == BackendPlonk {
+		return plonk.NewProvingKey(curve)
+	}
+	return groth16.NewProvingKey(curve)
+}
+
+func (b Backend) newVerifyingKey(curve ecc.ID) Artifact {
+	if b == BackendPlonk {
+		return plonk.NewVerifyingKey(curve)
+	}
+	return groth16.NewVerifyingKey(curve)
+}
+
+func (b Backend) newProof(curve ecc.ID) Artifact {
+	if b == BackendPlonk {
+		return plonk.NewProof(curve)
+	}
+	return groth16.NewProof(curve)
+}
+
+// setup 运行后端的设置，PLONK 需要 srs
+func (b Backend) setup(ccs constraint.ConstraintSystem, srs SRSProvider) (Artifact, Artifact, error) {
+	if b == BackendGroth16 {
+		return groth16.Setup(ccs)
+	}
+	if srs == nil {
+		return nil, nil, fmt.Errorf("%v setup needs a KZG SRS", b)
+	}
+	canonical, lagrange, err := srs(ccs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kzg srs: %v", err)
+	}
+	return plonk.Setup(ccs, canonical, lagrange)
+}
+
+func (b Backend) prove(ccs constraint.ConstraintSystem, pk Artifact, w witness.Witness) (Artifact, error) {
+	if b == BackendPlonk {
+		return plonk.Prove(ccs, pk.(plonk.ProvingKey), w)
+	}
+	return groth16.Prove(ccs, pk.(groth16.ProvingKey), w)
+}
+
+func (b Backend) verify(proof, vk Artifact, w witness.Witness) error {
+	switch b {
+	case BackendGroth16:
+		p, ok1 := proof.(groth16.Proof)
+		k, ok2 := vk.(groth16.VerifyingKey)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("not a %v proof and verifying key", b)
+		}
+		return groth16.Verify(p, k, w)
+	case BackendPlonk:
+		p, ok1 := proof.(plonk.Proof)
+		k, ok2 := vk.(plonk.VerifyingKey)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("not a %v proof and verifying key", b)
+		}
+		return plonk.Verify(p, k, w)
+	default:
+		return fmt.Errorf("unknown backend %v", b)
+	}
+}
+
+// SRSProvider 返回电路所需的 KZG SRS，分别为 canonical 与 lagrange 形式
+// An SRSProvider returns the KZG SRS, in canonical and Lagrange form, large
+// enough for the constraint system 'ccs'.
+type SRSProvider func(ccs constraint.ConstraintSystem) (canonical, lagrange kzg.SRS, err error)
+
+// UnsafeSRS 在本地生成 SRS。生成者知道其陷门，只能用于测试与开发
+// UnsafeSRS generates an SRS locally. Whoever runs it knows the trapdoor and
+// can forge proofs, so it is only meant for tests and development.
+func UnsafeSRS(ccs constraint.ConstraintSystem) (kzg.SRS, kzg.SRS, error) {
+	return unsafekzg.NewSRS(ccs)
+}
+
+// FileSRS 从文件读取 canonical 形式的 SRS（例如公开仪式的输出），并转换为所需大小的 lagrange 形式
+// FileSRS returns an SRSProvider reading a canonical SRS, e.g. the output of
+// a public ceremony, from 'path' and computing its Lagrange form.
+func FileSRS(path string) SRSProvider {
+	return func(ccs constraint.ConstraintSystem) (kzg.SRS, kzg.SRS, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		curve, err := fieldCurve(ccs.Field())
+		if err != nil {
+			return nil, nil, err
+		}
+		canonical := kzg.NewSRS(curve)
+		if _, err := canonical.ReadFrom(bytes.NewReader(data)); err != nil {
+			return nil, nil, fmt.Errorf("read %s: %v", path, err)
+		}
+		// 与 unsafekzg 相同的大小规则
+		size := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints() + ccs.GetNbPublicVariables()))
+		lagrange, err := toLagrange(canonical, int(size))
+		if err != nil {
+			return nil, nil, err
+		}
+		return canonical, lagrange, nil
+	}
+}
+
+// toLagrange 将 canonical 形式 SRS 的前 size 个点转换为 lagrange 形式
+func toLagrange(canonical kzg.SRS, size int) (kzg.SRS, error) {
+	switch srs := canonical.(type) {
+	case *bn254kzg.SRS:
+		if len(srs.Pk.G1) < size+3 {
+			return nil, fmt.Errorf("srs of size %d too small, need %d", len(srs.Pk.G1), size+3)
+		}
+		g1, err := bn254kzg.ToLagrangeG1(srs.Pk.G1[:size])
+		if err != nil {
+			return nil, err
+		}
+		return &bn254kzg.SRS{Pk: bn254kzg.ProvingKey{G1: g1}, Vk: srs.Vk}, nil
+	case *bls12381kzg.SRS:
+		if len(srs.Pk.G1) < size+3 {
+			return nil, fmt.Errorf("srs of size %d too small, need %d", len(srs.Pk.G1), size+3)
+		}
+		g1, err := bls12381kzg.ToLagrangeG1(srs.Pk.G1[:size])
+		if err != nil {
+			return nil, err
+		}
+		return &bls12381kzg.SRS{Pk: bls12381kzg.ProvingKey{G1: g1}, Vk: srs.Vk}, nil
+	default:
+		return nil, fmt.Errorf("unsupported srs %T", canonical)
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// 从文件读取的 SRS 与直接生成的 SRS 应得到相同的 PLONK 密钥
+func TestFileSRS(t *testing.T) {
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	ccs, err := frontend.Compile(ct.Profile().Field(), BackendPlonk.newBuilder(), ct.Placeholder(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, _, err := UnsafeSRS(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "kzg.srs")
+	var buf bytes.Buffer
+	if _, err := canonical.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := SetupPlonk(ct, 2, UnsafeSRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SetupPlonk(ct, 2, FileSRS(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantVK, gotVK bytes.Buffer
+	if _, err := want.VK.WriteTo(&wantVK); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.VK.WriteTo(&gotVK); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wantVK.Bytes(), gotVK.Bytes()) {
+		t.Fatal("verifying keys differ")
+	}
+
+	// 电路需要的 SRS 比文件中的更大时失败
+	if _, err := SetupPlonk(ct, 6, FileSRS(path)); err == nil {
+		t.Fatal("setup with a too small SRS")
+	}
+	if _, err := SetupPlonk(ct, 2, nil); err == nil {
+		t.Fatal("plonk setup without SRS")
+	}
+}
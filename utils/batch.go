@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"runtime"
+	"sync"
+)
+
+// minBatchChunk 是每个 goroutine 至少处理的哈希次数，层较小时直接顺序计算
+const minBatchChunk = 256
+
+// BatchTree 一次性构建整批叶子的 merkle 树，每一层的哈希在多个 goroutine 中并行计算。
+// 自底向上两两合并、奇数个时最后一个节点直接上移，得到的树与 Tree 的形状完全相同。
+// A BatchTree is a Merkle tree built at once from a whole batch of leaves.
+// Levels are hashed bottom-up in parallel, each node being the sum of two
+// adjacent nodes of the level below and an odd node being promoted as is.
+// This yields the same shape, root and proofs as Tree. All levels are kept in
+// memory so that proofs can be built for any leaf.
+type BatchTree struct {
+	mode   HashMode
+	cached bool
+	leaves [][]byte
+	levels [][][]byte // levels[0] 为叶子哈希，最后一层为 root
+}
+
+// BuildBatchTree 构建批量 merkle 树。newHash 为每个 goroutine 创建独立的哈希实例，
+// cached 为 true 时叶子数据即为叶子哈希，与 New 创建的缓存树一致，否则与 New1 一致。
+// BuildBatchTree builds the tree of 'leaves' hashed in 'mode' using up to
+// 'workers' goroutines, or GOMAXPROCS when workers <= 0. newHash must return a
+// fresh hash for every call since hash.Hash is not safe for concurrent use.
+// When cached is true the leaves are already leaf sums, as in a tree created
+// by New; otherwise they are hashed with mode.LeafSum, as in New1.
+func BuildBatchTree(newHash func() hash.Hash, mode HashMode, leaves [][]byte, cached bool, workers int) (*BatchTree, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("cannot build a tree without leaves")
+	}
+	if !mode.Valid() {
+		return nil, fmt.Errorf("unknown hash mode %v", mode)
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	hashers := make([]hash.Hash, workers)
+	for i := range hashers {
+		hashers[i] = newHash()
+	}
+
+	sums := make([][]byte, len(leaves))
+	if cached {
+		copy(sums, leaves)
+	} else {
+		parallelHash(hashers, len(sums), func(h hash.Hash, i int) {
+			sums[i] = mode.LeafSum(h, leaves[i])
+		})
+	}
+
+	levels := [][][]byte{sums}
+	for len(sums) > 1 {
+		next := make([][]byte, (len(sums)+1)/2)
+		prev := sums
+		parallelHash(hashers, len(prev)/2, func(h hash.Hash, i int) {
+			next[i] = mode.NodeSum(h, prev[2*i], prev[2*i+1])
+		})
+		if len(prev)%2 == 1 {
+			next[len(next)-1] = prev[len(prev)-1]
+		}
+		levels = append(levels, next)
+		sums = next
+	}
+	return &BatchTree{
+		mode:   mode,
+		cached: cached,
+		leaves: append([][]byte(nil), leaves...),
+		levels: levels,
+	}, nil
+}
+
+// parallelHash 将 [0, n) 划分给各个哈希实例并行执行 fn
+func parallelHash(hashers []hash.Hash, n int, fn func(h hash.Hash, i int)) {
+	workers := len(hashers)
+	if limit := (n + minBatchChunk - 1) / minBatchChunk; limit < workers {
+		workers = limit
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(hashers[0], i)
+		}
+		return
+	}
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := w*chunk, (w+1)*chunk
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(h hash.Hash) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				fn(h, i)
+			}
+		}(hashers[w])
+	}
+	wg.Wait()
+}
+
+// Len returns the number of leaves in the tree.
+func (b *BatchTree) Len() int {
+	return len(b.leaves)
+}
+
+// Root returns the Merkle root of the tree.
+func (b *BatchTree) Root() []byte {
+	return b.levels[len(b.levels)-1][0]
+}
+
+// Prove 返回第 index 个叶子的证明，其 Path 与 Tree.Prove 生成的证明相同
+// Prove returns a proof for the leaf at 'index'. Its path is the one Tree
+// would produce, and LeafHashed is set for cached trees.
+func (b *BatchTree) Prove(index uint64) (*Proof, error) {
+	if index >= uint64(len(b.leaves)) {
+		return nil, fmt.Errorf("index %d out of range for %d leaves", index, len(b.leaves))
+	}
+	proof := &Proof{
+		Index:      index,
+		NumLeaves:  uint64(len(b.leaves)),
+		Mode:       b.mode,
+		LeafHashed: b.cached,
+		Leaf:       append([]byte(nil), b.leaves[index]...),
+	}
+	i := index
+	for _, level := range b.levels[:len(b.levels)-1] {
+		// 上移的孤立节点在这一层没有兄弟节点
+		if sibling := i ^ 1; sibling < uint64(len(level)) {
+			proof.Path = append(proof.Path, level[sibling])
+		}
+		i >>= 1
+	}
+	return proof, nil
+}
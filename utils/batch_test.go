@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+)
+
+// fieldLeaves 返回 n 个 32 字节的规范域元素，可同时作为 MiMC 与 SHA-256 缓存树的叶子
+func fieldLeaves(n int) [][]byte {
+	data := make([][]byte, n)
+	for i := range data {
+		var e fr.Element
+		e.SetString(fmt.Sprintf("%d%d", i, i*i))
+		b := e.Bytes()
+		data[i] = b[:]
+	}
+	return data
+}
+
+func newMiMC() hash.Hash { return mimc.NewMiMC() }
+
+func TestBatchTree(t *testing.T) {
+	h := mimc.NewMiMC()
+	for _, n := range []int{1, 2, 3, 7, 8, 13, 600} {
+		data := testLeaves(n)
+		hashed := fieldLeaves(n)
+		for _, mode := range []HashMode{HashModePlain, HashModeRFC6962, HashModeDomainTag} {
+			tree := New1(h)
+			cached := New(h)
+			if err := tree.SetHashMode(mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := cached.SetHashMode(mode); err != nil {
+				t.Fatal(err)
+			}
+			for i := range data {
+				tree.Push1(data[i])
+				cached.Push(hashed[i])
+			}
+
+			batch, err := BuildBatchTree(newMiMC, mode, data, false, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(batch.Root(), tree.Root1()) {
+				t.Fatalf("n=%d %v: batch root differs from Tree", n, mode)
+			}
+			cachedBatch, err := BuildBatchTree(newMiMC, mode, hashed, true, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(cachedBatch.Root(), cached.Root()) {
+				t.Fatalf("n=%d %v: cached batch root differs from Tree", n, mode)
+			}
+
+			for _, i := range []int{0, n / 2, n - 1} {
+				proof, err := batch.Prove(uint64(i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !proof.Verify(h, batch.Root()) {
+					t.Fatalf("n=%d %v i=%d: batch proof rejected", n, mode, i)
+				}
+				want, _, err := cached.BuildProof(uint64(i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := cachedBatch.Prove(uint64(i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got.Path) != len(want.Path) {
+					t.Fatalf("n=%d %v i=%d: proof length differs from Tree", n, mode, i)
+				}
+				for j := range got.Path {
+					if !bytes.Equal(got.Path[j], want.Path[j]) {
+						t.Fatalf("n=%d %v i=%d: proof differs from Tree", n, mode, i)
+					}
+				}
+			}
+		}
+	}
+	if _, err := BuildBatchTree(newMiMC, HashModePlain, nil, false, 0); err == nil {
+		t.Fatal("empty batch accepted")
+	}
+}
+
+const benchLeaves = 1 << 14
+
+func benchmarkTreePush(b *testing.B, h hash.Hash) {
+	data := fieldLeaves(benchLeaves)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Push1 会打印每个叶子，基准测试使用同样哈希叶子的 Push
+		tree := New1(h)
+		for _, d := range data {
+			tree.Push(d)
+		}
+		tree.Root()
+	}
+}
+
+func benchmarkBatchTree(b *testing.B, newHash func() hash.Hash) {
+	data := fieldLeaves(benchLeaves)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildBatchTree(newHash, HashModePlain, data, false, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTreePushMiMC(b *testing.B)    { benchmarkTreePush(b, mimc.NewMiMC()) }
+func BenchmarkBatchTreeMiMC(b *testing.B)   { benchmarkBatchTree(b, newMiMC) }
+func BenchmarkTreePushSHA256(b *testing.B)  { benchmarkTreePush(b, sha256.New()) }
+func BenchmarkBatchTreeSHA256(b *testing.B) { benchmarkBatchTree(b, sha256.New) }
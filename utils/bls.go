@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	blsfr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// blsDST 是哈希到 G2 时使用的域分离标签，与客户端签名保持一致
+var blsDST = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_NUL_")
+
+// Sign 使用 BLS 私钥对消息签名：σ = sk * H(m)
+func Sign(sk blsfr.Element, message []byte) (bls12381.G2Affine, error) {
+	var signature bls12381.G2Affine
+	hashToG2, err := bls12381.HashToG2(message, blsDST)
+	if err != nil {
+		return signature, err
+	}
+	skBytes := sk.Bytes()
+	signature.ScalarMultiplication(&hashToG2, new(big.Int).SetBytes(skBytes[:]))
+	return signature, nil
+}
+
+// 聚合公钥和签名
+func Aggregate(pks []bls12381.G1Affine, signatures []bls12381.G2Affine) (bls12381.G1Affine, bls12381.G2Affine) {
+	// 聚合公钥 (apk = Σ pk_i)
+	var aggPublicKey bls12381.G1Jac
+	for _, signer := range pks {
+		var pkJac bls12381.G1Jac
+		pkJac.FromAffine(&signer)
+		aggPublicKey.AddAssign(&pkJac)
+	}
+
+	// 聚合签名 (asig = Σ σ_i)
+	var aggSignature bls12381.G2Jac
+	for _, sig := range signatures {
+		var sigJac bls12381.G2Jac
+		sigJac.FromAffine(&sig)
+		aggSignature.AddAssign(&sigJac)
+	}
+
+	// 转回仿射坐标
+	var aggPKAff bls12381.G1Affine
+	aggPKAff.FromJacobian(&aggPublicKey)
+
+	var aggSigAff bls12381.G2Affine
+	aggSigAff.FromJacobian(&aggSignature)
+
+	return aggPKAff, aggSigAff
+}
+
+// checkAuthorities 检查聚合公钥恰好是受信任机构公钥之和。受信任公钥由验证方提供，不能取自证明本身
+// checkAuthorities reports an error unless aggPK is the aggregate of the
+// trusted authority keys. The keys are supplied by the verifier, never taken
+// from the proof, and must come with a proof of possession, otherwise
+// aggregating public keys allows rogue key attacks.
+func checkAuthorities(aggPK bls12381.G1Affine, authorities []bls12381.G1Affine) error {
+	if len(authorities) == 0 {
+		return errors.New("no trusted authority keys")
+	}
+	trusted, _ := Aggregate(authorities, nil)
+	if !trusted.Equal(&aggPK) {
+		return errors.New("aggregate public key is not the one of the trusted authorities")
+	}
+	return nil
+}
+
+// SaveAuthorities 将机构公钥以压缩编码的十六进制 JSON 数组写入文件
+// SaveAuthorities writes the authority public keys to path as a JSON array
+// of hex encoded compressed points.
+func SaveAuthorities(path string, pks []bls12381.G1Affine) error {
+	encoded := make([]string, len(pks))
+	for i := range pks {
+		b := pks[i].Bytes()
+		encoded[i] = hex.EncodeToString(b[:])
+	}
+	data, err := json.MarshalIndent(encoded, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadAuthorities 读取 SaveAuthorities 写入的机构公钥，验证方据此检查证明中的聚合公钥
+// LoadAuthorities reads the authority public keys written by SaveAuthorities.
+// Verifiers pass them to Verify to pin the aggregate public key of a proof.
+func LoadAuthorities(path string) ([]bls12381.G1Affine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var encoded []string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	pks := make([]bls12381.G1Affine, len(encoded))
+	for i, s := range encoded {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: authority %d: %v", path, i, err)
+		}
+		if _, err := pks[i].SetBytes(b); err != nil {
+			return nil, fmt.Errorf("%s: authority %d: %v", path, i, err)
+		}
+	}
+	return pks, nil
+}
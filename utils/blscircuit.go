@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/emulated/fields_bls12381"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_bls12381"
+	"github.com/consensys/gnark/std/hash/sha2"
+	"github.com/consensys/gnark/std/math/bits"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/std/math/uints"
+)
+
+// AggregateSignature 在电路内验证机构对消息的 BLS12-381 聚合签名。聚合公钥为公开输入，
+// 签名为私有输入，验证方无需看到签名本身。
+// AggregateSignature verifies in-circuit a BLS12-381 aggregate signature with
+// emulated arithmetic. It follows VerifyAggregateSignature: the message is
+// hashed to G2 with blsDST as in RFC 9380 and e(G1, AggSig) = e(AggPK, H(m)).
+type AggregateSignature struct {
+	AggPK  sw_bls12381.G1Affine `gnark:",public"`
+	AggSig sw_bls12381.G2Affine
+}
+
+// NewAggregateSignature returns the circuit assignment of an aggregate
+// public key and signature.
+func NewAggregateSignature(aggPK bls12381.G1Affine, aggSig bls12381.G2Affine) AggregateSignature {
+	return AggregateSignature{
+		AggPK:  sw_bls12381.NewG1Affine(aggPK),
+		AggSig: sw_bls12381.NewG2Affine(aggSig),
+	}
+}
+
+// AssertValid 断言 AggSig 是 AggPK 对 message 的有效签名，message 按 32 字节大端序编码，
+// 与原生签名中 merkle root 的编码一致。
+// AssertValid asserts that AggSig is a valid signature of AggPK over the
+// 32-byte big endian encoding of 'message', which is how Tree roots are
+// signed natively.
+func (s *AggregateSignature) AssertValid(api frontend.API, message frontend.Variable) error {
+	pairing, err := sw_bls12381.NewPairing(api)
+	if err != nil {
+		return err
+	}
+	// 签名是私有输入，必须检查其位于 G2 子群
+	pairing.AssertIsOnG1(&s.AggPK)
+	pairing.AssertIsOnG2(&s.AggSig)
+
+	msg, err := variableToBytes(api, message)
+	if err != nil {
+		return err
+	}
+	hm, err := hashToG2(api, msg, blsDST)
+	if err != nil {
+		return err
+	}
+
+	// e(-G1, σ) · e(apk, H(m)) == 1
+	_, _, g1Gen, _ := bls12381.Generators()
+	var negG1 bls12381.G1Affine
+	negG1.Neg(&g1Gen)
+	negG1Var := sw_bls12381.NewG1Affine(negG1)
+	return pairing.PairingCheck(
+		[]*sw_bls12381.G1Affine{&negG1Var, &s.AggPK},
+		[]*sw_bls12381.G2Affine{&s.AggSig, hm},
+	)
+}
+
+// variableToBytes 将域元素分解为 32 字节大端序
+func variableToBytes(api frontend.API, v frontend.Variable) ([]uints.U8, error) {
+	nbBits := api.Compiler().FieldBitLen()
+	if nbBits > 256 {
+		return nil, fmt.Errorf("field of %d bits does not fit in 32 bytes", nbBits)
+	}
+	bs := bits.ToBinary(api, v, bits.WithNbDigits(nbBits))
+	for len(bs) < 256 {
+		bs = append(bs, 0)
+	}
+	uapi, err := uints.New[uints.U32](api)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]uints.U8, 32)
+	for i := range res {
+		// bs 为小端序比特，res[31] 为最低字节
+		j := 31 - i
+		res[i] = uapi.ByteValueOf(api.FromBinary(bs[8*j : 8*j+8]...))
+	}
+	return res, nil
+}
+
+// hashToG2 是 bls12381.HashToG2 在电路内的实现（RFC 9380，expand_message_xmd 使用 SHA-256）。
+// 由于 isogeny 与清除余因子都是群同态，H(m) = MapToG2(u0) + MapToG2(u1)。
+func hashToG2(api frontend.API, msg []uints.U8, dst []byte) (*sw_bls12381.G2Affine, error) {
+	const (
+		l     = 64 // 每个域元素使用的字节数，L = ceil((381 + 128) / 8)
+		count = 2
+		m     = 2
+	)
+	uniform, err := expandMessageXMD(api, msg, dst, count*m*l)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := emulated.NewField[sw_bls12381.BaseField](api)
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]*emulated.Element[sw_bls12381.BaseField], count*m)
+	for i := range elems {
+		elems[i] = bytesToFp(api, fp, uniform[i*l:(i+1)*l])
+	}
+
+	g2, err := sw_bls12381.NewG2(api)
+	if err != nil {
+		return nil, err
+	}
+	q0, err := g2.MapToG2(&fields_bls12381.E2{A0: *elems[0], A1: *elems[1]})
+	if err != nil {
+		return nil, err
+	}
+	q1, err := g2.MapToG2(&fields_bls12381.E2{A0: *elems[2], A1: *elems[3]})
+	if err != nil {
+		return nil, err
+	}
+	return g2.AddUnified(q0, q1), nil
+}
+
+// bytesToFp 将大端序字节串解释为整数并模 p 约减
+func bytesToFp(api frontend.API, fp *emulated.Field[sw_bls12381.BaseField], b []uints.U8) *emulated.Element[sw_bls12381.BaseField] {
+	// 分为高低两个 256 比特的部分：x = hi·2^256 + lo
+	half := len(b) / 2
+	hi := fp.FromBits(bytesToBits(api, b[:half])...)
+	lo := fp.FromBits(bytesToBits(api, b[half:])...)
+	shift := new(big.Int).Lsh(big.NewInt(1), uint(8*half))
+	shift.Mod(shift, sw_bls12381.BaseField{}.Modulus())
+	return fp.Reduce(fp.Add(fp.Mul(hi, fp.NewElement(shift)), lo))
+}
+
+// bytesToBits 返回大端序字节串的小端序比特
+func bytesToBits(api frontend.API, b []uints.U8) []frontend.Variable {
+	res := make([]frontend.Variable, 0, 8*len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		res = append(res, bits.ToBinary(api, b[i].Val, bits.WithNbDigits(8))...)
+	}
+	return res
+}
+
+// expandMessageXMD 实现 RFC 9380 5.3.1 中的 expand_message_xmd，哈希函数为 SHA-256
+func expandMessageXMD(api frontend.API, msg []uints.U8, dst []byte, lenInBytes int) ([]uints.U8, error) {
+	const bInBytes, rInBytes = 32, 64
+	ell := (lenInBytes + bInBytes - 1) / bInBytes
+	if ell > 255 || lenInBytes > 0xffff || len(dst) > 255 {
+		return nil, errors.New("invalid expand_message_xmd parameters")
+	}
+	dstPrime := uints.NewU8Array(append(append([]byte(nil), dst...), byte(len(dst))))
+	uapi, err := uints.New[uints.U32](api)
+	if err != nil {
+		return nil, err
+	}
+	sum := func(parts ...[]uints.U8) ([]uints.U8, error) {
+		h, err := sha2.New(api)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(), nil
+	}
+
+	// b_0 = H(Z_pad || msg || l_i_b_str || I2OSP(0, 1) || DST_prime)
+	b0, err := sum(
+		uints.NewU8Array(make([]byte, rInBytes)),
+		msg,
+		uints.NewU8Array([]byte{byte(lenInBytes >> 8), byte(lenInBytes), 0}),
+		dstPrime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// b_1 = H(b_0 || I2OSP(1, 1) || DST_prime)
+	bi, err := sum(b0, uints.NewU8Array([]byte{1}), dstPrime)
+	if err != nil {
+		return nil, err
+	}
+	uniform := append([]uints.U8(nil), bi...)
+	for i := 2; i <= ell; i++ {
+		// b_i = H(strxor(b_0, b_(i-1)) || I2OSP(i, 1) || DST_prime)
+		x := make([]uints.U8, 0, bInBytes)
+		for j := 0; j < bInBytes; j += 4 {
+			w := uapi.Xor(uapi.PackMSB(b0[j:j+4]...), uapi.PackMSB(bi[j:j+4]...))
+			x = append(x, uapi.UnpackMSB(w)...)
+		}
+		if bi, err = sum(x, uints.NewU8Array([]byte{byte(i)}), dstPrime); err != nil {
+			return nil, err
+		}
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:lenInBytes], nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type aggregateSignatureCircuit struct {
+	Message   frontend.Variable `gnark:",public"`
+	Signature AggregateSignature
+}
+
+func (c *aggregateSignatureCircuit) Define(api frontend.API) error {
+	return c.Signature.AssertValid(api, c.Message)
+}
+
+func TestAggregateSignatureCircuit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("emulated pairing is slow")
+	}
+	root := frToBytes32(Mimc("credential root"))
+	sks, pks := testAuthorities(t, 3)
+	aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+
+	assignment := aggregateSignatureCircuit{
+		Message:   BytesToVariable(root),
+		Signature: NewAggregateSignature(aggPK, aggSig),
+	}
+	if err := test.IsSolved(&aggregateSignatureCircuit{}, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignedCircuit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("emulated pairing is slow")
+	}
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag, Signature: true}
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(1)
+	for _, d := range testLeaves(4) {
+		tree.Push(d)
+	}
+	root, proofSet, proofIndex, _ := tree.Prove()
+	depth := len(proofSet) - 1
+
+	sks, pks := testAuthorities(t, 2)
+	aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+	assignment := SignedCircuit{
+		ValidCircuit: ValidCircuit{
+			MerkleRoot: BytesToVariable(root),
+			Message:    0,
+			Leaf:       BytesToVariable(proofSet[0]),
+			Path:       BytesArrayToVariables(proofSet[1:]),
+			Helper:     IndexToHelper(proofIndex, depth),
+			Depth:      depth,
+		},
+		Signature: NewAggregateSignature(aggPK, aggSig),
+	}
+	if err := test.IsSolved(ct.Placeholder(depth), &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	// 机构签署的是另一个 root
+	_, other := Aggregate(pks, testCoSign(t, sks, frToBytes32(Mimc("other root"))))
+	assignment.Signature = NewAggregateSignature(aggPK, other)
+	if err := test.IsSolved(ct.Placeholder(depth), &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("signature over another root accepted")
+	}
+}
@@ -0,0 +1,716 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	blsfr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	bls12381mpc "github.com/consensys/gnark/backend/groth16/bls12-381/mpcsetup"
+	bn254mpc "github.com/consensys/gnark/backend/groth16/bn254/mpcsetup"
+	"github.com/consensys/gnark/constraint"
+	bls12381cs "github.com/consensys/gnark/constraint/bls12-381"
+	bn254cs "github.com/consensys/gnark/constraint/bn254"
+)
+
+// CeremonyVersion 是仪式记录的格式版本，格式不兼容时递增
+const CeremonyVersion = 1
+
+// CeremonyTranscriptFile 是仪式记录在产物目录中的文件名
+const CeremonyTranscriptFile = "ceremony.json"
+
+// 仪式的两个阶段：与电路无关的 powers of tau，以及电路相关的第二阶段
+const (
+	CeremonyPhase1 = 1
+	CeremonyPhase2 = 2
+)
+
+// CeremonyBeacon 返回封存阶段 phase 时使用的随机信标，信标值记录在仪式记录中
+// A CeremonyBeacon returns the random beacon sealing phase 'phase' of a
+// ceremony, evaluated after the last contribution to it. Its output is
+// recorded in the transcript.
+type CeremonyBeacon func(phase int) ([]byte, error)
+
+// RandomBeacon 使用本机随机数作为信标。生产环境应使用公开的随机信标
+// RandomBeacon is a CeremonyBeacon drawing 32 random bytes locally. A
+// public randomness beacon is preferable in production, but the soundness
+// of the keys rests on the contributions, not on the beacon.
+func RandomBeacon(int) ([]byte, error) {
+	beacon := make([]byte, 32)
+	if _, err := rand.Read(beacon); err != nil {
+		return nil, err
+	}
+	return beacon, nil
+}
+
+// CeremonyContribution 是仪式记录中的一次贡献：贡献后的 mpcsetup 状态及贡献方的签名
+// A CeremonyContribution is the mpcsetup state after one participant's
+// contribution, with the participant's compressed BLS key and signature over
+// CeremonyMessage.
+type CeremonyContribution struct {
+	Participant []byte `json:"participant"`
+	Signature   []byte `json:"signature"`
+	Data        []byte `json:"data"`
+}
+
+// CeremonyMessage 返回贡献方对阶段 phase 的一次贡献签名的消息
+// CeremonyMessage returns the message a participant signs for contribution
+// 'data' to phase 'phase'. The data commits to the previous contribution, so
+// the signature also fixes its place in the transcript.
+func CeremonyMessage(phase int, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("DID ceremony"))
+	h.Write([]byte{byte(phase)})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// CeremonyRequestMessage 返回机构用服务器下发的挑战请求贡献权时签名的消息
+// CeremonyRequestMessage returns the message a participant with compressed
+// key 'participant' signs to ask for the turn with the one-time 'challenge'
+// issued by the coordinator, proving that it holds the key right now before
+// the turn is handed over.
+func CeremonyRequestMessage(participant, challenge []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("DID ceremony turn"))
+	h.Write(participant)
+	h.Write(challenge)
+	return h.Sum(nil)
+}
+
+// verify 检查贡献方的签名并返回其公钥
+func (c *CeremonyContribution) verify(phase int) (bls12381.G1Affine, error) {
+	var pk bls12381.G1Affine
+	var sig bls12381.G2Affine
+	if _, err := pk.SetBytes(c.Participant); err != nil {
+		return pk, fmt.Errorf("invalid participant key: %v", err)
+	}
+	if _, err := sig.SetBytes(c.Signature); err != nil {
+		return pk, fmt.Errorf("invalid participant signature: %v", err)
+	}
+	if !VerifyAggregateSignature(pk, sig, CeremonyMessage(phase, c.Data)) {
+		return pk, errors.New("participant signature does not match the contribution")
+	}
+	return pk, nil
+}
+
+// ContributeCeremony 在阶段 phase 的状态 state 上贡献随机性并用机构的 BLS 私钥签名，
+// 随机性在返回前即被丢弃
+// ContributeCeremony adds fresh randomness to 'state', the latest state of
+// phase 'phase' of a ceremony on 'curve', and signs the result with the
+// participant's BLS key. The randomness is discarded before returning.
+func ContributeCeremony(curve ecc.ID, phase int, state []byte, sk blsfr.Element, pk bls12381.G1Affine) (*CeremonyContribution, error) {
+	ops, err := ceremonyOpsFor(curve)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ops.contribute(phase, state)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := Sign(sk, CeremonyMessage(phase, data))
+	if err != nil {
+		return nil, err
+	}
+	pkBytes, sigBytes := pk.Bytes(), sig.Bytes()
+	return &CeremonyContribution{Participant: pkBytes[:], Signature: sigBytes[:], Data: data}, nil
+}
+
+// CeremonyTranscript 是可审计的仪式记录：电路描述、允许贡献的机构、两个阶段的全部贡献与信标，以及最终验证密钥的摘要
+// A CeremonyTranscript records a whole ceremony: the circuit, the
+// compressed keys of the authorities allowed to contribute, every
+// contribution to both phases in order, the beacons sealing them and the
+// digest of the resulting verifying key. Verify replays it.
+type CeremonyTranscript struct {
+	Version     int                    `json:"version"`
+	Manifest    SetupManifest          `json:"manifest"`
+	DomainSize  uint64                 `json:"domain_size"`
+	Authorities [][]byte               `json:"authorities"`
+	Phase1      []CeremonyContribution `json:"phase1"`
+	Beacon1     []byte                 `json:"beacon1,omitempty"`
+	Phase2      []CeremonyContribution `json:"phase2"`
+	Beacon2     []byte                 `json:"beacon2,omitempty"`
+	VKHash      string                 `json:"vk_hash,omitempty"`
+}
+
+// Verify 重新编译电路，检查记录的机构集合与审计方信任的 authorities 一致、每次贡献都来自其中的机构，
+// 检查每次贡献的签名、证明与链接关系，用记录的信标封存两个阶段，并确认得到的验证密钥与记录一致。
+// 返回复现的产物，审计方可与发布的产物比较
+// Verify replays the transcript: it checks that the recorded authorities
+// are exactly 'authorities', the keys the auditor trusts, recompiles the
+// circuit, checks the signature and update proof of every contribution
+// against the previous one, each by a recorded authority, seals both phases
+// with the recorded beacons and checks the digest of the resulting
+// verifying key. It returns the reproduced artifacts.
+func (t *CeremonyTranscript) Verify(authorities []bls12381.G1Affine) (*SetupArtifacts, error) {
+	if t.Version != CeremonyVersion {
+		return nil, fmt.Errorf("unsupported ceremony version %d, expected %d", t.Version, CeremonyVersion)
+	}
+	allowed, err := t.authorities()
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) != len(authorities) {
+		return nil, fmt.Errorf("transcript records %d authorities, %d are trusted", len(allowed), len(authorities))
+	}
+	for _, pk := range authorities {
+		if !allowed[pk] {
+			return nil, fmt.Errorf("trusted authority %x is not recorded in the transcript", pk.Bytes())
+		}
+	}
+	m := t.Manifest
+	m.Files, m.Digests = nil, nil
+	if b, err := m.BackendID(); err != nil || b != BackendGroth16 {
+		return nil, fmt.Errorf("ceremony for backend %q, only groth16 needs one", m.Backend)
+	}
+	ct, err := m.CredentialType()
+	if err != nil {
+		return nil, err
+	}
+	compiled, ccs, err := compileSetup(BackendGroth16, ct, m.Depth)
+	if err != nil {
+		return nil, err
+	}
+	if n := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints())); n != t.DomainSize {
+		return nil, fmt.Errorf("domain size %d does not match the circuit (%d)", t.DomainSize, n)
+	}
+	ops, err := ceremonyOpsFor(ct.Profile().CurveID())
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Phase1) == 0 || len(t.Phase2) == 0 || t.Beacon1 == nil || t.Beacon2 == nil {
+		return nil, errors.New("incomplete ceremony")
+	}
+	phase1, err := verifyCeremonySignatures(CeremonyPhase1, t.Phase1, allowed)
+	if err != nil {
+		return nil, err
+	}
+	commons, err := ops.commons(t.DomainSize, t.Beacon1, phase1)
+	if err != nil {
+		return nil, fmt.Errorf("phase 1: %v", err)
+	}
+	phase2, err := verifyCeremonySignatures(CeremonyPhase2, t.Phase2, allowed)
+	if err != nil {
+		return nil, err
+	}
+	pk, vk, err := ops.seal(ccs, commons, t.Beacon2, phase2)
+	if err != nil {
+		return nil, fmt.Errorf("phase 2: %v", err)
+	}
+	a := &SetupArtifacts{Manifest: *compiled, CCS: ccs, PK: pk, VK: vk}
+	digest, err := a.VerifyingKey().Digest()
+	if err != nil {
+		return nil, err
+	}
+	if digest != t.VKHash {
+		return nil, fmt.Errorf("ceremony yields verifying key %s, transcript records %s", digest, t.VKHash)
+	}
+	return a, nil
+}
+
+// authorities 解码记录的机构公钥，记录不能为空或重复
+func (t *CeremonyTranscript) authorities() (map[bls12381.G1Affine]bool, error) {
+	if len(t.Authorities) == 0 {
+		return nil, errors.New("transcript records no authorities")
+	}
+	allowed := make(map[bls12381.G1Affine]bool, len(t.Authorities))
+	for i, b := range t.Authorities {
+		var pk bls12381.G1Affine
+		if _, err := pk.SetBytes(b); err != nil {
+			return nil, fmt.Errorf("invalid authority key %d: %v", i, err)
+		}
+		if allowed[pk] {
+			return nil, fmt.Errorf("authority key %d recorded twice", i)
+		}
+		allowed[pk] = true
+	}
+	return allowed, nil
+}
+
+// verifyCeremonySignatures 检查一个阶段中每次贡献的签名，贡献方必须是允许的机构且只能贡献一次
+func verifyCeremonySignatures(phase int, contributions []CeremonyContribution, allowed map[bls12381.G1Affine]bool) ([][]byte, error) {
+	seen := make(map[bls12381.G1Affine]bool)
+	data := make([][]byte, len(contributions))
+	for i := range contributions {
+		pk, err := contributions[i].verify(phase)
+		if err != nil {
+			return nil, fmt.Errorf("phase %d contribution %d: %v", phase, i, err)
+		}
+		if !allowed[pk] {
+			return nil, fmt.Errorf("phase %d contribution %d: participant is not an authority of the ceremony", phase, i)
+		}
+		if seen[pk] {
+			return nil, fmt.Errorf("phase %d contribution %d: participant contributed twice", phase, i)
+		}
+		seen[pk] = true
+		data[i] = contributions[i].Data
+	}
+	return data, nil
+}
+
+// Save 将仪式记录写入目录 dir 中的 CeremonyTranscriptFile
+// Save writes the transcript to CeremonyTranscriptFile in 'dir', next to
+// the artifacts it produced.
+func (t *CeremonyTranscript) Save(dir string) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, CeremonyTranscriptFile), data, 0644)
+}
+
+// LoadCeremonyTranscript reads a transcript saved by Save; it does not
+// verify it.
+func LoadCeremonyTranscript(dir string) (*CeremonyTranscript, error) {
+	data, err := os.ReadFile(filepath.Join(dir, CeremonyTranscriptFile))
+	if err != nil {
+		return nil, err
+	}
+	var t CeremonyTranscript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid ceremony transcript: %v", err)
+	}
+	return &t, nil
+}
+
+// Ceremony 是协调方维护的多方 Groth16 可信设置仪式。每个阶段由 participants 个不同的机构依次贡献随机性，
+// 机构必须属于配置的公钥集合。只要其中一个机构诚实地丢弃了随机性，任何人都无法伪造证明
+// A Ceremony is the coordinator's state of a multi-party Groth16 setup for
+// one credential circuit, run with gnark's mpcsetup: 'participants'
+// distinct authorities, out of a configured set of keys, contribute in turn
+// to the powers of tau and then to the circuit specific phase. The keys are
+// sound as long as one of them discarded its randomness. Each contribution
+// is verified on submission.
+type Ceremony struct {
+	mu           sync.Mutex
+	participants int
+	authorities  map[bls12381.G1Affine]bool // 允许贡献的机构
+	beacon       CeremonyBeacon
+	ops          ceremonyOps
+	ccs          constraint.ConstraintSystem
+	transcript   CeremonyTranscript
+	contributed  map[bls12381.G1Affine]bool // 当前阶段已贡献的机构
+	state        []byte                     // 当前阶段最近的状态
+	commons      []byte                     // 封存后的第一阶段参数
+	artifacts    *SetupArtifacts
+}
+
+// NewCeremony 编译凭证类型 ct 最大深度为 depth 的电路并开始仪式，只有 authorities 中的机构可以贡献，
+// beacon 为 nil 时使用 RandomBeacon
+// NewCeremony compiles the circuit of maximum depth 'depth' for credential
+// type 'ct' and starts a ceremony among 'participants' of the authorities
+// with keys 'authorities', which are recorded in the transcript. A nil
+// beacon is RandomBeacon.
+func NewCeremony(ct CredentialType, depth, participants int, authorities []bls12381.G1Affine, beacon CeremonyBeacon) (*Ceremony, error) {
+	if participants < 1 || participants > len(authorities) {
+		return nil, fmt.Errorf("invalid number of participants %d for %d authorities", participants, len(authorities))
+	}
+	allowed := make(map[bls12381.G1Affine]bool, len(authorities))
+	recorded := make([][]byte, len(authorities))
+	for i, pk := range authorities {
+		if allowed[pk] {
+			return nil, fmt.Errorf("authority key %d given twice", i)
+		}
+		allowed[pk] = true
+		b := pk.Bytes()
+		recorded[i] = b[:]
+	}
+	if beacon == nil {
+		beacon = RandomBeacon
+	}
+	m, ccs, err := compileSetup(BackendGroth16, ct, depth)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := ceremonyOpsFor(ct.Profile().CurveID())
+	if err != nil {
+		return nil, err
+	}
+	n := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints()))
+	state, err := ops.initialPhase1(n)
+	if err != nil {
+		return nil, err
+	}
+	return &Ceremony{
+		participants: participants,
+		authorities:  allowed,
+		beacon:       beacon,
+		ops:          ops,
+		ccs:          ccs,
+		transcript:   CeremonyTranscript{Version: CeremonyVersion, Manifest: *m, DomainSize: n, Authorities: recorded},
+		contributed:  make(map[bls12381.G1Affine]bool),
+		state:        state,
+	}, nil
+}
+
+// Curve returns the curve of the ceremony's circuit.
+func (c *Ceremony) Curve() ecc.ID {
+	curve, _ := c.transcript.Manifest.CurveID()
+	return curve
+}
+
+// State 返回当前阶段、下一次贡献的序号以及需要在其上贡献的状态；仪式结束后阶段为 0
+// State returns the current phase, the index of the next contribution to
+// it and the state to contribute to. The phase is 0 once the ceremony is
+// over.
+func (c *Ceremony) State() (phase, index int, state []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.artifacts != nil:
+		return 0, 0, nil
+	case c.commons == nil:
+		return CeremonyPhase1, len(c.transcript.Phase1), c.state
+	default:
+		return CeremonyPhase2, len(c.transcript.Phase2), c.state
+	}
+}
+
+// Authorized reports whether the participant with key 'pk' is one of the
+// authorities of the ceremony.
+func (c *Ceremony) Authorized(pk bls12381.G1Affine) bool {
+	return c.authorities[pk]
+}
+
+// Contributed reports whether the participant with key 'pk' already
+// contributed to the current phase.
+func (c *Ceremony) Contributed(pk bls12381.G1Affine) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.contributed[pk]
+}
+
+// Submit 验证对阶段 phase 的一次贡献并将其追加到仪式记录，贡献方必须是仪式的机构。
+// 阶段的贡献数达到 participants 后用信标封存该阶段，第二阶段封存后生成最终密钥
+// Submit verifies a contribution to phase 'phase' by one of the
+// authorities against the current state and appends it to the transcript. Once a phase has all its contributions
+// it is sealed with the beacon; sealing the second phase produces the keys.
+func (c *Ceremony) Submit(phase int, contribution *CeremonyContribution) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := CeremonyPhase1
+	if c.commons != nil {
+		current = CeremonyPhase2
+	}
+	if c.artifacts != nil || phase != current {
+		return fmt.Errorf("ceremony does not accept contributions to phase %d", phase)
+	}
+	pk, err := contribution.verify(phase)
+	if err != nil {
+		return err
+	}
+	if !c.authorities[pk] {
+		return errors.New("participant is not an authority of the ceremony")
+	}
+	if c.contributed[pk] {
+		return fmt.Errorf("participant already contributed to phase %d", phase)
+	}
+	if err := c.ops.verify(phase, c.state, contribution.Data); err != nil {
+		return fmt.Errorf("invalid contribution: %v", err)
+	}
+	c.contributed[pk] = true
+	c.state = contribution.Data
+	if phase == CeremonyPhase1 {
+		c.transcript.Phase1 = append(c.transcript.Phase1, *contribution)
+		if len(c.transcript.Phase1) == c.participants {
+			return c.sealPhase1()
+		}
+		return nil
+	}
+	c.transcript.Phase2 = append(c.transcript.Phase2, *contribution)
+	if len(c.transcript.Phase2) == c.participants {
+		return c.sealPhase2()
+	}
+	return nil
+}
+
+func (c *Ceremony) sealPhase1() error {
+	beacon, err := c.beacon(CeremonyPhase1)
+	if err != nil {
+		return fmt.Errorf("phase 1 beacon: %v", err)
+	}
+	commons, err := c.ops.commons(c.transcript.DomainSize, beacon, contributionData(c.transcript.Phase1))
+	if err != nil {
+		return err
+	}
+	state, err := c.ops.initialPhase2(c.ccs, commons)
+	if err != nil {
+		return err
+	}
+	c.transcript.Beacon1 = beacon
+	c.commons, c.state = commons, state
+	c.contributed = make(map[bls12381.G1Affine]bool)
+	return nil
+}
+
+func (c *Ceremony) sealPhase2() error {
+	beacon, err := c.beacon(CeremonyPhase2)
+	if err != nil {
+		return fmt.Errorf("phase 2 beacon: %v", err)
+	}
+	pk, vk, err := c.ops.seal(c.ccs, c.commons, beacon, contributionData(c.transcript.Phase2))
+	if err != nil {
+		return err
+	}
+	a := &SetupArtifacts{Manifest: c.transcript.Manifest, CCS: c.ccs, PK: pk, VK: vk}
+	digest, err := a.VerifyingKey().Digest()
+	if err != nil {
+		return err
+	}
+	c.transcript.Beacon2 = beacon
+	c.transcript.VKHash = digest
+	c.artifacts, c.state = a, nil
+	return nil
+}
+
+func contributionData(contributions []CeremonyContribution) [][]byte {
+	data := make([][]byte, len(contributions))
+	for i := range contributions {
+		data[i] = contributions[i].Data
+	}
+	return data
+}
+
+// Artifacts returns the keys produced by the ceremony once it is over.
+func (c *Ceremony) Artifacts() (*SetupArtifacts, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.artifacts == nil {
+		return nil, errors.New("ceremony is not over")
+	}
+	return c.artifacts, nil
+}
+
+// Transcript returns a copy of the transcript so far.
+func (c *Ceremony) Transcript() *CeremonyTranscript {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.transcript
+	t.Phase1 = append([]CeremonyContribution(nil), t.Phase1...)
+	t.Phase2 = append([]CeremonyContribution(nil), t.Phase2...)
+	t.Authorities = append([][]byte(nil), t.Authorities...)
+	return &t
+}
+
+// ceremonyOps 以序列化的形式执行 mpcsetup 在一条曲线上的各个步骤
+type ceremonyOps interface {
+	initialPhase1(n uint64) ([]byte, error)
+	contribute(phase int, state []byte) ([]byte, error)
+	verify(phase int, prev, next []byte) error
+	// commons 从头验证第一阶段的全部贡献并用信标封存，返回与电路无关的参数
+	commons(n uint64, beacon []byte, phase1 [][]byte) ([]byte, error)
+	initialPhase2(ccs constraint.ConstraintSystem, commons []byte) ([]byte, error)
+	// seal 从头验证第二阶段的全部贡献并用信标封存，返回最终密钥
+	seal(ccs constraint.ConstraintSystem, commons, beacon []byte, phase2 [][]byte) (Artifact, Artifact, error)
+}
+
+// mpcPhase 是 mpcsetup 中 Phase1 与 Phase2 共同的方法
+type mpcPhase[T any] interface {
+	*T
+	Artifact
+	Contribute()
+	Verify(*T) error
+}
+
+// ceremonyCurve 封装一条曲线上 mpcsetup 包的函数
+type ceremonyCurve[T1, T2, C any, P1 mpcPhase[T1], P2 mpcPhase[T2], PC interface {
+	*C
+	Artifact
+}] struct {
+	newPhase1    func(n uint64) P1
+	verifyPhase1 func(n uint64, beacon []byte, c ...P1) (C, error)
+	initPhase2   func(p P2, ccs constraint.ConstraintSystem, commons PC) error
+	verifyPhase2 func(ccs constraint.ConstraintSystem, commons PC, beacon []byte, c ...P2) (groth16.ProvingKey, groth16.VerifyingKey, error)
+}
+
+func ceremonyOpsFor(curve ecc.ID) (ceremonyOps, error) {
+	switch curve {
+	case ecc.BN254:
+		return &ceremonyCurve[bn254mpc.Phase1, bn254mpc.Phase2, bn254mpc.SrsCommons, *bn254mpc.Phase1, *bn254mpc.Phase2, *bn254mpc.SrsCommons]{
+			newPhase1:    bn254mpc.NewPhase1,
+			verifyPhase1: bn254mpc.VerifyPhase1,
+			initPhase2: func(p *bn254mpc.Phase2, ccs constraint.ConstraintSystem, commons *bn254mpc.SrsCommons) error {
+				r1cs, ok := ccs.(*bn254cs.R1CS)
+				if !ok {
+					return fmt.Errorf("unexpected constraint system %T", ccs)
+				}
+				p.Initialize(r1cs, commons)
+				return nil
+			},
+			verifyPhase2: func(ccs constraint.ConstraintSystem, commons *bn254mpc.SrsCommons, beacon []byte, c ...*bn254mpc.Phase2) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+				r1cs, ok := ccs.(*bn254cs.R1CS)
+				if !ok {
+					return nil, nil, fmt.Errorf("unexpected constraint system %T", ccs)
+				}
+				return bn254mpc.VerifyPhase2(r1cs, commons, beacon, c...)
+			},
+		}, nil
+	case ecc.BLS12_381:
+		return &ceremonyCurve[bls12381mpc.Phase1, bls12381mpc.Phase2, bls12381mpc.SrsCommons, *bls12381mpc.Phase1, *bls12381mpc.Phase2, *bls12381mpc.SrsCommons]{
+			newPhase1:    bls12381mpc.NewPhase1,
+			verifyPhase1: bls12381mpc.VerifyPhase1,
+			initPhase2: func(p *bls12381mpc.Phase2, ccs constraint.ConstraintSystem, commons *bls12381mpc.SrsCommons) error {
+				r1cs, ok := ccs.(*bls12381cs.R1CS)
+				if !ok {
+					return fmt.Errorf("unexpected constraint system %T", ccs)
+				}
+				p.Initialize(r1cs, commons)
+				return nil
+			},
+			verifyPhase2: func(ccs constraint.ConstraintSystem, commons *bls12381mpc.SrsCommons, beacon []byte, c ...*bls12381mpc.Phase2) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+				r1cs, ok := ccs.(*bls12381cs.R1CS)
+				if !ok {
+					return nil, nil, fmt.Errorf("unexpected constraint system %T", ccs)
+				}
+				return bls12381mpc.VerifyPhase2(r1cs, commons, beacon, c...)
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("no setup ceremony for curve %s", curve)
+	}
+}
+
+// readArtifactBytes 反序列化 data，data 必须恰好被完整读取
+func readArtifactBytes(a io.ReaderFrom, data []byte) error {
+	n, err := a.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if n != int64(len(data)) {
+		return fmt.Errorf("%d trailing bytes", int64(len(data))-n)
+	}
+	return nil
+}
+
+func artifactBytes(a io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := a.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func readPhase[T any, P mpcPhase[T]](data []byte) (P, error) {
+	p := P(new(T))
+	if err := readArtifactBytes(p, data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func contributePhase[T any, P mpcPhase[T]](state []byte) ([]byte, error) {
+	p, err := readPhase[T, P](state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ceremony state: %v", err)
+	}
+	p.Contribute()
+	return artifactBytes(p)
+}
+
+func verifyPhase[T any, P mpcPhase[T]](prev, next []byte) error {
+	p, err := readPhase[T, P](prev)
+	if err != nil {
+		return fmt.Errorf("invalid ceremony state: %v", err)
+	}
+	q, err := readPhase[T, P](next)
+	if err != nil {
+		return err
+	}
+	return p.Verify((*T)(q))
+}
+
+func readPhases[T any, P mpcPhase[T]](data [][]byte) ([]P, error) {
+	phases := make([]P, len(data))
+	for i := range data {
+		p, err := readPhase[T, P](data[i])
+		if err != nil {
+			return nil, fmt.Errorf("contribution %d: %v", i, err)
+		}
+		phases[i] = p
+	}
+	return phases, nil
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) initialPhase1(n uint64) ([]byte, error) {
+	return artifactBytes(c.newPhase1(n))
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) contribute(phase int, state []byte) ([]byte, error) {
+	switch phase {
+	case CeremonyPhase1:
+		return contributePhase[T1, P1](state)
+	case CeremonyPhase2:
+		return contributePhase[T2, P2](state)
+	default:
+		return nil, fmt.Errorf("unknown ceremony phase %d", phase)
+	}
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) verify(phase int, prev, next []byte) error {
+	switch phase {
+	case CeremonyPhase1:
+		return verifyPhase[T1, P1](prev, next)
+	case CeremonyPhase2:
+		return verifyPhase[T2, P2](prev, next)
+	default:
+		return fmt.Errorf("unknown ceremony phase %d", phase)
+	}
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) commons(n uint64, beacon []byte, phase1 [][]byte) ([]byte, error) {
+	phases, err := readPhases[T1, P1](phase1)
+	if err != nil {
+		return nil, err
+	}
+	commons, err := c.verifyPhase1(n, beacon, phases...)
+	if err != nil {
+		return nil, err
+	}
+	return artifactBytes(PC(&commons))
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) readCommons(data []byte) (PC, error) {
+	commons := PC(new(C))
+	if err := readArtifactBytes(commons, data); err != nil {
+		return nil, fmt.Errorf("invalid phase 1 parameters: %v", err)
+	}
+	return commons, nil
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) initialPhase2(ccs constraint.ConstraintSystem, commons []byte) ([]byte, error) {
+	srs, err := c.readCommons(commons)
+	if err != nil {
+		return nil, err
+	}
+	p := P2(new(T2))
+	if err := c.initPhase2(p, ccs, srs); err != nil {
+		return nil, err
+	}
+	return artifactBytes(p)
+}
+
+func (c *ceremonyCurve[T1, T2, C, P1, P2, PC]) seal(ccs constraint.ConstraintSystem, commons, beacon []byte, phase2 [][]byte) (Artifact, Artifact, error) {
+	srs, err := c.readCommons(commons)
+	if err != nil {
+		return nil, nil, err
+	}
+	phases, err := readPhases[T2, P2](phase2)
+	if err != nil {
+		return nil, nil, err
+	}
+	pk, vk, err := c.verifyPhase2(ccs, srs, beacon, phases...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pk, vk, nil
+}
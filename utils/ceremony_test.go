@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+// 封存阶段时 mpcsetup 需要在群上做 FFT，-short 时只运行 BN254
+func TestCeremony(t *testing.T) {
+	curves := SupportedCurves
+	if testing.Short() {
+		curves = []ecc.ID{ecc.BN254}
+	}
+	for _, curve := range curves {
+		ct := CredentialType{Name: "test", Curve: curve}
+		const participants = 2
+		sks, pks := testAuthorities(t, participants+1)
+		authorities := pks[:participants]
+		c, err := NewCeremony(ct, 1, participants, authorities, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, phase := range []int{CeremonyPhase1, CeremonyPhase2} {
+			for i := 0; i < participants; i++ {
+				p, index, state := c.State()
+				if p != phase || index != i {
+					t.Fatalf("%s: at phase %d index %d, expected phase %d index %d", curve, p, index, phase, i)
+				}
+				contribution, err := ContributeCeremony(c.Curve(), phase, state, sks[i], pks[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// 签名必须来自贡献方
+				other, err := ContributeCeremony(c.Curve(), phase, state, sks[participants], pks[participants])
+				if err != nil {
+					t.Fatal(err)
+				}
+				forged := *contribution
+				forged.Signature = other.Signature
+				if err := c.Submit(phase, &forged); err == nil {
+					t.Fatalf("%s: contribution with another participant's signature accepted", curve)
+				}
+				// 有效的贡献，但贡献方不是仪式的机构
+				if err := c.Submit(phase, other); err == nil {
+					t.Fatalf("%s: contribution by another authority accepted", curve)
+				}
+				if err := c.Submit(phase%2+1, contribution); err == nil {
+					t.Fatalf("%s: contribution to the wrong phase accepted", curve)
+				}
+
+				if err := c.Submit(phase, contribution); err != nil {
+					t.Fatalf("%s phase %d: %v", curve, phase, err)
+				}
+				// 阶段封存后重新记录贡献方
+				if i < participants-1 && !c.Contributed(pks[i]) {
+					t.Fatalf("%s: contribution not recorded", curve)
+				}
+				if i == 0 {
+					// 同一机构在同一阶段只能贡献一次，且贡献必须基于最新状态
+					stale, err := ContributeCeremony(c.Curve(), phase, state, sks[1], pks[1])
+					if err != nil {
+						t.Fatal(err)
+					}
+					_, _, state := c.State()
+					again, err := ContributeCeremony(c.Curve(), phase, state, sks[0], pks[0])
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := c.Submit(phase, again); err == nil {
+						t.Fatalf("%s: participant contributed twice", curve)
+					}
+					if err := c.Submit(phase, stale); err == nil {
+						t.Fatalf("%s: contribution on a stale state accepted", curve)
+					}
+				}
+			}
+		}
+		if phase, _, _ := c.State(); phase != 0 {
+			t.Fatalf("%s: ceremony not over", curve)
+		}
+
+		setup, err := c.Artifacts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		if err := setup.Save(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Transcript().Save(dir); err != nil {
+			t.Fatal(err)
+		}
+		setup, err = LoadSetup(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := ct.NewTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = tree.SetIndex(1)
+		data := testLeaves(2)
+		for _, d := range data {
+			tree.Push(d)
+		}
+		root, proofSet, proofIndex, numLeaves := tree.Prove()
+		aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+		proof, public, err := Prove(setup, aggPK, aggSig, data[1], proofSet, proofIndex, numLeaves, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(setup.VerifyingKey(), proof, public, pks); err != nil {
+			t.Fatalf("%s: proof with ceremony keys rejected: %v", curve, err)
+		}
+
+		// 审计方重放记录，得到相同的验证密钥
+		transcript, err := LoadCeremonyTranscript(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		audited, err := transcript.Verify(authorities)
+		if err != nil {
+			t.Fatalf("%s: %v", curve, err)
+		}
+		digest, _ := audited.VerifyingKey().Digest()
+		if digest != setup.Manifest.Digests[artifactVK] {
+			t.Fatalf("%s: audit reproduced another verifying key", curve)
+		}
+		// 审计方信任的机构与记录不一致
+		if _, err := transcript.Verify(pks[1:]); err == nil {
+			t.Fatalf("%s: transcript verified for other authorities", curve)
+		}
+		if _, err := transcript.Verify(pks); err == nil {
+			t.Fatalf("%s: transcript verified with an authority missing from it", curve)
+		}
+		tampered := *transcript
+		tampered.Authorities = nil
+		if _, err := tampered.Verify(nil); err == nil {
+			t.Fatalf("%s: transcript without authorities verified", curve)
+		}
+		tampered = *transcript
+		tampered.Phase1 = append([]CeremonyContribution(nil), transcript.Phase1...)
+		tampered.Phase1[0].Signature = tampered.Phase1[1].Signature
+		if _, err := tampered.Verify(authorities); err == nil {
+			t.Fatalf("%s: transcript with a forged signature verified", curve)
+		}
+		if !testing.Short() {
+			tampered = *transcript
+			tampered.Beacon2 = transcript.Beacon1
+			if _, err := tampered.Verify(authorities); err == nil {
+				t.Fatalf("%s: transcript with another beacon verified", curve)
+			}
+		}
+	}
+
+	_, pks := testAuthorities(t, 2)
+	if _, err := NewCeremony(CredentialType{Name: "test"}, 1, 0, pks, nil); err == nil {
+		t.Fatal("ceremony without participants accepted")
+	}
+	if _, err := NewCeremony(CredentialType{Name: "test"}, 1, 3, pks, nil); err == nil {
+		t.Fatal("ceremony with more participants than authorities accepted")
+	}
+	if _, err := NewCeremony(CredentialType{Name: "test"}, 1, 2, []bls12381.G1Affine{pks[0], pks[0]}, nil); err == nil {
+		t.Fatal("ceremony with a repeated authority accepted")
+	}
+	if _, err := ceremonyOpsFor(ecc.BW6_761); err == nil {
+		t.Fatal("ceremony on an unsupported curve accepted")
+	}
+}
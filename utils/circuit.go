@@ -1,16 +1,12 @@
 package utils
 
 import (
+	"errors"
+	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/ecc/bls12-381"
 	"math/big"
 
-	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"log"
-
-	"fmt"
-	"github.com/consensys/gnark/std/hash/mimc"
 )
 
 // VerifyAggregateSignatureWithPairingCheck 使用 bls12381.PairingCheck 验证聚合签名
@@ -74,92 +70,184 @@ func IndexToHelper(index uint64, depth int) []frontend.Variable {
 	return helper
 }
 
-// BLS聚合验证电路
+// 凭证成员证明电路：证明 Leaf 属于 MerkleRoot 对应的 merkle 树。
+// 机构对 MerkleRoot 的聚合签名由 SignedCircuit 在电路内验证。
 type ValidCircuit struct {
-	Valid      frontend.Variable `gnark:",public"`
 	MerkleRoot frontend.Variable `gnark:",public"`
 
 	Message frontend.Variable   // 被签名的消息
 	Leaf    frontend.Variable   // Merkle 叶子
-	Path    []frontend.Variable // Merkle 路径
-	Helper  []frontend.Variable // 方向位 (0=左, 1=右)
+	Path    []frontend.Variable // Merkle 路径，按最大深度补 0
+	Helper  []frontend.Variable // 方向位 (0=左, 1=右)，按最大深度补 0
+	// 路径的实际深度，不超过 len(Path)，见 MerkleWitness。叶子总在电路内哈希，
+	// 较小的深度无法让内部节点冒充叶子，深度为 0 时只接受单个叶子的树
+	Depth frontend.Variable
+	// 叶子绑定持有者时为持有者秘密与属性，Leaf 必须等于 H(Holder...)（见 HolderLeaf），
+	// 只有知道秘密的持有者才能生成证明；不绑定时为空
+	Holder []frontend.Variable
+
+	// 哈希方式，需与生成 MerkleRoot 的 Tree 一致。Leaf 为叶子数据，在电路内按 Mode 哈希：
+	// HashModePlain 下直接哈希，其余方式下加上叶子前缀后哈希。
+	Mode HashMode `gnark:"-"`
+	// 哈希函数与曲线，需与生成 MerkleRoot 的 Tree 所用 Profile 一致，默认为 BN254 上的 MiMC。
+	// 电路在其他曲线上编译时 Define 返回错误。
+	Hash  HashFunc `gnark:"-"`
+	Curve ecc.ID   `gnark:"-"`
+}
+
+// Profile returns the proof system profile of the circuit.
+func (c *ValidCircuit) Profile() Profile {
+	return Profile{Curve: c.Curve, Hash: c.Hash}
 }
 
 func (c *ValidCircuit) Define(api frontend.API) error {
-	// 断言 Valid == 1
-	api.AssertIsEqual(c.Valid, 1)
-	curr := c.Leaf
-	for i := 0; i < len(c.Path); i++ {
-		hNode, _ := mimc.NewMiMC(api)
+	p := c.Profile()
+	if len(c.Holder) > 0 {
+		commitment, err := circuitSum(api, p, c.Holder...)
+		if err != nil {
+			return err
+		}
+		api.AssertIsEqual(c.Leaf, commitment)
+	}
+	leaf, err := circuitLeafSum(api, p, c.Mode, c.Leaf)
+	if err != nil {
+		return err
+	}
+	root, err := circuitPaddedPathRoot(api, p, c.Mode, leaf, c.Path, c.Helper, c.Depth)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(root, c.MerkleRoot)
+	return nil
+}
 
-		left := api.Select(c.Helper[i], c.Path[i], curr)
-		right := api.Select(c.Helper[i], curr, c.Path[i])
+// circuitPaddedPathRoot 与 circuitPathRoot 相同，但只使用路径的前 depth 层，
+// 同一个电路可以验证深度不超过 len(path) 的任意证明。补齐部分必须为 0。
+func circuitPaddedPathRoot(api frontend.API, p Profile, mode HashMode, leaf frontend.Variable, path, helper []frontend.Variable, depth frontend.Variable) (frontend.Variable, error) {
+	if len(path) != len(helper) {
+		return nil, errors.New("merkle path and helper differ in length")
+	}
+	api.AssertIsLessOrEqual(depth, len(path))
+	curr := leaf
+	active := frontend.Variable(1) // 第 i 层是否在路径内，即 i < depth
+	for i := 0; i < len(path); i++ {
+		active = api.Mul(active, api.Sub(1, api.IsZero(api.Sub(depth, i))))
+		inactive := api.Sub(1, active)
+		api.AssertIsEqual(api.Mul(inactive, path[i]), 0)
+		api.AssertIsEqual(api.Mul(inactive, helper[i]), 0)
+
+		left := api.Select(helper[i], path[i], curr)
+		right := api.Select(helper[i], curr, path[i])
+		node, err := circuitNodeSum(api, p, mode, left, right)
+		if err != nil {
+			return nil, err
+		}
+		curr = api.Select(active, node, curr)
+	}
+	return curr, nil
+}
 
-		hNode.Write(left, right)
-		curr = hNode.Sum()
+// circuitPathRoot 沿 merkle 路径从叶子哈希计算 root，helper[i] 为 1 时当前节点在右侧
+func circuitPathRoot(api frontend.API, p Profile, mode HashMode, leaf frontend.Variable, path, helper []frontend.Variable) (frontend.Variable, error) {
+	if len(path) != len(helper) {
+		return nil, errors.New("merkle path and helper differ in length")
 	}
-	api.AssertIsEqual(curr, c.MerkleRoot)
-	return nil
+	curr := leaf
+	for i := 0; i < len(path); i++ {
+		left := api.Select(helper[i], path[i], curr)
+		right := api.Select(helper[i], curr, path[i])
+
+		var err error
+		curr, err = circuitNodeSum(api, p, mode, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return curr, nil
 }
 
-// =============================
-// 第三部分：证明生成与验证
-// =============================
+// SignedCircuit 在 ValidCircuit 的基础上于电路内验证机构对 MerkleRoot 的聚合签名，
+// 验证方只需聚合公钥，签名本身作为私有输入不会公开。
+// SignedCircuit is a ValidCircuit that also verifies in-circuit the
+// aggregate signature of the authorities over MerkleRoot. The public inputs
+// are the root and the aggregate public key.
+type SignedCircuit struct {
+	ValidCircuit
+	Signature AggregateSignature
+}
 
-func GenerateZKProof(aggPK bls12381.G1Affine, aggSig bls12381.G2Affine, attr []byte, proofSet [][]byte, proofIndex uint64, proofRoot []byte) {
-	ok := VerifyAggregateSignature(aggPK, aggSig, proofRoot)
-	if !ok {
-		log.Fatal("Chain-side pairing verification failed — abort")
+func (c *SignedCircuit) Define(api frontend.API) error {
+	if err := c.ValidCircuit.Define(api); err != nil {
+		return err
 	}
-	fmt.Println("Chain-side BLS aggregation verification OK")
-	var circuit ValidCircuit
+	return c.Signature.AssertValid(api, c.MerkleRoot)
+}
 
-	ccs, err := frontend.Compile(bls12381.ID.ScalarField(), r1cs.NewBuilder, &circuit) // 注意：fr.Modulus() 仅为示例（你可替换为你本地 gnark 要求的 scalar field）
+// circuitLeafSum 是 FieldTree 叶子哈希在电路内的对应实现，标签由 fieldTags 统一给出。
+// HashModePlain 下同样对叶子哈希，否则内部节点或 root 本身可以冒充叶子
+func circuitLeafSum(api frontend.API, p Profile, mode HashMode, leaf frontend.Variable) (frontend.Variable, error) {
+	leafTag, _, err := mode.fieldTags()
 	if err != nil {
-		log.Fatalf("frontend.Compile error: %v", err)
+		return nil, err
 	}
-	// circuit=&ValidCircuit{
-	// 	Valid: ok,
-	// 	MerkleRoot: BytesToVariable(proofRoot),
-
-	// 	Message: BytesToVariable(msg),
-	// 	Leaf: BytesToVariable(proofSet[proofIndex]),
-	// 	Path: BytesArrayToVariables(proofSet[:proofIndex]),
-	// 	Helper: IndexToHelper(uint64(proofIndex), len(proofSet)),
+	if leafTag == nil {
+		return circuitSum(api, p, leaf)
+	}
+	return circuitSum(api, p, leafTag, leaf)
+}
 
-	// }
-	pk, vk, err := groth16.Setup(ccs)
+// circuitNodeSum 是 FieldTree 内部节点哈希在电路内的对应实现
+func circuitNodeSum(api frontend.API, p Profile, mode HashMode, left, right frontend.Variable) (frontend.Variable, error) {
+	_, nodeTag, err := mode.fieldTags()
 	if err != nil {
-		log.Fatalf("groth16.Setup error: %v", err)
+		return nil, err
 	}
-
-	// 生成 witness：Public Valid = 1
-	assignment := ValidCircuit{Valid: ok,
-		MerkleRoot: BytesToVariable(proofRoot),
-
-		Message: BytesToVariable(attr),
-		Leaf:    BytesToVariable(proofSet[proofIndex]),
-		Path:    BytesArrayToVariables(proofSet[:proofIndex]),
-		Helper:  IndexToHelper(uint64(proofIndex), len(proofSet)),
+	if nodeTag == nil {
+		return circuitSum(api, p, left, right)
 	}
+	return circuitSum(api, p, nodeTag, left, right)
+}
 
-	witness, err := frontend.NewWitness(&assignment, bls12381.ID.ScalarField())
-	if err != nil {
-		log.Fatalf("NewWitness error: %v", err)
+// ComputeMerkleRoot 在电路内计算全部叶子的 merkle root，形状与 Tree 相同
+// ComputeMerkleRoot computes in-circuit the root of a tree made of 'leaves'
+// hashed with the profile 'p', following the orphan rules of Tree. It is the circuit
+// counterpart of FieldTree.Root.
+func ComputeMerkleRoot(api frontend.API, p Profile, mode HashMode, leaves []frontend.Variable) (frontend.Variable, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("cannot compute the root of an empty tree")
 	}
+	sums := make([]frontend.Variable, len(leaves))
+	for i, l := range leaves {
+		s, err := circuitLeafSum(api, p, mode, l)
+		if err != nil {
+			return nil, err
+		}
+		sums[i] = s
+	}
+	return circuitSubTreeRoot(api, p, mode, sums)
+}
 
-	proof, err := groth16.Prove(ccs, pk, witness)
+func circuitSubTreeRoot(api frontend.API, p Profile, mode HashMode, sums []frontend.Variable) (frontend.Variable, error) {
+	if len(sums) == 1 {
+		return sums[0], nil
+	}
+	k := splitPoint(uint64(len(sums)))
+	l, err := circuitSubTreeRoot(api, p, mode, sums[:k])
 	if err != nil {
-		log.Fatalf("groth16.Prove error: %v", err)
+		return nil, err
 	}
-
-	publicWitness, err := witness.Public()
+	r, err := circuitSubTreeRoot(api, p, mode, sums[k:])
 	if err != nil {
-		log.Fatalf("witness.Public error: %v", err)
+		return nil, err
 	}
+	return circuitNodeSum(api, p, mode, l, r)
+}
 
-	if err := groth16.Verify(proof, vk, publicWitness); err != nil {
-		log.Fatalf("groth16.Verify failed: %v", err)
+func circuitSum(api frontend.API, p Profile, data ...frontend.Variable) (frontend.Variable, error) {
+	h, err := p.NewCircuitHash(api)
+	if err != nil {
+		return nil, err
 	}
-
+	h.Write(data...)
+	return h.Sum(), nil
 }
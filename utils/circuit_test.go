@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+)
+
+// 原生 Tree 与电路在各种哈希方式下应得到相同的 merkle root
+func TestHashModeCircuit(t *testing.T) {
+	h := mimc.NewMiMC()
+	data := testLeaves(8)
+	for _, mode := range []HashMode{HashModeRFC6962, HashModeDomainTag} {
+		for i := uint64(0); i < uint64(len(data)); i++ {
+			tree := New1(h)
+			if err := tree.SetHashMode(mode); err != nil {
+				t.Fatal(err)
+			}
+			_ = tree.SetIndex(i)
+			for _, d := range data {
+				tree.Push1(d)
+			}
+			root, proofSet, proofIndex, numLeaves := tree.Prove1()
+			if !VerifyProofMode(h, mode, root, proofSet[0], proofSet[1:], proofIndex, numLeaves) {
+				t.Fatalf("%v: native proof %d rejected", mode, i)
+			}
+
+			depth := len(proofSet) - 1
+			circuit := ValidCircuit{
+				Path:   make([]frontend.Variable, depth),
+				Helper: make([]frontend.Variable, depth),
+				Mode:   mode,
+			}
+			assignment := ValidCircuit{
+				MerkleRoot: BytesToVariable(root),
+				Message:    0,
+				Leaf:       BytesToVariable(proofSet[0]),
+				Path:       BytesArrayToVariables(proofSet[1:]),
+				Helper:     IndexToHelper(proofIndex, depth),
+				Depth:      depth,
+			}
+			if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("%v: circuit rejected proof %d: %v", mode, i, err)
+			}
+
+			// 将叶子的普通哈希当作叶子数据提交时应被拒绝
+			assignment.Leaf = BytesToVariable(HashModePlain.LeafSum(h, proofSet[0]))
+			if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+				t.Fatalf("%v: circuit accepted unprefixed leaf", mode)
+			}
+		}
+	}
+}
+
+// 每种凭证类型下 Tree 生成的证明都应被对应的电路接受
+func TestCredentialTypeCircuit(t *testing.T) {
+	data := testLeaves(5)
+	for _, hf := range []HashFunc{HashMiMC, HashPoseidon2} {
+		for _, mode := range []HashMode{HashModeRFC6962, HashModeDomainTag} {
+			ct := CredentialType{Name: "test", Hash: hf, Mode: mode}
+			tree, err := ct.NewTree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = tree.SetIndex(3)
+			for _, d := range data {
+				tree.Push(d)
+			}
+			root, proofSet, proofIndex, _ := tree.Prove()
+			depth := len(proofSet) - 1
+			circuit := ct.Circuit(depth)
+			assignment := ValidCircuit{
+				MerkleRoot: BytesToVariable(root),
+				Message:    0,
+				Leaf:       BytesToVariable(proofSet[0]),
+				Path:       BytesArrayToVariables(proofSet[1:]),
+				Helper:     IndexToHelper(proofIndex, depth),
+				Depth:      depth,
+			}
+			if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("%v %v: circuit rejected native proof: %v", hf, mode, err)
+			}
+		}
+	}
+	if _, err := (CredentialType{Hash: HashFunc(9)}).NewTree(); err == nil {
+		t.Fatal("unknown hash function accepted")
+	}
+}
+
+// 比较不同哈希函数下 ValidCircuit 的约束数量
+func benchmarkCircuitConstraints(b *testing.B, hf HashFunc, newBuilder frontend.NewBuilder) {
+	circuit := CredentialType{Hash: hf, Mode: HashModeDomainTag}.Circuit(20)
+	var nbConstraints int
+	for i := 0; i < b.N; i++ {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), newBuilder, &circuit)
+		if err != nil {
+			b.Fatal(err)
+		}
+		nbConstraints = ccs.GetNbConstraints()
+	}
+	b.ReportMetric(float64(nbConstraints), "constraints")
+}
+
+func BenchmarkConstraintsR1CSMiMC(b *testing.B) {
+	benchmarkCircuitConstraints(b, HashMiMC, r1cs.NewBuilder)
+}
+
+func BenchmarkConstraintsR1CSPoseidon2(b *testing.B) {
+	benchmarkCircuitConstraints(b, HashPoseidon2, r1cs.NewBuilder)
+}
+
+func BenchmarkConstraintsSCSMiMC(b *testing.B) {
+	benchmarkCircuitConstraints(b, HashMiMC, scs.NewBuilder)
+}
+
+func BenchmarkConstraintsSCSPoseidon2(b *testing.B) {
+	benchmarkCircuitConstraints(b, HashPoseidon2, scs.NewBuilder)
+}
+
+// TestValidCircuit 在每条支持的曲线与每种后端上检查 ValidCircuit：
+// 任意位置（包括落单叶子）的证明都应被接受，篡改路径、错误的 root、翻转方向位、
+// 缩短深度、以 root 作为深度 0 的叶子以及补齐部分非零时都应被拒绝。默认只运行测试引擎与约束求解器，
+// 使用 -tags=prover_checks 时同时运行证明与验证。
+func TestValidCircuit(t *testing.T) {
+	const maxDepth = 3
+	tampered := []byte("tampered")
+	invalid := []struct {
+		name    string
+		padding bool // 只适用于深度小于 maxDepth 的证明
+		tamper  func(c *ValidCircuit)
+	}{
+		{"wrong root", false, func(c *ValidCircuit) { c.MerkleRoot = BytesToVariable(tampered) }},
+		{"wrong leaf", false, func(c *ValidCircuit) { c.Leaf = BytesToVariable(tampered) }},
+		{"tampered path", false, func(c *ValidCircuit) { c.Path[0] = BytesToVariable(tampered) }},
+		{"flipped helper", false, func(c *ValidCircuit) { c.Helper[0] = 1 - c.Helper[0].(uint8) }},
+		{"non-binary helper", false, func(c *ValidCircuit) { c.Helper[0] = 2 }},
+		{"depth too large", false, func(c *ValidCircuit) { c.Depth = maxDepth + 1 }},
+		// 缩短深度并清零多出的路径，叶子在电路内哈希，不能当作内部节点使用
+		{"depth too small", false, func(c *ValidCircuit) {
+			d := c.Depth.(int) - 1
+			c.Depth, c.Path[d], c.Helper[d] = d, 0, 0
+		}},
+		{"leaf = root at depth 0", false, func(c *ValidCircuit) {
+			c.Leaf, c.Depth = c.MerkleRoot, 0
+			for i := range c.Path {
+				c.Path[i], c.Helper[i] = 0, 0
+			}
+		}},
+		{"padded path", true, func(c *ValidCircuit) { c.Path[maxDepth-1] = 1 }},
+		{"padded helper", true, func(c *ValidCircuit) { c.Helper[maxDepth-1] = 1 }},
+	}
+
+	for _, curve := range SupportedCurves {
+		for _, mode := range []HashMode{HashModePlain, HashModeRFC6962, HashModeDomainTag} {
+			ct := CredentialType{Name: "test", Curve: curve, Mode: mode}
+			opts := []test.TestingOption{
+				test.WithCurves(curve),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			}
+			// 5 个叶子时最后一个叶子落单，8 个叶子为满树
+			for _, n := range []int{1, 5, 8} {
+				data := testLeaves(n)
+				for i := uint64(0); i < uint64(n); i++ {
+					tree, err := ct.NewTree()
+					if err != nil {
+						t.Fatal(err)
+					}
+					_ = tree.SetIndex(i)
+					for _, d := range data {
+						tree.Push(d)
+					}
+					root, proofSet, proofIndex, numLeaves := tree.Prove()
+					mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+					if err != nil {
+						t.Fatalf("%v %v: leaf %d of %d: %v", curve, mode, i, n, err)
+					}
+					valid := mw.Circuit(root, data[i])
+					opts = append(opts, test.WithValidAssignment(&valid))
+					if mw.Depth == 0 {
+						continue
+					}
+					for _, c := range invalid {
+						if c.padding && mw.Depth == maxDepth {
+							continue
+						}
+						assignment := mw.Circuit(root, data[i])
+						assignment.Path = append([]frontend.Variable(nil), mw.Path...)
+						assignment.Helper = append([]frontend.Variable(nil), mw.Helper...)
+						c.tamper(&assignment)
+						opts = append(opts, test.WithInvalidAssignment(&assignment))
+					}
+				}
+			}
+			circuit := ct.Circuit(maxDepth)
+			test.NewAssert(t).CheckCircuit(&circuit, opts...)
+		}
+	}
+}
+
+// 回归测试：叶子总在电路内哈希，root 本身或内部节点都不能冒充叶子，
+// 缩短 Depth 无法绕过路径，深度为 0 时只接受单个叶子的树
+func TestValidCircuitLeafForgery(t *testing.T) {
+	const maxDepth = 2
+	data := testLeaves(4)
+	for _, mode := range []HashMode{HashModePlain, HashModeRFC6962, HashModeDomainTag} {
+		ct := CredentialType{Name: "test", Mode: mode}
+		tree, err := ct.NewTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = tree.SetIndex(2)
+		for _, d := range data {
+			tree.Push(d)
+		}
+		root, proofSet, proofIndex, numLeaves := tree.Prove()
+		mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		circuit := ct.Circuit(maxDepth)
+		valid := mw.Circuit(root, data[2])
+		if err := test.IsSolved(&circuit, &valid, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%v: valid proof rejected: %v", mode, err)
+		}
+
+		// 深度为 0，叶子即 root
+		forged := ValidCircuit{
+			MerkleRoot: BytesToVariable(root),
+			Message:    0,
+			Leaf:       BytesToVariable(root),
+			Path:       []frontend.Variable{0, 0},
+			Helper:     []frontend.Variable{0, 0},
+			Depth:      0,
+		}
+		if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: root accepted as a leaf at depth 0", mode)
+		}
+
+		// 深度为 1，叶子为叶子 2、3 的父节点
+		h := ct.Profile().NewHash()
+		node := mode.NodeSum(h, mode.LeafSum(h, data[2]), mode.LeafSum(h, data[3]))
+		forged.Leaf = BytesToVariable(node)
+		forged.Path = []frontend.Variable{BytesToVariable(proofSet[2]), 0}
+		forged.Helper = []frontend.Variable{1, 0}
+		forged.Depth = 1
+		if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: internal node accepted as a leaf", mode)
+		}
+	}
+
+	// 单个叶子的树，深度 0 的证明有效
+	ct := CredentialType{Name: "test"}
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(0)
+	tree.Push(data[0])
+	root, proofSet, proofIndex, numLeaves := tree.Prove()
+	mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	circuit := ct.Circuit(maxDepth)
+	valid := mw.Circuit(root, data[0])
+	if err := test.IsSolved(&circuit, &valid, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("single leaf proof rejected: %v", err)
+	}
+}
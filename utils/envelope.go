@@ -0,0 +1,391 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// EnvelopeVersion 是证明信封的格式版本，格式不兼容时递增
+const EnvelopeVersion = 1
+
+// EnvelopeFormat 是证明信封的编码格式，解码时根据内容自动识别
+// An EnvelopeFormat is a wire encoding of an Envelope. Both encodings carry
+// the same fields; DecodeEnvelope tells them apart by their first byte.
+type EnvelopeFormat uint8
+
+const (
+	// EnvelopeJSON encodes envelopes as JSON objects, byte strings in base64.
+	EnvelopeJSON EnvelopeFormat = iota
+	// EnvelopeCBOR encodes envelopes as deterministic CBOR maps.
+	EnvelopeCBOR
+)
+
+// String returns the name of the format.
+func (f EnvelopeFormat) String() string {
+	switch f {
+	case EnvelopeJSON:
+		return "json"
+	case EnvelopeCBOR:
+		return "cbor"
+	default:
+		return fmt.Sprintf("EnvelopeFormat(%d)", uint8(f))
+	}
+}
+
+// ParseEnvelopeFormat returns the format with the given name. The empty
+// string is JSON.
+func ParseEnvelopeFormat(name string) (EnvelopeFormat, error) {
+	switch name {
+	case "", "json":
+		return EnvelopeJSON, nil
+	case "cbor":
+		return EnvelopeCBOR, nil
+	default:
+		return 0, fmt.Errorf("unknown envelope format %q", name)
+	}
+}
+
+// PublicInput 是按电路字段命名的公开输入，值为大端序、定长的域元素
+// A PublicInput is one element of the public witness, named after the
+// circuit field it assigns, with its value as a big-endian field element.
+type PublicInput struct {
+	Name  string `json:"name"`
+	Value []byte `json:"value"`
+}
+
+// IssuerMetadata 是可选的签发方信息：凭证 root 与机构的聚合公钥、聚合签名（压缩编码）
+// IssuerMetadata are the credential root and the compressed aggregate key
+// and signature of the authorities over it. AggSig is empty when the
+// signature was verified in-circuit.
+type IssuerMetadata struct {
+	MerkleRoot []byte `json:"merkle_root"`
+	AggPK      []byte `json:"agg_pk"`
+	AggSig     []byte `json:"agg_sig,omitempty"`
+}
+
+// Envelope 是自描述的证明格式：电路标识与版本、后端与曲线、验证密钥摘要、
+// 命名的公开输入、证明本身以及可选的签发方信息与 nullifier，验证方无需其他上下文即可选择密钥并验证
+// An Envelope is the wire format of a proof. It names the circuit and
+// version the proof is for, the backend and curve, the sha256 of the
+// verifying key (see VerifyingKey.Digest), the public inputs by name and the
+// serialized proof, so a verifier can pick the key and check the proof
+// without any other context. Issuer is optional in the encoding but
+// VerifyEnvelope requires it. Nullifier is set for proofs made by
+// ProveNullifier.
+type Envelope struct {
+	Version        int              `json:"version"`
+	Circuit        string           `json:"circuit"`
+	CircuitVersion int              `json:"circuit_version"`
+	Backend        string           `json:"backend"`
+	Curve          string           `json:"curve"`
+	VKHash         string           `json:"vk_hash"`
+	Public         []PublicInput    `json:"public"`
+	Proof          []byte           `json:"proof"`
+	Issuer         *IssuerMetadata  `json:"issuer,omitempty"`
+	Nullifier      *NullifierInputs `json:"nullifier,omitempty"`
+}
+
+// NewEnvelope 将 Prove 的结果与可信设置清单、验证密钥一起封装，签发方信息与 nullifier 取自公开输入
+// NewEnvelope wraps a proof and its public inputs, as returned by Prove,
+// for the circuit described by 'm' with verifying key 'vk'. The issuer
+// metadata and nullifier are taken from the public inputs.
+func NewEnvelope(m *SetupManifest, vk *VerifyingKey, proof *ZKProof, public *PublicInputs) (*Envelope, error) {
+	if m == nil || vk == nil || vk.Key == nil || proof == nil || proof.Proof == nil || public == nil {
+		return nil, errors.New("missing manifest, verifying key, proof or public inputs")
+	}
+	if vk.Backend != proof.Backend || vk.Curve != proof.Curve {
+		return nil, fmt.Errorf("%v proof on %s does not match %v verifying key on %s", proof.Backend, proof.Curve, vk.Backend, vk.Curve)
+	}
+	digest, err := vk.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("hash verifying key: %v", err)
+	}
+	named, err := public.Named(proof.Curve)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := proof.Proof.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	key := m.Key()
+	return &Envelope{
+		Version:        EnvelopeVersion,
+		Circuit:        key.ID,
+		CircuitVersion: key.Version,
+		Backend:        proof.Backend.String(),
+		Curve:          proof.Curve.String(),
+		VKHash:         digest,
+		Public:         named,
+		Proof:          buf.Bytes(),
+		Issuer:         public.issuer(),
+		Nullifier:      public.Nullifier,
+	}, nil
+}
+
+// Named 返回公开 witness 中每个元素的字段名与值，顺序与 witness 相同
+// Named returns the public witness of the inputs on 'curve' with the name
+// of the circuit field of every element, in witness order.
+func (p *PublicInputs) Named(curve ecc.ID) ([]PublicInput, error) {
+	assignment := p.assignment()
+	var names []string
+	tVariable := reflect.TypeOf((*frontend.Variable)(nil)).Elem()
+	_, err := schema.Walk(curve.ScalarField(), assignment, tVariable, func(leaf schema.LeafInfo, _ reflect.Value) error {
+		if leaf.Visibility == schema.Public {
+			names = append(names, leaf.FullName())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	w, err := frontend.NewWitness(assignment, curve.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return nil, fmt.Errorf("build public witness: %v", err)
+	}
+	values, err := witnessValues(w)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(names) {
+		return nil, fmt.Errorf("%d public inputs for %d public fields", len(values), len(names))
+	}
+	size := (curve.ScalarField().BitLen() + 7) / 8
+	named := make([]PublicInput, len(values))
+	for i, v := range values {
+		named[i] = PublicInput{Name: names[i], Value: v.FillBytes(make([]byte, size))}
+	}
+	return named, nil
+}
+
+func (p *PublicInputs) issuer() *IssuerMetadata {
+	pk := p.AggPK.Bytes()
+	m := &IssuerMetadata{
+		MerkleRoot: append([]byte(nil), p.MerkleRoot...),
+		AggPK:      pk[:],
+	}
+	if p.AggSig != nil {
+		sig := p.AggSig.Bytes()
+		m.AggSig = sig[:]
+	}
+	return m
+}
+
+// Encode 以格式 f 编码信封
+// Encode encodes the envelope in format 'f'.
+func (e *Envelope) Encode(f EnvelopeFormat) ([]byte, error) {
+	switch f {
+	case EnvelopeJSON:
+		return json.MarshalIndent(e, "", "  ")
+	case EnvelopeCBOR:
+		em, err := cbor.CoreDetEncOptions().EncMode()
+		if err != nil {
+			return nil, err
+		}
+		return em.Marshal(e)
+	default:
+		return nil, fmt.Errorf("unknown envelope format %v", f)
+	}
+}
+
+// DecodeEnvelope 解码 JSON 或 CBOR 编码的信封并校验其内容
+// DecodeEnvelope decodes an envelope in either format and validates it.
+func DecodeEnvelope(data []byte) (*Envelope, error) {
+	var e Envelope
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &e); err != nil {
+			return nil, fmt.Errorf("invalid json envelope: %v", err)
+		}
+	} else {
+		dm, err := cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}.DecMode()
+		if err != nil {
+			return nil, err
+		}
+		if err := dm.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("invalid cbor envelope: %v", err)
+		}
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Validate 检查信封的格式版本、各字段的取值以及证明能否解析；
+// 带有签发方信息时，公开输入必须与其一致
+// Validate checks that the envelope is well formed: known version, backend
+// and curve, a sha256 verifying-key hash, canonical and uniquely named
+// public inputs and a proof that decodes. When issuer metadata are present
+// the public inputs must be the ones they imply. It does not verify the
+// proof; see VerifyEnvelope.
+func (e *Envelope) Validate() error {
+	if e.Version != EnvelopeVersion {
+		return fmt.Errorf("unsupported envelope version %d, expected %d", e.Version, EnvelopeVersion)
+	}
+	if e.Circuit == "" {
+		return errors.New("envelope has no circuit id")
+	}
+	if e.CircuitVersion < 1 {
+		return fmt.Errorf("invalid circuit version %d", e.CircuitVersion)
+	}
+	if digest, err := hex.DecodeString(e.VKHash); err != nil || len(digest) != 32 {
+		return fmt.Errorf("invalid verifying key hash %q", e.VKHash)
+	}
+	proof, err := e.ZKProof()
+	if err != nil {
+		return err
+	}
+	if len(e.Public) == 0 {
+		return errors.New("envelope has no public inputs")
+	}
+	modulus := proof.Curve.ScalarField()
+	size := (modulus.BitLen() + 7) / 8
+	seen := make(map[string]bool, len(e.Public))
+	for _, in := range e.Public {
+		if in.Name == "" || seen[in.Name] {
+			return fmt.Errorf("missing or duplicate public input name %q", in.Name)
+		}
+		seen[in.Name] = true
+		if len(in.Value) != size || new(big.Int).SetBytes(in.Value).Cmp(modulus) >= 0 {
+			return fmt.Errorf("public input %s is not a canonical %s scalar", in.Name, proof.Curve)
+		}
+	}
+	if e.Issuer == nil {
+		return nil
+	}
+	public, err := e.PublicInputs()
+	if err != nil {
+		return err
+	}
+	named, err := public.Named(proof.Curve)
+	if err != nil {
+		return err
+	}
+	if len(named) != len(e.Public) {
+		return fmt.Errorf("issuer metadata imply %d public inputs, envelope has %d", len(named), len(e.Public))
+	}
+	for i := range named {
+		if named[i].Name != e.Public[i].Name || !bytes.Equal(named[i].Value, e.Public[i].Value) {
+			return fmt.Errorf("public input %s does not match the issuer metadata", e.Public[i].Name)
+		}
+	}
+	return nil
+}
+
+// Key returns the id and version of the circuit the proof is for.
+func (e *Envelope) Key() CircuitKey {
+	return CircuitKey{ID: e.Circuit, Version: e.CircuitVersion}
+}
+
+// ZKProof 返回信封中的证明
+// ZKProof decodes the proof of the envelope.
+func (e *Envelope) ZKProof() (*ZKProof, error) {
+	b, err := ParseBackend(e.Backend)
+	if err != nil {
+		return nil, err
+	}
+	curve, err := ecc.IDFromString(e.Curve)
+	if err != nil || !implementedCurve(curve) {
+		return nil, fmt.Errorf("unknown curve %q", e.Curve)
+	}
+	proof := b.newProof(curve)
+	if _, err := proof.ReadFrom(bytes.NewReader(e.Proof)); err != nil {
+		return nil, fmt.Errorf("invalid %v proof: %v", b, err)
+	}
+	return &ZKProof{Backend: b, Curve: curve, Proof: proof}, nil
+}
+
+// PublicInputs 由签发方信息与 nullifier 还原 Prove 返回的公开输入
+// PublicInputs returns the public inputs described by the issuer metadata
+// and the nullifier of the envelope.
+func (e *Envelope) PublicInputs() (*PublicInputs, error) {
+	if e.Issuer == nil {
+		return nil, errors.New("envelope has no issuer metadata")
+	}
+	public := &PublicInputs{
+		MerkleRoot: append([]byte(nil), e.Issuer.MerkleRoot...),
+		Nullifier:  e.Nullifier,
+	}
+	if _, err := public.AggPK.SetBytes(e.Issuer.AggPK); err != nil {
+		return nil, fmt.Errorf("invalid aggregate public key: %v", err)
+	}
+	if len(e.Issuer.AggSig) > 0 {
+		var sig bls12381.G2Affine
+		if _, err := sig.SetBytes(e.Issuer.AggSig); err != nil {
+			return nil, fmt.Errorf("invalid aggregate signature: %v", err)
+		}
+		public.AggSig = &sig
+	}
+	return public, nil
+}
+
+// Witness 由命名的公开输入构造公开 witness
+// Witness returns the public witness made of the named public inputs.
+func (e *Envelope) Witness() (witness.Witness, error) {
+	curve, err := ecc.IDFromString(e.Curve)
+	if err != nil {
+		return nil, err
+	}
+	w, err := witness.New(curve.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	values := make(chan any, len(e.Public))
+	for _, in := range e.Public {
+		values <- new(big.Int).SetBytes(in.Value)
+	}
+	close(values)
+	if err := w.Fill(len(e.Public), 0, values); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// VerifyEnvelope 校验信封并用 vk 验证其中的证明。验证密钥的摘要必须与信封记录的一致；
+// 信封必须带有签发方信息，聚合公钥必须来自 authorities，签名未在电路内验证时同时验证签名（见 Verify）
+// VerifyEnvelope validates the envelope and checks its proof with 'vk',
+// whose digest must be the one the envelope names. The proof is checked by
+// Verify: the envelope must carry issuer metadata, whose aggregate key must
+// be the aggregate of the trusted 'authorities' and whose signature is
+// checked unless the circuit verifies it. Envelopes without issuer metadata
+// are rejected, since the proof alone does not show who signed the root.
+func VerifyEnvelope(vk *VerifyingKey, e *Envelope, authorities []bls12381.G1Affine) error {
+	if vk == nil || vk.Key == nil || e == nil {
+		return errors.New("missing verifying key or envelope")
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	digest, err := vk.Digest()
+	if err != nil {
+		return fmt.Errorf("hash verifying key: %v", err)
+	}
+	if digest != e.VKHash {
+		return fmt.Errorf("envelope is for verifying key %s, not %s", e.VKHash, digest)
+	}
+	proof, err := e.ZKProof()
+	if err != nil {
+		return err
+	}
+	if e.Issuer == nil {
+		return errors.New("envelope has no issuer metadata, the authorities' signature cannot be checked")
+	}
+	public, err := e.PublicInputs()
+	if err != nil {
+		return err
+	}
+	return Verify(vk, proof, public, authorities)
+}
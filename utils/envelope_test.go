@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	for _, b := range []Backend{BackendGroth16, BackendPlonk} {
+		ct := CredentialType{Name: "test", Mode: HashModeDomainTag, Version: 3}
+		setup, err := setupBackend(b, ct, 2, UnsafeSRS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proofs, publics, authorities := testInnerProofs(t, setup, ct, 1)
+		vk := setup.VerifyingKey()
+		e, err := NewEnvelope(&setup.Manifest, vk, proofs[0], publics[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Circuit != "test" || e.CircuitVersion != 3 || e.Backend != b.String() {
+			t.Fatalf("%v: envelope describes %s v%d (%s)", b, e.Circuit, e.CircuitVersion, e.Backend)
+		}
+		if len(e.Public) != 1 || e.Public[0].Name != "MerkleRoot" {
+			t.Fatalf("%v: unexpected public inputs %+v", b, e.Public)
+		}
+
+		for _, f := range []EnvelopeFormat{EnvelopeJSON, EnvelopeCBOR} {
+			data, err := e.Encode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := DecodeEnvelope(data)
+			if err != nil {
+				t.Fatalf("%v %v: %v", b, f, err)
+			}
+			if !reflect.DeepEqual(decoded, e) {
+				t.Fatalf("%v %v: envelope changed in round trip", b, f)
+			}
+			if err := VerifyEnvelope(vk, decoded, authorities); err != nil {
+				t.Fatalf("%v %v: %v", b, f, err)
+			}
+		}
+		// 签发方的聚合公钥不属于受信任的机构
+		_, foreign := testAuthorities(t, 3)
+		if err := VerifyEnvelope(vk, e, foreign); err == nil {
+			t.Fatalf("%v: envelope accepted from untrusted authorities", b)
+		}
+
+		// 不带签发方信息时无法确认 root 由受信任的机构签名
+		bare := *e
+		bare.Issuer = nil
+		if err := VerifyEnvelope(vk, &bare, authorities); err == nil {
+			t.Fatalf("%v: envelope without issuer accepted", b)
+		}
+		bare.Public = []PublicInput{{Name: "MerkleRoot", Value: make([]byte, 32)}}
+		if err := VerifyEnvelope(vk, &bare, authorities); err == nil {
+			t.Fatalf("%v: proof accepted for another root", b)
+		}
+
+		// 公开输入与签发方信息不一致
+		forged := *e
+		forged.Public = bare.Public
+		if err := forged.Validate(); err == nil {
+			t.Fatalf("%v: public inputs not checked against the issuer", b)
+		}
+		if err := VerifyEnvelope(vk, &forged, authorities); err == nil {
+			t.Fatalf("%v: envelope accepted with public inputs of another root", b)
+		}
+
+		// 其他电路的验证密钥
+		other, err := setupBackend(b, ct, 3, UnsafeSRS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyEnvelope(other.VerifyingKey(), e, authorities); err == nil {
+			t.Fatalf("%v: envelope accepted with another verifying key", b)
+		}
+	}
+}
+
+func TestDecodeEnvelopeInvalid(t *testing.T) {
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	setup, err := Setup(ct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, publics, _ := testInnerProofs(t, setup, ct, 1)
+	e, err := NewEnvelope(&setup.Manifest, setup.VerifyingKey(), proofs[0], publics[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tamper := range map[string]func(e *Envelope){
+		"version":          func(e *Envelope) { e.Version = EnvelopeVersion + 1 },
+		"circuit":          func(e *Envelope) { e.Circuit = "" },
+		"circuit version":  func(e *Envelope) { e.CircuitVersion = 0 },
+		"backend":          func(e *Envelope) { e.Backend = "stark" },
+		"curve":            func(e *Envelope) { e.Curve = "secp256k1" },
+		"vk hash":          func(e *Envelope) { e.VKHash = "00" },
+		"proof":            func(e *Envelope) { e.Proof = e.Proof[:10] },
+		"no public inputs": func(e *Envelope) { e.Public = nil },
+		"non-canonical":    func(e *Envelope) { e.Public = []PublicInput{{Name: "MerkleRoot", Value: []byte{1}}} },
+		"agg pk":           func(e *Envelope) { e.Issuer = &IssuerMetadata{MerkleRoot: e.Issuer.MerkleRoot, AggPK: []byte{1}} },
+	} {
+		for _, f := range []EnvelopeFormat{EnvelopeJSON, EnvelopeCBOR} {
+			tampered := *e
+			tamper(&tampered)
+			data, err := tampered.Encode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := DecodeEnvelope(data); err == nil {
+				t.Fatalf("%v: envelope with invalid %s accepted", f, name)
+			}
+		}
+	}
+	if _, err := ParseEnvelopeFormat("xml"); err == nil {
+		t.Fatal("unknown envelope format accepted")
+	}
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// fieldTags 是 FieldTree 与电路共用的唯一哈希规则：
+//
+//	leaf = MiMC(leafTag, leaf)         leafTag 为 nil 时不加标签
+//	node = MiMC(nodeTag, left, right)  nodeTag 为 nil 时不加标签
+//
+// fieldTags returns the domain tags absorbed before leaf and node inputs. It
+// is the single definition of field element hashing shared by FieldTree and
+// the circuit gadgets. The tags are the field elements that MiMC absorbs for
+// the byte prefixes of HashMode.LeafSum and HashMode.NodeSum, so a FieldTree
+// agrees with a Tree over the 32-byte encoding of the same leaves.
+func (m HashMode) fieldTags() (leafTag, nodeTag *big.Int, err error) {
+	switch m {
+	case HashModePlain:
+		return nil, nil, nil
+	case HashModeRFC6962:
+		return new(big.Int).SetBytes(leafHashPrefix), new(big.Int).SetBytes(nodeHashPrefix), nil
+	case HashModeDomainTag:
+		return new(big.Int).SetBytes(leafDomainTag), new(big.Int).SetBytes(nodeDomainTag), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown hash mode %v", m)
+	}
+}
+
+// fieldSum 对域元素做 MiMC 哈希，每个输入按 32 字节大端序写入
+func fieldSum(h hash.Hash, data ...*fr.Element) fr.Element {
+	h.Reset()
+	for _, d := range data {
+		b := d.Bytes()
+		if _, err := h.Write(b[:]); err != nil {
+			panic(err)
+		}
+	}
+	var res fr.Element
+	res.SetBytes(h.Sum(nil))
+	return res
+}
+
+func fieldLeafSum(h hash.Hash, mode HashMode, leaf *fr.Element) fr.Element {
+	leafTag, _, err := mode.fieldTags()
+	if err != nil {
+		panic(err)
+	}
+	if leafTag == nil {
+		return fieldSum(h, leaf)
+	}
+	var tag fr.Element
+	tag.SetBigInt(leafTag)
+	return fieldSum(h, &tag, leaf)
+}
+
+func fieldNodeSum(h hash.Hash, mode HashMode, left, right *fr.Element) fr.Element {
+	_, nodeTag, err := mode.fieldTags()
+	if err != nil {
+		panic(err)
+	}
+	if nodeTag == nil {
+		return fieldSum(h, left, right)
+	}
+	var tag fr.Element
+	tag.SetBigInt(nodeTag)
+	return fieldSum(h, &tag, left, right)
+}
+
+// FieldTree 是以 bn254 域元素为叶子的 merkle 树，哈希规则与电路完全一致
+// A FieldTree is a Merkle tree over bn254 field elements hashed with a
+// HashFunc. It has the same shape as Tree and hashes exactly like
+// ComputeMerkleRoot and ValidCircuit, so its roots and proofs can be used as
+// circuit witnesses without any byte conversion. Leaves are kept in memory.
+type FieldTree struct {
+	hash   hash.Hash
+	hf     HashFunc
+	mode   HashMode
+	leaves []fr.Element
+}
+
+// A FieldProof proves that Leaf is the leaf at Index of a FieldTree. Path
+// holds the siblings from the leaf up to the root and Helper[i] is 1 when the
+// node at height i is the right child, matching ValidCircuit.Helper.
+type FieldProof struct {
+	Index     uint64
+	NumLeaves uint64
+	Mode      HashMode
+	Hash      HashFunc
+	Leaf      fr.Element
+	Path      []fr.Element
+	Helper    []uint8
+}
+
+// NewFieldTree 创建以 Profile 的哈希函数按 mode 哈希的 FieldTree，叶子为 bn254 域元素，其他曲线返回错误
+// NewFieldTree creates an empty FieldTree hashed with the hash function of
+// 'p' in 'mode'. Leaves are bn254 field elements, so profiles on other curves
+// are rejected.
+func NewFieldTree(p Profile, mode HashMode) (*FieldTree, error) {
+	if err := p.checkBN254(); err != nil {
+		return nil, err
+	}
+	if _, _, err := mode.fieldTags(); err != nil {
+		return nil, err
+	}
+	return &FieldTree{
+		hash: p.NewHash(),
+		hf:   p.Hash,
+		mode: mode,
+	}, nil
+}
+
+// Push appends a leaf to the tree.
+func (t *FieldTree) Push(leaf fr.Element) {
+	t.leaves = append(t.leaves, leaf)
+}
+
+// Len returns the number of leaves in the tree.
+func (t *FieldTree) Len() int {
+	return len(t.leaves)
+}
+
+func (t *FieldTree) sums() []fr.Element {
+	sums := make([]fr.Element, len(t.leaves))
+	for i := range t.leaves {
+		sums[i] = fieldLeafSum(t.hash, t.mode, &t.leaves[i])
+	}
+	return sums
+}
+
+// Root returns the Merkle root of the tree.
+func (t *FieldTree) Root() (fr.Element, error) {
+	if len(t.leaves) == 0 {
+		return fr.Element{}, errors.New("cannot compute the root of an empty tree")
+	}
+	return fieldSubTreeRoot(t.hash, t.mode, t.sums()), nil
+}
+
+// Prove returns the root of the tree and a proof for the leaf at 'index'.
+func (t *FieldTree) Prove(index uint64) (fr.Element, *FieldProof, error) {
+	if index >= uint64(len(t.leaves)) {
+		return fr.Element{}, nil, fmt.Errorf("index %d out of range for %d leaves", index, len(t.leaves))
+	}
+	sums := t.sums()
+	proof := &FieldProof{
+		Index:     index,
+		NumLeaves: uint64(len(t.leaves)),
+		Mode:      t.mode,
+		Hash:      t.hf,
+		Leaf:      t.leaves[index],
+	}
+	fieldSubTreePath(t.hash, t.mode, sums, index, proof)
+	return fieldSubTreeRoot(t.hash, t.mode, sums), proof, nil
+}
+
+func fieldSubTreeRoot(h hash.Hash, mode HashMode, sums []fr.Element) fr.Element {
+	if len(sums) == 1 {
+		return sums[0]
+	}
+	k := splitPoint(uint64(len(sums)))
+	l := fieldSubTreeRoot(h, mode, sums[:k])
+	r := fieldSubTreeRoot(h, mode, sums[k:])
+	return fieldNodeSum(h, mode, &l, &r)
+}
+
+// fieldSubTreePath 与 subTreePath 相同，同时记录每一层的方向位
+func fieldSubTreePath(h hash.Hash, mode HashMode, sums []fr.Element, index uint64, proof *FieldProof) {
+	if len(sums) <= 1 {
+		return
+	}
+	k := splitPoint(uint64(len(sums)))
+	if index < k {
+		fieldSubTreePath(h, mode, sums[:k], index, proof)
+		proof.Path = append(proof.Path, fieldSubTreeRoot(h, mode, sums[k:]))
+		proof.Helper = append(proof.Helper, 0)
+		return
+	}
+	fieldSubTreePath(h, mode, sums[k:], index-k, proof)
+	proof.Path = append(proof.Path, fieldSubTreeRoot(h, mode, sums[:k]))
+	proof.Helper = append(proof.Helper, 1)
+}
+
+// proofHelper 返回 numLeaves 个叶子的树中第 index 个叶子从下到上的方向位
+func proofHelper(numLeaves, index uint64) []uint8 {
+	if numLeaves <= 1 {
+		return nil
+	}
+	k := splitPoint(numLeaves)
+	if index < k {
+		return append(proofHelper(k, index), 0)
+	}
+	return append(proofHelper(numLeaves-k, index-k), 1)
+}
+
+// Verify returns true if the proof is valid for the given root.
+func (p *FieldProof) Verify(root fr.Element) bool {
+	if len(p.Path) != len(p.Helper) || p.Index >= p.NumLeaves {
+		return false
+	}
+	helper := proofHelper(p.NumLeaves, p.Index)
+	if len(helper) != len(p.Helper) {
+		return false
+	}
+	for i := range helper {
+		if helper[i] != p.Helper[i] {
+			return false
+		}
+	}
+	if _, _, err := p.Mode.fieldTags(); err != nil || !p.Hash.Valid() {
+		return false
+	}
+	h := p.Hash.New()
+	curr := fieldLeafSum(h, p.Mode, &p.Leaf)
+	for i := range p.Path {
+		if p.Helper[i] == 0 {
+			curr = fieldNodeSum(h, p.Mode, &curr, &p.Path[i])
+		} else {
+			curr = fieldNodeSum(h, p.Mode, &p.Path[i], &curr)
+		}
+	}
+	return curr.Equal(&root)
+}
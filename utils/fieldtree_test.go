@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type fieldRootCircuit struct {
+	Root   frontend.Variable `gnark:",public"`
+	Leaves []frontend.Variable
+	Mode   HashMode `gnark:"-"`
+	Hash   HashFunc `gnark:"-"`
+}
+
+func (c *fieldRootCircuit) Define(api frontend.API) error {
+	root, err := ComputeMerkleRoot(api, Profile{Hash: c.Hash}, c.Mode, c.Leaves)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(root, c.Root)
+	return nil
+}
+
+func frToVariable(e *fr.Element) frontend.Variable {
+	return e.BigInt(new(big.Int))
+}
+
+func TestFieldTreeProfile(t *testing.T) {
+	if _, err := NewFieldTree(Profile{Curve: ecc.BLS12_381}, HashModeDomainTag); err == nil {
+		t.Fatal("field tree created on BLS12-381")
+	}
+	if _, err := NewFieldTree(Profile{Hash: HashPoseidon2}, HashMode(0xff)); err == nil {
+		t.Fatal("field tree created with an unknown hash mode")
+	}
+}
+
+// 差分测试：随机树的原生 root 必须与电路内计算的 root 一致
+func TestFieldTreeMatchesCircuit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sizes := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 13, 16, 17, 31, 32, 33, 64, 100}
+	for _, hf := range []HashFunc{HashMiMC, HashPoseidon2} {
+		for _, mode := range []HashMode{HashModePlain, HashModeRFC6962, HashModeDomainTag} {
+			for _, n := range sizes {
+				tree, err := NewFieldTree(Profile{Hash: hf}, mode)
+				if err != nil {
+					t.Fatal(err)
+				}
+				leaves := make([]frontend.Variable, n)
+				for i := 0; i < n; i++ {
+					var leaf fr.Element
+					leaf.SetRandom()
+					tree.Push(leaf)
+					leaves[i] = frToVariable(&leaf)
+				}
+				root, err := tree.Root()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				circuit := fieldRootCircuit{Leaves: make([]frontend.Variable, n), Mode: mode, Hash: hf}
+				assignment := fieldRootCircuit{Root: frToVariable(&root), Leaves: leaves}
+				if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+					t.Fatalf("%v %v n=%d: circuit root differs from native root: %v", hf, mode, n, err)
+				}
+
+				index := uint64(rng.Intn(n))
+				proofRoot, proof, err := tree.Prove(index)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !proofRoot.Equal(&root) || !proof.Verify(root) {
+					t.Fatalf("%v %v n=%d: native proof %d rejected", hf, mode, n, index)
+				}
+				path := make([]frontend.Variable, len(proof.Path))
+				helper := make([]frontend.Variable, len(proof.Helper))
+				for i := range proof.Path {
+					path[i] = frToVariable(&proof.Path[i])
+					helper[i] = proof.Helper[i]
+				}
+				valid := ValidCircuit{
+					Path:   make([]frontend.Variable, len(path)),
+					Helper: make([]frontend.Variable, len(path)),
+					Mode:   mode,
+					Hash:   hf,
+				}
+				validAssignment := ValidCircuit{
+					MerkleRoot: frToVariable(&root),
+					Message:    0,
+					Leaf:       frToVariable(&proof.Leaf),
+					Path:       path,
+					Helper:     helper,
+					Depth:      len(path),
+				}
+				if err := test.IsSolved(&valid, &validAssignment, ecc.BN254.ScalarField()); err != nil {
+					t.Fatalf("%v %v n=%d: ValidCircuit rejected native proof %d: %v", hf, mode, n, index, err)
+				}
+
+				// 字节树对相同叶子的 32 字节编码应得到相同的 root
+				byteTree := New1(hf.New())
+				if err := byteTree.SetHashMode(mode); err != nil {
+					t.Fatal(err)
+				}
+				for i := range tree.leaves {
+					b := tree.leaves[i].Bytes()
+					byteTree.Push1(b[:])
+				}
+				rootBytes := root.Bytes()
+				if !bytes.Equal(byteTree.Root1(), rootBytes[:]) {
+					t.Fatalf("%v %v n=%d: Tree and FieldTree roots differ", hf, mode, n)
+				}
+			}
+		}
+	}
+}
@@ -1,17 +1,178 @@
 package utils
 
 import (
+	"fmt"
+	"hash"
+
+	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
-	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/frontend"
+	stdhash "github.com/consensys/gnark/std/hash"
+)
+
+// HashFunc 是原生与电路内一一对应的哈希函数，所在的域由 Profile 的曲线决定
+// A HashFunc is a SNARK friendly hash with a native implementation and an
+// in-circuit gadget that agree on every input. The field it works in is the
+// scalar field of the Profile curve, BN254 by default. The zero value is
+// MiMC, the historical hash of the repository.
+type HashFunc uint8
+
+const (
+	// HashMiMC is MiMC in Miyaguchi-Preneel mode.
+	HashMiMC HashFunc = iota
+	// HashPoseidon2 is the Poseidon2 permutation in Merkle-Damgard mode with a
+	// zero IV. It costs a fraction of the constraints of MiMC.
+	HashPoseidon2
 )
 
-// Mimc 对字符串数据进行 MiMC 哈希处理，返回哈希结果（字节数组）
+// String returns the name of the hash function.
+func (f HashFunc) String() string {
+	switch f {
+	case HashMiMC:
+		return "mimc"
+	case HashPoseidon2:
+		return "poseidon2"
+	default:
+		return fmt.Sprintf("HashFunc(%d)", uint8(f))
+	}
+}
+
+// ParseHashFunc returns the hash function with the given name. The empty
+// string is MiMC.
+func ParseHashFunc(name string) (HashFunc, error) {
+	switch name {
+	case "", "mimc":
+		return HashMiMC, nil
+	case "poseidon2":
+		return HashPoseidon2, nil
+	default:
+		return 0, fmt.Errorf("unknown hash function %q", name)
+	}
+}
+
+// Valid returns true if f is a known hash function.
+func (f HashFunc) Valid() bool {
+	return f == HashMiMC || f == HashPoseidon2
+}
+
+// New 返回 BN254 上的原生哈希实例，可直接用于 New、New1 等
+// New returns a fresh native hash over the BN254 scalar field, i.e.
+// Profile{Hash: f}.NewHash(). Use a Profile for other curves.
+func (f HashFunc) New() hash.Hash {
+	return Profile{Curve: ecc.BN254, Hash: f}.NewHash()
+}
+
+// NewCircuit 返回 BN254 上电路内的哈希实例
+// NewCircuit returns the in-circuit counterpart of New. It fails when the
+// circuit is not compiled over the BN254 scalar field.
+func (f HashFunc) NewCircuit(api frontend.API) (stdhash.FieldHasher, error) {
+	return Profile{Curve: ecc.BN254, Hash: f}.NewCircuitHash(api)
+}
+
+// Sum 对字符串数据进行哈希处理，返回 BN254 上的哈希结果；其他曲线使用 Profile.Sum
+// Sum hashes a string attribute into a BN254 field element, as Mimc does for
+// MiMC. It is Profile{Hash: f}.Sum as an element; use Profile.Sum for
+// profiles on other curves.
+func (f HashFunc) Sum(data string) *fr.Element {
+	return new(fr.Element).SetBytes(leafSum(f.New(), []byte(data)))
+}
+
+// CredentialType 描述一类凭证使用的哈希函数与哈希方式，签发方、持有者与电路必须一致
+// A CredentialType fixes how the attributes of a kind of credential are
+// hashed. Issuers build their trees with NewTree and the circuits proving
+// statements about such credentials are built from the same type.
+type CredentialType struct {
+	Name  string
+	Curve ecc.ID // 零值为 BN254
+	Hash  HashFunc
+	Mode  HashMode
+	// 为 true 时机构的聚合签名在电路内验证（SignedCircuit），签名不再作为公开输入
+	Signature bool
+	// 为 true 时叶子绑定持有者：叶子数据为 HolderLeaf(secret, attributes...)，含 Attributes 个属性，
+	// 证明需要持有者秘密（见 ProveHolder）
+	Holder     bool
+	Attributes int
+	// 为 true 时证明同时输出验证方所在域内的 nullifier 并回应其挑战（见 ProveNullifier），需要 Holder
+	Nullifier bool
+	// 电路版本，同名电路的约束或参数变化时递增，零值为 1
+	Version int
+}
+
+// Profile returns the proof system profile of the type.
+func (c CredentialType) Profile() Profile {
+	return Profile{Curve: c.Curve, Hash: c.Hash}
+}
+
+// Validate checks the profile and hash mode of the type.
+func (c CredentialType) Validate() error {
+	if err := c.Profile().Validate(); err != nil {
+		return fmt.Errorf("credential type %q: %v", c.Name, err)
+	}
+	if !c.Mode.Valid() {
+		return fmt.Errorf("credential type %q: unknown hash mode %v", c.Name, c.Mode)
+	}
+	if c.Version < 0 {
+		return fmt.Errorf("credential type %q: invalid version %d", c.Name, c.Version)
+	}
+	if c.Attributes < 0 || (c.Attributes > 0 && !c.Holder) {
+		return fmt.Errorf("credential type %q: %d holder attributes without holder binding", c.Name, c.Attributes)
+	}
+	if c.Nullifier && !c.Holder {
+		return fmt.Errorf("credential type %q: nullifiers require holder binding", c.Name)
+	}
+	return nil
+}
+
+// NewTree 返回该凭证类型的 merkle 树，叶子由 Push1 按 Mode 哈希
+// NewTree returns an empty standard tree (see New1) for the type.
+func (c CredentialType) NewTree() (*Tree, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	t := New1(c.Profile().NewHash())
+	if err := t.SetHashMode(c.Mode); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Circuit 返回深度为 depth 的 ValidCircuit，用于编译该凭证类型的电路
+// Circuit returns a ValidCircuit placeholder for proofs of depth 'depth'.
+func (c CredentialType) Circuit(depth int) ValidCircuit {
+	circuit := ValidCircuit{
+		Path:   make([]frontend.Variable, depth),
+		Helper: make([]frontend.Variable, depth),
+		Mode:   c.Mode,
+		Hash:   c.Hash,
+		Curve:  c.Curve,
+	}
+	if c.Holder {
+		circuit.Holder = make([]frontend.Variable, 1+c.Attributes)
+	}
+	return circuit
+}
+
+// Placeholder 返回用于编译的电路：Signature 为 true 时为 SignedCircuit，否则为 ValidCircuit；
+// Nullifier 为 true 时分别为 SignedNullifierCircuit 与 NullifierCircuit
+// Placeholder returns the circuit to compile for proofs of depth 'depth',
+// a SignedCircuit when the signature is verified in-circuit, and their
+// NullifierCircuit counterparts when the type outputs nullifiers.
+func (c CredentialType) Placeholder(depth int) frontend.Circuit {
+	circuit := c.Circuit(depth)
+	switch {
+	case c.Nullifier && c.Signature:
+		return &SignedNullifierCircuit{NullifierCircuit: NullifierCircuit{ValidCircuit: circuit}}
+	case c.Nullifier:
+		return &NullifierCircuit{ValidCircuit: circuit}
+	case c.Signature:
+		return &SignedCircuit{ValidCircuit: circuit}
+	}
+	return &circuit
+}
+
+// Mimc 对字符串数据进行 BN254 上的 MiMC 哈希处理，即 DefaultProfile.Sum；其他曲线使用 Profile.Sum
 func Mimc(data string) *fr.Element {
-	input := []byte(data)
-	// 创建 MiMC 哈希器
-	hFunc := mimc.NewMiMC()
-	input = leafSum(hFunc, input)
-	return new(fr.Element).SetBytes(input)
+	return HashMiMC.Sum(data)
 	// var inputInt *big.Int
 
 	// // 判断传入的是字符还是数字
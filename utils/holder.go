@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// holderSecretTag 用于从密钥材料派生持有者秘密时的域分离
+var holderSecretTag = []byte("DID.holder.secret")
+
+// HolderSecret 由持有者的密钥材料（客户端写入 *_keypair.json 的 BLS 私钥）派生持有者秘密，
+// 密钥不变时秘密不变，且不泄露私钥本身。
+// HolderSecret derives the holder secret from the holder's key material,
+// i.e. the BLS private key clients keep in *_keypair.json. The secret is a
+// field element of the profile and reveals nothing about the key.
+func HolderSecret(p Profile, keyMaterial []byte) []byte {
+	h := sha256.New()
+	h.Write(holderSecretTag)
+	h.Write(keyMaterial)
+	s := new(big.Int).SetBytes(h.Sum(nil))
+	return s.Mod(s, p.Field()).FillBytes(make([]byte, 32))
+}
+
+// HolderLeaf 返回绑定持有者的叶子 H(secret, attributes...)，每个属性必须是单个域元素
+// HolderLeaf returns the holder bound leaf H(secret, attributes...). Only
+// the holder, who knows the secret, can prove membership of such a leaf
+// (see ValidCircuit.Holder and ProveHolder).
+func HolderLeaf(p Profile, secret []byte, attributes [][]byte) ([]byte, error) {
+	if err := checkAttributeElement(p, secret); err != nil {
+		return nil, fmt.Errorf("holder secret: %v", err)
+	}
+	for i, a := range attributes {
+		if err := checkAttributeElement(p, a); err != nil {
+			return nil, fmt.Errorf("attribute %d: %v", i, err)
+		}
+	}
+	return sum(p.NewHash(), append([][]byte{secret}, attributes...)...), nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+// 绑定持有者的凭证类型：证明流程在 ValidCircuit 内检查叶子是持有者秘密的承诺
+func TestProveHolder(t *testing.T) {
+	p := DefaultProfile
+	alice := HolderSecret(p, []byte("alice key"))
+	bob := HolderSecret(p, []byte("bob key"))
+	if !bytes.Equal(alice, HolderSecret(p, []byte("alice key"))) {
+		t.Fatal("holder secret is not deterministic")
+	}
+	if bytes.Equal(alice, bob) {
+		t.Fatal("different keys derived the same secret")
+	}
+	if _, err := HolderLeaf(p, alice, [][]byte{make([]byte, 33)}); err == nil {
+		t.Fatal("attribute larger than a field element accepted")
+	}
+	attributes := [][]byte{p.Sum("doctor")}
+	ct := CredentialType{Name: "test", Mode: HashModePlain, Holder: true, Attributes: 1}
+	setup, err := Setup(ct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := setup.Manifest.CredentialType()
+	if err != nil || !loaded.Holder || loaded.Attributes != 1 {
+		t.Fatalf("holder binding not recorded in the manifest: %+v %v", loaded, err)
+	}
+
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(1)
+	for _, secret := range [][]byte{bob, alice, bob} {
+		leaf, err := HolderLeaf(p, secret, attributes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Push(leaf)
+	}
+	root, proofSet, proofIndex, numLeaves := tree.Prove()
+	sks, pks := testAuthorities(t, 2)
+	aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+
+	proof, public, err := ProveHolder(setup, aggPK, aggSig, alice, attributes, proofSet, proofIndex, numLeaves, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(setup.VerifyingKey(), proof, public, pks); err != nil {
+		t.Fatalf("holder proof rejected: %v", err)
+	}
+	if _, _, err := ProveHolder(setup, aggPK, aggSig, bob, attributes, proofSet, proofIndex, numLeaves, root); err == nil {
+		t.Fatal("proof generated with another holder's secret")
+	}
+	if _, _, err := Prove(setup, aggPK, aggSig, proofSet[0], proofSet, proofIndex, numLeaves, root); err == nil {
+		t.Fatal("holder bound credential proven without the secret")
+	}
+
+	// 复制叶子与路径但不知道秘密，电路拒绝
+	mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	circuit := ct.Circuit(2)
+	assignment := mw.Circuit(root, proofSet[0])
+	assignment.Holder = BytesArrayToVariables([][]byte{alice, attributes[0]})
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("holder opening rejected: %v", err)
+	}
+	assignment.Holder = BytesArrayToVariables([][]byte{bob, attributes[0]})
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("wrong secret accepted")
+	}
+	assignment.Holder = BytesArrayToVariables([][]byte{alice, p.Sum("nurse")})
+	if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("wrong attributes accepted")
+	}
+
+	if _, err := (CredentialType{Name: "test", Attributes: 1}).NewTree(); err == nil {
+		t.Fatal("holder attributes accepted without holder binding")
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
+	blsfr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+// Keypair 是机构或持有者的 BLS 密钥对
+// A Keypair is the BLS key pair of an authority or a holder. The holder
+// secret of a client is derived from its private key (see HolderSecret), so
+// the key pair must survive restarts.
+type Keypair struct {
+	PrivateKey blsfr.Element     // 私钥
+	PublicKey  bls12381.G1Affine // 公钥
+}
+
+// keypairFile 是 certs/<name>/<name>_keypair.json 的格式：域元素按蒙哥马利形式的原始 limb 序列化
+type keypairFile struct {
+	PrivateKey [blsfr.Limbs]uint64
+	PublicKey  struct {
+		X, Y [fp.Limbs]uint64
+	}
+}
+
+// LoadKeypair 读取密钥对文件，文件不存在时生成新的密钥对并写入，已有的密钥对不会被覆盖
+// LoadKeypair reads the key pair stored in 'filename', checking that the
+// public key matches the private key. When the file does not exist, a new
+// key pair is generated and written to it.
+func LoadKeypair(filename string) (Keypair, error) {
+	var kp Keypair
+	var f keypairFile
+	_, _, g1Gen, _ := bls12381.Generators()
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		if err := json.Unmarshal(data, &f); err != nil {
+			return kp, fmt.Errorf("%s: %v", filename, err)
+		}
+		kp.PrivateKey = blsfr.Element(f.PrivateKey)
+		kp.PublicKey.X, kp.PublicKey.Y = fp.Element(f.PublicKey.X), fp.Element(f.PublicKey.Y)
+		var pk bls12381.G1Affine
+		pk.ScalarMultiplication(&g1Gen, kp.PrivateKey.BigInt(new(big.Int)))
+		if !pk.Equal(&kp.PublicKey) {
+			return kp, fmt.Errorf("%s: public key does not match the private key", filename)
+		}
+		return kp, nil
+	}
+	if !os.IsNotExist(err) {
+		return kp, err
+	}
+	if _, err := kp.PrivateKey.SetRandom(); err != nil {
+		return kp, err
+	}
+	kp.PublicKey.ScalarMultiplication(&g1Gen, kp.PrivateKey.BigInt(new(big.Int)))
+	f.PrivateKey = kp.PrivateKey
+	f.PublicKey.X, f.PublicKey.Y = kp.PublicKey.X, kp.PublicKey.Y
+	data, err = json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return kp, err
+	}
+	return kp, os.WriteFile(filename, data, 0600)
+}
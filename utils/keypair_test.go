@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeypair(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alice_keypair.json")
+	created, err := LoadKeypair(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 再次加载得到同一个密钥对，持有者秘密保持不变
+	loaded, err := LoadKeypair(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.PrivateKey.Equal(&created.PrivateKey) || !loaded.PublicKey.Equal(&created.PublicKey) {
+		t.Fatal("existing key pair overwritten")
+	}
+
+	other, err := LoadKeypair(filepath.Join(t.TempDir(), "bob_keypair.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := []byte(`{"PrivateKey":[1,2,3,4],"PublicKey":{"X":[0,0,0,0,0,0],"Y":[0,0,0,0,0,0]}}`)
+	if err := os.WriteFile(path, mismatched, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeypair(path); err == nil {
+		t.Fatal("key pair with a mismatching public key loaded")
+	}
+	if other.PrivateKey.Equal(&created.PrivateKey) {
+		t.Fatal("two key pairs share a private key")
+	}
+}
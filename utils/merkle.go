@@ -30,6 +30,8 @@ type Tree struct {
 	// combined, and then inserted as a subtree of height n + 1.
 	head *subTree
 	hash hash.Hash
+	//叶子与内部节点的哈希方式，只能在插入数据前通过 SetHashMode 修改
+	mode HashMode
 
 	// Helper variables used to construct proofs that the data at 'proofIndex'
 	// is in the Merkle tree. The proofSet is constructed as elements are being
@@ -52,7 +54,7 @@ type Tree struct {
 	//是否开启缓存优化。为 true 时，插入数据时用不同的方式创建头部子树
 	cachedTree bool
 
-	//添加叶子结点用于还原merkle tree
+	//缓存树中记录的叶子，用于 BuildProof 重新生成 merkle proof
 	leaves [][]byte
 }
 
@@ -86,29 +88,112 @@ func sum(h hash.Hash, data ...[]byte) []byte {
 	return h.Sum(nil)
 }
 
-// leafSum returns the hash created from data inserted to form a leaf. Leaf
-// sums are calculated using:
+// leafSum returns the hash created from data inserted to form a leaf in
+// HashModePlain. Leaf sums are calculated using:
 //
-//	Hash(0x00 || data)
+//	Hash(data)
 //
 // 返回叶子节点的hash
 func leafSum(h hash.Hash, data []byte) []byte {
-
-	//return sum(h, leafHashPrefix, data)
-	return sum(h, data)
+	return HashModePlain.LeafSum(h, data)
 }
 
 // nodeSum returns the hash created from two sibling nodes being combined into
-// a parent node. Node sums are calculated using:
+// a parent node in HashModePlain. Node sums are calculated using:
 //
-//	Hash(0x01 || left sibling sum || right sibling sum)
+//	Hash(left sibling sum || right sibling sum)
 //
 // 返回两个兄弟节点的hash
 func nodeSum(h hash.Hash, a, b []byte) []byte {
-	//将a、b合并后拆分
+	return HashModePlain.NodeSum(h, a, b)
+}
+
+// HashMode 决定叶子节点与内部节点的哈希是否做域分离
+// A HashMode selects how leaf and node sums are domain separated. Without
+// separation an interior node can be presented as a leaf, which allows second
+// preimage attacks on proofs.
+type HashMode uint8
+
+const (
+	// HashModePlain hashes leaves and nodes without any prefix. It is the
+	// historical behaviour of Tree and is kept as the zero value.
+	HashModePlain HashMode = iota
+
+	// HashModeRFC6962 prefixes leaves with 0x00 and nodes with 0x01 as in
+	// RFC 6962. Each prefix is written separately, so MiMC absorbs it as the
+	// field element 0 or 1.
+	HashModeRFC6962
+
+	// HashModeDomainTag prefixes leaves and nodes with distinct field element
+	// tags. The tags are circuit constants and cost a single extra MiMC round
+	// each.
+	HashModeDomainTag
+)
+
+var (
+	leafHashPrefix = []byte{0x00}
+	nodeHashPrefix = []byte{0x01}
+
+	// 域标签按大端序解释为 bn254/bls12-381 标量域中的元素
+	leafDomainTag = []byte("DID.merkle.leaf")
+	nodeDomainTag = []byte("DID.merkle.node")
+)
 
-	//return sum(h, nodeHashPrefix, a, b)
-	return sum(h, a, b)
+// String returns the name of the hash mode.
+func (m HashMode) String() string {
+	switch m {
+	case HashModePlain:
+		return "plain"
+	case HashModeRFC6962:
+		return "rfc6962"
+	case HashModeDomainTag:
+		return "domain-tag"
+	default:
+		return fmt.Sprintf("HashMode(%d)", uint8(m))
+	}
+}
+
+// ParseHashMode parses the name returned by String. An empty name is
+// HashModePlain.
+func ParseHashMode(name string) (HashMode, error) {
+	for m := HashModePlain; m.Valid(); m++ {
+		if name == m.String() {
+			return m, nil
+		}
+	}
+	if name == "" {
+		return HashModePlain, nil
+	}
+	return 0, fmt.Errorf("unknown hash mode %q", name)
+}
+
+// Valid reports whether m is a known hash mode.
+func (m HashMode) Valid() bool {
+	return m <= HashModeDomainTag
+}
+
+// LeafSum returns the leaf sum of data in the hash mode.
+func (m HashMode) LeafSum(h hash.Hash, data []byte) []byte {
+	switch m {
+	case HashModeRFC6962:
+		return sum(h, leafHashPrefix, data)
+	case HashModeDomainTag:
+		return sum(h, leafDomainTag, data)
+	default:
+		return sum(h, data)
+	}
+}
+
+// NodeSum returns the sum of two sibling sums in the hash mode.
+func (m HashMode) NodeSum(h hash.Hash, a, b []byte) []byte {
+	switch m {
+	case HashModeRFC6962:
+		return sum(h, nodeHashPrefix, a, b)
+	case HashModeDomainTag:
+		return sum(h, nodeDomainTag, a, b)
+	default:
+		return sum(h, a, b)
+	}
 }
 
 // joinSubTrees combines two equal sized subTrees into a larger subTree.
@@ -121,16 +206,10 @@ func (t *Tree) joinSubTrees(h hash.Hash, a, b *subTree) *subTree {
 	// 		panic("invalid subtree presented - height mismatch")
 	// 	}
 	// }
-	if a.height == 0 && b.height == 0 {
-		combineSums(a, b)
-		t.leaves = append(t.leaves, a.sum)
-		t.leaves = append(t.leaves, b.sum)
-	}
-
 	return &subTree{
 		next:   a.next,
 		height: a.height + 1,
-		sum:    nodeSum(h, a.sum, b.sum),
+		sum:    t.mode.NodeSum(h, a.sum, b.sum),
 	}
 }
 
@@ -230,8 +309,9 @@ func (t *Tree) Push(data []byte) {
 	}
 	if t.cachedTree {
 		t.head.sum = data
+		t.leaves = append(t.leaves, data)
 	} else {
-		t.head.sum = leafSum(t.hash, data)
+		t.head.sum = t.mode.LeafSum(t.hash, data)
 	}
 
 	// Join subTrees if possible.
@@ -309,6 +389,26 @@ func (t *Tree) SetIndex(i uint64) error {
 	return nil
 }
 
+// SetHashMode 设置树的哈希方式，必须在 Push 之前调用
+// SetHashMode selects how leaves and nodes are hashed. It can only be called
+// before any data is pushed. For cached trees the pushed data must already be
+// leaf sums computed with the same mode.
+func (t *Tree) SetHashMode(mode HashMode) error {
+	if t.head != nil {
+		return errors.New("cannot call SetHashMode on Tree if Tree has not been reset")
+	}
+	if !mode.Valid() {
+		return fmt.Errorf("unknown hash mode %v", mode)
+	}
+	t.mode = mode
+	return nil
+}
+
+// HashMode returns the hash mode of the tree.
+func (t *Tree) HashMode() HashMode {
+	return t.mode
+}
+
 func (t *Tree) joinAllSubTrees() {
 	// 防止 proofSet 是空的情况下死循环
 	if len(t.proofSet) == 0 {
@@ -349,68 +449,58 @@ func frToBytes32(e *fr.Element) []byte {
 	return b[:]
 }
 
-func combineSums(a, b *subTree) {
-	if len(a.sum) != 64 || len(b.sum) != 64 {
-		panic("sum should be 64 bytes")
-	}
-
-	// 分割出每一部分（32 字节）
-	p1a := new(fr.Element).SetBytes(a.sum[:32])
-	p2a := new(fr.Element).SetBytes(a.sum[32:])
-	p1b := new(fr.Element).SetBytes(b.sum[:32])
-	p2b := new(fr.Element).SetBytes(b.sum[32:])
-
-	// 执行加法
-	sumA := new(fr.Element).Add(p1a, p1b) // a 的新值
-	sumB := new(fr.Element).Add(p2a, p2b) // b 的新值
-
-	// 更新 sum（只保留 32 字节结果）
-	a.sum = frToBytes32(sumA)
-	fmt.Println("还原hash:", new(fr.Element).SetBytes(a.sum))
-	b.sum = frToBytes32(sumB)
-	fmt.Println("还原hash:", new(fr.Element).SetBytes(b.sum))
-}
-
-func (t *Tree) BuildProof(index uint64) ([][]byte, []byte, error) {
+// BuildProof 根据 Push 过程中记录的叶子重新生成 merkle proof。
+// BuildProof rebuilds a proof for the leaf at 'index' of a cached tree from
+// the leaves recorded while pushing. The recorded leaves are already leaf sums, so
+// the returned proof has LeafHashed set. The proof follows the same orphan
+// rules as Prove and can be checked with VerifyCachedProof.
+func (t *Tree) BuildProof(index uint64) (*Proof, []byte, error) {
 	if index >= uint64(len(t.leaves)) {
 		return nil, nil, fmt.Errorf("index out of range")
 	}
 
-	// 生成叶子哈希
-	leafHashes := make([][]byte, len(t.leaves))
-	for i, leaf := range t.leaves {
-		leafHashes[i] = leafSum(t.hash, leaf)
+	proof := &Proof{
+		Index:      index,
+		NumLeaves:  uint64(len(t.leaves)),
+		Mode:       t.mode,
+		LeafHashed: true,
+		Leaf:       append([]byte(nil), t.leaves[index]...),
+		Path:       subTreePath(t.hash, t.mode, t.leaves, index),
 	}
+	return proof, subTreeRoot(t.hash, t.mode, t.leaves), nil
+}
 
-	// 构造 proof path
-	var proof [][]byte
-	i := index
-	nodes := leafHashes
-
-	for len(nodes) > 1 {
-		var nextLevel [][]byte
-		for j := 0; j < len(nodes); j += 2 {
-			left := nodes[j]
-			var right []byte
-			if j+1 < len(nodes) {
-				right = nodes[j+1]
-			} else {
-				right = left
-			}
-
-			if uint64(j) == i {
-				proof = append(proof, right)
-				i = uint64(len(nextLevel))
-			} else if uint64(j+1) == i {
-				proof = append(proof, left)
-				i = uint64(len(nextLevel))
-			}
+// splitPoint returns the largest power of two smaller than n. It is the size
+// of the left subtree when n leaves are collapsed into a root by Tree. It
+// stops at 1<<63, so it terminates for every n, including the leaf counts
+// of untrusted proofs.
+func splitPoint(n uint64) uint64 {
+	k := uint64(1)
+	for k < 1<<63 && k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
 
-			nextLevel = append(nextLevel, nodeSum(t.hash, left, right))
-		}
-		nodes = nextLevel
+// subTreeRoot returns the Merkle root of a list of leaf sums, combining
+// orphans the same way Root does.
+func subTreeRoot(h hash.Hash, mode HashMode, sums [][]byte) []byte {
+	if len(sums) == 1 {
+		return sums[0]
 	}
+	k := splitPoint(uint64(len(sums)))
+	return mode.NodeSum(h, subTreeRoot(h, mode, sums[:k]), subTreeRoot(h, mode, sums[k:]))
+}
 
-	root := nodes[0]
-	return proof, root, nil
+// subTreePath returns the sibling sums, ordered from the leaf up to the root,
+// that prove the leaf sum at 'index' is part of subTreeRoot(h, sums).
+func subTreePath(h hash.Hash, mode HashMode, sums [][]byte, index uint64) [][]byte {
+	if len(sums) <= 1 {
+		return nil
+	}
+	k := splitPoint(uint64(len(sums)))
+	if index < k {
+		return append(subTreePath(h, mode, sums[:k], index), subTreeRoot(h, mode, sums[k:]))
+	}
+	return append(subTreePath(h, mode, sums[k:], index-k), subTreeRoot(h, mode, sums[:k]))
 }
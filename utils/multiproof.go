@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// MultiProof 一次证明多个叶子属于同一棵 merkle 树，只携带无法由已披露叶子推出的子树根。
+// A MultiProof shows that several leaves are part of the same tree. Hashes
+// holds the roots of the maximal subtrees that contain none of the disclosed
+// leaves, in depth-first left-to-right order, which is the minimal set of
+// sibling sums needed to rebuild the root. Indices are strictly increasing
+// and Leaves[i] is the leaf at Indices[i]. As for Proof, LeafHashed tells
+// whether the leaves are leaf data or already leaf sums.
+type MultiProof struct {
+	Indices    []uint64
+	NumLeaves  uint64
+	Mode       HashMode
+	LeafHashed bool
+	Leaves     [][]byte
+	Hashes     [][]byte
+}
+
+// BuildMultiProof 根据全部叶子数据生成多叶子证明
+// BuildMultiProof builds a multiproof for the leaves at 'indices' of the tree
+// made of 'leaves' hashed in 'mode'. The indices may be given in any order.
+func BuildMultiProof(h hash.Hash, mode HashMode, leaves [][]byte, indices []uint64) (*MultiProof, error) {
+	sums := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		sums[i] = mode.LeafSum(h, l)
+	}
+	proof, err := buildMultiProof(h, mode, sums, leaves, indices)
+	if err != nil {
+		return nil, err
+	}
+	proof.Mode = mode
+	return proof, nil
+}
+
+// BuildMultiProof 根据 Push 过程中记录的叶子生成多叶子证明，参见 BuildProof。
+// BuildMultiProof is the multiproof counterpart of BuildProof for cached trees.
+func (t *Tree) BuildMultiProof(indices []uint64) (*MultiProof, []byte, error) {
+	if len(t.leaves) == 0 {
+		return nil, nil, errors.New("tree has no recorded leaves")
+	}
+	proof, err := buildMultiProof(t.hash, t.mode, t.leaves, t.leaves, indices)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof.Mode = t.mode
+	proof.LeafHashed = true
+	return proof, subTreeRoot(t.hash, t.mode, t.leaves), nil
+}
+
+func buildMultiProof(h hash.Hash, mode HashMode, sums, leaves [][]byte, indices []uint64) (*MultiProof, error) {
+	if len(indices) == 0 {
+		return nil, errors.New("no leaf to prove")
+	}
+	sorted := append([]uint64(nil), indices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if err := checkMultiProofIndices(sorted, uint64(len(sums))); err != nil {
+		return nil, err
+	}
+	proof := &MultiProof{
+		Indices:   sorted,
+		NumLeaves: uint64(len(sums)),
+		Leaves:    make([][]byte, len(sorted)),
+		Hashes:    multiProofHashes(h, mode, sums, sorted),
+	}
+	for i, idx := range sorted {
+		proof.Leaves[i] = append([]byte(nil), leaves[idx]...)
+	}
+	return proof, nil
+}
+
+// maxProofLeaves 是多叶子证明中叶子数的上限。证明的形状由叶子数递归决定，
+// 验证方在处理不受信任的证明前先检查叶子数
+const maxProofLeaves = 1 << 32
+
+func checkMultiProofIndices(indices []uint64, numLeaves uint64) error {
+	if numLeaves > maxProofLeaves {
+		return fmt.Errorf("%d leaves exceed the maximum of %d", numLeaves, uint64(maxProofLeaves))
+	}
+	for i, idx := range indices {
+		if idx >= numLeaves {
+			return fmt.Errorf("index %d out of range for %d leaves", idx, numLeaves)
+		}
+		if i > 0 && indices[i-1] >= idx {
+			return fmt.Errorf("indices must be unique and increasing, got %d after %d", idx, indices[i-1])
+		}
+	}
+	return nil
+}
+
+// splitIndices 将有序索引按左子树大小 k 拆分，右侧索引减去 k
+func splitIndices(indices []uint64, k uint64) ([]uint64, []uint64) {
+	split := sort.Search(len(indices), func(i int) bool { return indices[i] >= k })
+	right := make([]uint64, len(indices)-split)
+	for i, idx := range indices[split:] {
+		right[i] = idx - k
+	}
+	return indices[:split], right
+}
+
+func multiProofHashes(h hash.Hash, mode HashMode, sums [][]byte, indices []uint64) [][]byte {
+	if len(indices) == 0 {
+		return [][]byte{subTreeRoot(h, mode, sums)}
+	}
+	if len(sums) == 1 {
+		return nil
+	}
+	k := splitPoint(uint64(len(sums)))
+	left, right := splitIndices(indices, k)
+	return append(multiProofHashes(h, mode, sums[:k], left), multiProofHashes(h, mode, sums[k:], right)...)
+}
+
+// multiProofShape 返回证明所需的子树根数量
+func multiProofShape(numLeaves uint64, indices []uint64) int {
+	if len(indices) == 0 {
+		return 1
+	}
+	if numLeaves == 1 {
+		return 0
+	}
+	k := splitPoint(numLeaves)
+	left, right := splitIndices(indices, k)
+	return multiProofShape(k, left) + multiProofShape(numLeaves-k, right)
+}
+
+// Verify 验证多叶子证明
+// Verify returns true if the multiproof shows that every leaf is part of the
+// tree with the given root.
+func (p *MultiProof) Verify(h hash.Hash, merkleRoot []byte) bool {
+	if merkleRoot == nil || len(p.Indices) == 0 || len(p.Indices) != len(p.Leaves) {
+		return false
+	}
+	if checkMultiProofIndices(p.Indices, p.NumLeaves) != nil {
+		return false
+	}
+	if len(p.Hashes) != multiProofShape(p.NumLeaves, p.Indices) {
+		return false
+	}
+	sums := make([][]byte, len(p.Leaves))
+	for i, l := range p.Leaves {
+		sums[i] = l
+		if !p.LeafHashed {
+			sums[i] = p.Mode.LeafSum(h, l)
+		}
+	}
+	hashes := p.Hashes
+	root := multiProofRoot(h, p.Mode, p.NumLeaves, p.Indices, sums, &hashes)
+	return bytes.Equal(root, merkleRoot)
+}
+
+// VerifyMultiProof 是 MultiProof.Verify 的函数形式
+// VerifyMultiProof verifies the leaves at 'indices' against 'merkleRoot'
+// using the subtree roots in 'hashes'.
+func VerifyMultiProof(h hash.Hash, mode HashMode, merkleRoot []byte, leaves [][]byte, indices []uint64, numLeaves uint64, hashes [][]byte) bool {
+	p := MultiProof{
+		Indices:   indices,
+		NumLeaves: numLeaves,
+		Mode:      mode,
+		Leaves:    leaves,
+		Hashes:    hashes,
+	}
+	return p.Verify(h, merkleRoot)
+}
+
+func multiProofRoot(h hash.Hash, mode HashMode, numLeaves uint64, indices []uint64, sums [][]byte, hashes *[][]byte) []byte {
+	if len(indices) == 0 {
+		next := (*hashes)[0]
+		*hashes = (*hashes)[1:]
+		return next
+	}
+	if numLeaves == 1 {
+		return sums[0]
+	}
+	k := splitPoint(numLeaves)
+	left, right := splitIndices(indices, k)
+	l := multiProofRoot(h, mode, k, left, sums[:len(left)], hashes)
+	r := multiProofRoot(h, mode, numLeaves-k, right, sums[len(left):], hashes)
+	return mode.NodeSum(h, l, r)
+}
+
+// MerkleMultiProof 是 MultiProof 在电路内的对应结构。证明的形状（索引、叶子数、
+// 哈希方式）在编译时确定，通常由凭证的属性布局决定。
+// MerkleMultiProof is the in-circuit counterpart of MultiProof. The shape of
+// the proof is fixed at compile time. Leaves follow the ValidCircuit.Leaf
+// convention: they are leaf data and are always hashed in-circuit per Mode.
+type MerkleMultiProof struct {
+	Indices   []uint64 `gnark:"-"`
+	NumLeaves uint64   `gnark:"-"`
+	Mode      HashMode `gnark:"-"`
+	Hash      HashFunc `gnark:"-"` // 与生成证明的哈希一致，默认为 MiMC
+	Curve     ecc.ID   `gnark:"-"` // 与生成证明的 Profile 一致，默认为 BN254
+
+	Leaves []frontend.Variable
+	Hashes []frontend.Variable
+}
+
+// NewMerkleMultiProof 返回用于编译电路的空结构
+// NewMerkleMultiProof returns a circuit placeholder for proofs of the leaves
+// at 'indices' in a tree of 'numLeaves' leaves.
+func NewMerkleMultiProof(indices []uint64, numLeaves uint64, mode HashMode) (MerkleMultiProof, error) {
+	sorted := append([]uint64(nil), indices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) == 0 {
+		return MerkleMultiProof{}, errors.New("no leaf to prove")
+	}
+	if err := checkMultiProofIndices(sorted, numLeaves); err != nil {
+		return MerkleMultiProof{}, err
+	}
+	return MerkleMultiProof{
+		Indices:   sorted,
+		NumLeaves: numLeaves,
+		Mode:      mode,
+		Leaves:    make([]frontend.Variable, len(sorted)),
+		Hashes:    make([]frontend.Variable, multiProofShape(numLeaves, sorted)),
+	}, nil
+}
+
+// WithLeafData 将缓存树证明中的叶子哈希替换为叶子数据，data[i] 为 Indices[i] 处的叶子数据
+// WithLeafData returns a copy of a multiproof of a cached tree, whose
+// leaves are leaf sums, with the leaves replaced by their data. data[i] must
+// hash to Leaves[i] with Mode.LeafSum. Proofs of leaf data are returned as is.
+func (p *MultiProof) WithLeafData(h hash.Hash, data [][]byte) (*MultiProof, error) {
+	if !p.LeafHashed {
+		return p, nil
+	}
+	if len(data) != len(p.Leaves) {
+		return nil, fmt.Errorf("%d leaves for %d indices", len(data), len(p.Leaves))
+	}
+	opened := *p
+	opened.LeafHashed = false
+	opened.Leaves = make([][]byte, len(data))
+	for i, d := range data {
+		if !bytes.Equal(p.Mode.LeafSum(h, d), p.Leaves[i]) {
+			return nil, fmt.Errorf("leaf data at index %d does not match the leaf sum", p.Indices[i])
+		}
+		opened.Leaves[i] = append([]byte(nil), d...)
+	}
+	return &opened, nil
+}
+
+// Assign 将原生证明转换为电路赋值。电路内总是对叶子数据哈希，缓存树的证明需先用 WithLeafData 给出叶子数据
+// Assign returns the circuit assignment of a native multiproof. The circuit
+// hashes the leaves, so proofs of cached trees (LeafHashed) must be opened
+// with WithLeafData first.
+func (p *MultiProof) Assign() (MerkleMultiProof, error) {
+	if p.LeafHashed {
+		return MerkleMultiProof{}, errors.New("multiproof of leaf sums, the circuit needs the leaf data (see WithLeafData)")
+	}
+	return MerkleMultiProof{
+		Indices:   p.Indices,
+		NumLeaves: p.NumLeaves,
+		Mode:      p.Mode,
+		Leaves:    BytesArrayToVariables(p.Leaves),
+		Hashes:    BytesArrayToVariables(p.Hashes),
+	}, nil
+}
+
+// Root 在电路内计算 merkle root
+// Root recomputes the Merkle root from the disclosed leaves and hashes.
+func (p *MerkleMultiProof) Root(api frontend.API) (frontend.Variable, error) {
+	if len(p.Leaves) != len(p.Indices) || len(p.Hashes) != multiProofShape(p.NumLeaves, p.Indices) {
+		return nil, errors.New("multiproof shape does not match its indices")
+	}
+	sums := make([]frontend.Variable, len(p.Leaves))
+	for i, l := range p.Leaves {
+		s, err := circuitLeafSum(api, Profile{Curve: p.Curve, Hash: p.Hash}, p.Mode, l)
+		if err != nil {
+			return nil, err
+		}
+		sums[i] = s
+	}
+	hashes := p.Hashes
+	return p.root(api, p.NumLeaves, p.Indices, sums, &hashes)
+}
+
+func (p *MerkleMultiProof) root(api frontend.API, numLeaves uint64, indices []uint64, sums []frontend.Variable, hashes *[]frontend.Variable) (frontend.Variable, error) {
+	if len(indices) == 0 {
+		next := (*hashes)[0]
+		*hashes = (*hashes)[1:]
+		return next, nil
+	}
+	if numLeaves == 1 {
+		return sums[0], nil
+	}
+	k := splitPoint(numLeaves)
+	left, right := splitIndices(indices, k)
+	l, err := p.root(api, k, left, sums[:len(left)], hashes)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.root(api, numLeaves-k, right, sums[len(left):], hashes)
+	if err != nil {
+		return nil, err
+	}
+	return circuitNodeSum(api, Profile{Curve: p.Curve, Hash: p.Hash}, p.Mode, l, r)
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type multiProofCircuit struct {
+	Root  frontend.Variable `gnark:",public"`
+	Proof MerkleMultiProof
+}
+
+func (c *multiProofCircuit) Define(api frontend.API) error {
+	root, err := c.Proof.Root(api)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(root, c.Root)
+	return nil
+}
+
+func TestMultiProof(t *testing.T) {
+	h := mimc.NewMiMC()
+	for n := 1; n <= 11; n++ {
+		data := testLeaves(n)
+		sums := make([][]byte, n)
+		for i, d := range data {
+			sums[i] = HashModeRFC6962.LeafSum(h, d)
+		}
+		root := subTreeRoot(h, HashModeRFC6962, sums)
+
+		// 枚举所有非空索引子集
+		for mask := 1; mask < 1<<n && mask < 1<<8; mask++ {
+			var indices []uint64
+			for i := 0; i < n; i++ {
+				if mask&(1<<i) != 0 {
+					indices = append(indices, uint64(i))
+				}
+			}
+			proof, err := BuildMultiProof(h, HashModeRFC6962, data, indices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proof.Verify(h, root) {
+				t.Fatalf("n=%d indices=%v: multiproof rejected", n, indices)
+			}
+			if len(indices) == 1 {
+				single := subTreePath(h, HashModeRFC6962, sums, indices[0])
+				if len(single) != len(proof.Hashes) {
+					t.Fatalf("n=%d: single leaf multiproof has %d hashes, want %d", n, len(proof.Hashes), len(single))
+				}
+			}
+			proof.Leaves[0] = []byte("forged")
+			if proof.Verify(h, root) {
+				t.Fatalf("n=%d indices=%v: forged leaf accepted", n, indices)
+			}
+		}
+	}
+
+	// 叶子数由证明方给出，过大的叶子数必须被拒绝且不能使验证陷入死循环
+	proof, err := BuildMultiProof(h, HashModeRFC6962, testLeaves(4), []uint64{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []uint64{maxProofLeaves + 1, 1<<62 + 1, 1<<63 + 5, ^uint64(0)} {
+		forged := *proof
+		forged.NumLeaves = n
+		if forged.Verify(h, proof.Hashes[0]) {
+			t.Fatalf("multiproof for %d leaves accepted", n)
+		}
+	}
+	for n, k := range map[uint64]uint64{2: 1, 3: 2, 5: 4, 1<<63 + 1: 1 << 63, ^uint64(0): 1 << 63} {
+		if got := splitPoint(n); got != k {
+			t.Fatalf("splitPoint(%d) = %d, want %d", n, got, k)
+		}
+	}
+}
+
+func TestMultiProofCircuit(t *testing.T) {
+	h := mimc.NewMiMC()
+	data := testLeaves(7)
+	indices := []uint64{1, 2, 5}
+	proof, err := BuildMultiProof(h, HashModeDomainTag, data, indices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := make([][]byte, len(data))
+	for i, d := range data {
+		sums[i] = HashModeDomainTag.LeafSum(h, d)
+	}
+	root := subTreeRoot(h, HashModeDomainTag, sums)
+	if !proof.Verify(h, root) {
+		t.Fatal("multiproof rejected")
+	}
+
+	placeholder, err := NewMerkleMultiProof(indices, uint64(len(data)), HashModeDomainTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assigned, err := proof.Assign()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment := multiProofCircuit{Root: BytesToVariable(root), Proof: assigned}
+	if err := test.IsSolved(&multiProofCircuit{Proof: placeholder}, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	assignment.Proof.Hashes[0] = 0
+	if err := test.IsSolved(&multiProofCircuit{Proof: placeholder}, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("circuit accepted tampered multiproof")
+	}
+}
+
+// 缓存树（New，推入叶子哈希）与 New1 树（推入叶子数据）的 root 相同，
+// 缓存树的多叶子证明给出叶子数据后由同一电路验证
+func TestMultiProofCircuitCached(t *testing.T) {
+	h := mimc.NewMiMC()
+	data := testLeaves(5)
+	indices := []uint64{0, 4} // 5 个叶子时最后一个叶子落单
+	for _, mode := range []HashMode{HashModePlain, HashModeRFC6962, HashModeDomainTag} {
+		cached := New(h)
+		if err := cached.SetHashMode(mode); err != nil {
+			t.Fatal(err)
+		}
+		ct := CredentialType{Name: "test", Mode: mode}
+		tree, err := ct.NewTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range data {
+			cached.Push(mode.LeafSum(h, d))
+			tree.Push(d)
+		}
+		root := tree.Root()
+
+		proof, cachedRoot, err := cached.BuildMultiProof(indices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(cachedRoot, root) {
+			t.Fatalf("%v: cached and standard trees differ", mode)
+		}
+		if !proof.Verify(h, root) {
+			t.Fatalf("%v: cached multiproof rejected", mode)
+		}
+		if _, err := proof.Assign(); err == nil {
+			t.Fatalf("%v: multiproof of leaf sums assigned", mode)
+		}
+		if _, err := proof.WithLeafData(h, [][]byte{data[0], data[3]}); err == nil {
+			t.Fatalf("%v: wrong leaf data accepted", mode)
+		}
+		opened, err := proof.WithLeafData(h, [][]byte{data[0], data[4]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !opened.Verify(h, root) {
+			t.Fatalf("%v: opened multiproof rejected", mode)
+		}
+		assigned, err := opened.Assign()
+		if err != nil {
+			t.Fatal(err)
+		}
+		placeholder, err := NewMerkleMultiProof(indices, uint64(len(data)), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assignment := multiProofCircuit{Root: BytesToVariable(root), Proof: assigned}
+		if err := test.IsSolved(&multiProofCircuit{Proof: placeholder}, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%v: cached multiproof rejected in-circuit: %v", mode, err)
+		}
+		// 直接使用叶子哈希时电路再次哈希，必须拒绝
+		assignment.Proof.Leaves = BytesArrayToVariables(proof.Leaves)
+		if err := test.IsSolved(&multiProofCircuit{Proof: placeholder}, &assignment, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: leaf sums accepted as leaf data", mode)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark/frontend"
+)
+
+// HolderCommitment 返回持有者秘密的承诺 H(secret)，即不含属性的 HolderLeaf
+// HolderCommitment returns H(secret), the holder bound leaf without
+// attributes (see HolderLeaf).
+func HolderCommitment(p Profile, secret []byte) ([]byte, error) {
+	return HolderLeaf(p, secret, nil)
+}
+
+// NullifierDomain 将验证方的名称（如 "vote-2026"）映射为域元素
+// NullifierDomain maps the name of a verifier scope, e.g. "vote-2026", to a
+// field element of the profile.
+func NullifierDomain(p Profile, name string) []byte {
+	digest := sha256.Sum256([]byte(name))
+	d := new(big.Int).SetBytes(digest[:])
+	return d.Mod(d, p.Field()).FillBytes(make([]byte, 32))
+}
+
+// NewNonce 返回验证方的随机挑战，必须非零
+// NewNonce returns a random non-zero challenge a verifier sends to a holder.
+func NewNonce(p Profile) ([]byte, error) {
+	limit := new(big.Int).Sub(p.Field(), big.NewInt(1))
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, err
+	}
+	return n.Add(n, big.NewInt(1)).FillBytes(make([]byte, 32)), nil
+}
+
+// nullifierTag 与持有者叶子 H(secret, attributes...) 做域分离，
+// 否则单属性叶子 H(secret, a) 与 a 所在域的 nullifier 相同
+var nullifierTag = []byte("DID.nullifier")
+
+// Nullifier 返回 H(tag, secret, domain)。同一持有者在同一域内的 nullifier 固定，在不同域之间无法关联
+// Nullifier returns H(tag, secret, domain). It is the same for every proof
+// of a holder in a domain and unlinkable across domains. The fixed tag keeps
+// it apart from the holder bound leaf H(secret, attributes...), which is
+// otherwise a sum of the same shape.
+func Nullifier(p Profile, secret, domain []byte) ([]byte, error) {
+	if err := checkAttributeElement(p, secret); err != nil {
+		return nil, fmt.Errorf("holder secret: %v", err)
+	}
+	if err := checkAttributeElement(p, domain); err != nil {
+		return nil, fmt.Errorf("nullifier domain: %v", err)
+	}
+	return sum(p.NewHash(), p.tag(nullifierTag), secret, domain), nil
+}
+
+// NullifierCircuit 在 ValidCircuit 的基础上输出持有者在验证方域内的 nullifier。
+// Domain 与 Nonce 为公开输入，证明只能用于该验证方的这一次挑战。
+// A NullifierCircuit is a holder bound ValidCircuit that also outputs
+// Nullifier = H(tag, secret, Domain) for the holder secret Holder[0]. The
+// public Domain scopes the nullifier to a verifier and the public Nonce
+// binds the proof to one challenge of that verifier.
+type NullifierCircuit struct {
+	ValidCircuit
+	Domain    frontend.Variable `gnark:",public"`
+	Nonce     frontend.Variable `gnark:",public"`
+	Nullifier frontend.Variable `gnark:",public"`
+}
+
+func (c *NullifierCircuit) Define(api frontend.API) error {
+	if len(c.Holder) == 0 {
+		return errors.New("nullifier circuit without holder binding")
+	}
+	if err := c.ValidCircuit.Define(api); err != nil {
+		return err
+	}
+	p := c.Profile()
+	tag := new(big.Int).SetBytes(p.tag(nullifierTag))
+	nullifier, err := circuitSum(api, p, tag, c.Holder[0], c.Domain)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(nullifier, c.Nullifier)
+
+	// 未出现在任何约束中的公开输入不受证明约束，Nonce 必须参与一个约束
+	api.AssertIsDifferent(c.Nonce, 0)
+	return nil
+}
+
+// SignedNullifierCircuit 是同时在电路内验证机构聚合签名的 NullifierCircuit
+// A SignedNullifierCircuit is a NullifierCircuit that also verifies
+// in-circuit the aggregate signature of the authorities over MerkleRoot, as
+// SignedCircuit does.
+type SignedNullifierCircuit struct {
+	NullifierCircuit
+	Signature AggregateSignature
+}
+
+func (c *SignedNullifierCircuit) Define(api frontend.API) error {
+	if err := c.NullifierCircuit.Define(api); err != nil {
+		return err
+	}
+	return c.Signature.AssertValid(api, c.MerkleRoot)
+}
+
+// NullifierStatement 是验证方给出的公开参数：所在的域与本次挑战
+// A NullifierStatement is what a verifier asks a holder to prove against:
+// its domain and a fresh nonce.
+type NullifierStatement struct {
+	Domain []byte
+	Nonce  []byte
+}
+
+// Validate 检查域与挑战是 p 的域元素且挑战非零
+// Validate checks that the domain and nonce are field elements of 'p' and
+// that the nonce is non-zero.
+func (s NullifierStatement) Validate(p Profile) error {
+	if err := checkAttributeElement(p, s.Domain); err != nil {
+		return fmt.Errorf("nullifier domain: %v", err)
+	}
+	if err := checkAttributeElement(p, s.Nonce); err != nil {
+		return fmt.Errorf("nullifier nonce: %v", err)
+	}
+	if new(big.Int).SetBytes(s.Nonce).Sign() == 0 {
+		return errors.New("nonce must be non-zero")
+	}
+	return nil
+}
+
+// Accept 检查证明的公开输入回应的是本次挑战，并在 used 中记录其 nullifier。
+// 只能在证明通过 Verify 或 VerifyEnvelope 之后调用
+// Accept checks that the public inputs of a proof answer the statement and
+// records their nullifier in 'used', returning ErrNullifierUsed on a second
+// use in the domain. Call it only after the proof passed Verify or
+// VerifyEnvelope; VerifyNullifier does both.
+func (s NullifierStatement) Accept(public *PublicInputs, used *NullifierSet) error {
+	if public == nil || public.Nullifier == nil {
+		return errors.New("proof has no nullifier")
+	}
+	n := public.Nullifier
+	if !equalElements(n.Domain, s.Domain) {
+		return errors.New("proof is for another nullifier domain")
+	}
+	if !equalElements(n.Nonce, s.Nonce) {
+		return errors.New("proof answers another challenge")
+	}
+	return used.Use(s.Domain, n.Nullifier)
+}
+
+// equalElements 按域元素比较，忽略前导零
+func equalElements(a, b []byte) bool {
+	return new(big.Int).SetBytes(a).Cmp(new(big.Int).SetBytes(b)) == 0
+}
+
+// NullifierInputs 是 nullifier 证明额外的公开输入
+// NullifierInputs are the public inputs a NullifierCircuit adds to those of
+// ValidCircuit: the verifier's domain and nonce and the output nullifier.
+type NullifierInputs struct {
+	Domain    []byte `json:"domain"`
+	Nonce     []byte `json:"nonce"`
+	Nullifier []byte `json:"nullifier"`
+}
+
+// VerifyNullifier 用 Verify 验证证明，检查其回应了 s 并记录 nullifier
+// VerifyNullifier verifies a proof made by ProveNullifier with Verify, then
+// checks that it answers 's' and records its nullifier in 'used' (see
+// NullifierStatement.Accept).
+func VerifyNullifier(vk *VerifyingKey, proof *ZKProof, public *PublicInputs, authorities []bls12381.G1Affine, s NullifierStatement, used *NullifierSet) error {
+	if public == nil || public.Nullifier == nil {
+		return errors.New("proof has no nullifier")
+	}
+	if err := Verify(vk, proof, public, authorities); err != nil {
+		return err
+	}
+	return s.Accept(public, used)
+}
+
+// ErrNullifierUsed 在同一域内重复使用 nullifier 时返回
+var ErrNullifierUsed = errors.New("nullifier already used in this domain")
+
+// NullifierSet 记录验证方已接受的 nullifier，用于检测重复使用（如一人一票）
+// A NullifierSet records the nullifiers a verifier accepted, per domain, to
+// detect a second use of the same credential. It is safe for concurrent use.
+type NullifierSet struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewNullifierSet returns an empty set.
+func NewNullifierSet() *NullifierSet {
+	return &NullifierSet{seen: make(map[string]struct{})}
+}
+
+// nullifierKey 返回 "域:nullifier" 的十六进制形式，按域元素忽略前导零
+func nullifierKey(domain, nullifier []byte) string {
+	return new(big.Int).SetBytes(domain).Text(16) + ":" + new(big.Int).SetBytes(nullifier).Text(16)
+}
+
+// Use 记录 nullifier，已使用过时返回 ErrNullifierUsed
+// Use records 'nullifier' for 'domain', or returns ErrNullifierUsed if it
+// was already recorded. Call it only after the proof has been verified.
+func (s *NullifierSet) Use(domain, nullifier []byte) error {
+	key := nullifierKey(domain, nullifier)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[key]; ok {
+		return ErrNullifierUsed
+	}
+	s.seen[key] = struct{}{}
+	return nil
+}
+
+// Save 将已记录的 nullifier 写入 path，供验证方在多次运行之间检测重复使用
+// Save writes the recorded nullifiers to 'path' as a sorted JSON array, so
+// a verifier detects reuse across runs (see LoadNullifierSet).
+func (s *NullifierSet) Save(path string) error {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.seen))
+	for k := range s.seen {
+		keys = append(keys, k)
+	}
+	s.mu.Unlock()
+	sort.Strings(keys)
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadNullifierSet 读取 Save 写入的 nullifier，文件不存在时返回空集合
+// LoadNullifierSet reads a set written by Save. A missing file is an empty
+// set.
+func LoadNullifierSet(path string) (*NullifierSet, error) {
+	s := NewNullifierSet()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, k := range keys {
+		s.seen[k] = struct{}{}
+	}
+	return s, nil
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+func TestNullifier(t *testing.T) {
+	p := DefaultProfile
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag, Holder: true, Nullifier: true}
+	setup, err := Setup(ct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := setup.Manifest.CredentialType()
+	if err != nil || !loaded.Nullifier {
+		t.Fatalf("nullifier not recorded in the manifest: %+v %v", loaded, err)
+	}
+
+	// 叶子数据为各持有者的 H(secret)；3 个叶子时 carol 的叶子落单，路径比其他叶子短
+	alice := HolderSecret(p, []byte("alice key"))
+	carol := HolderSecret(p, []byte("carol key"))
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(2)
+	for _, s := range [][]byte{alice, HolderSecret(p, []byte("bob key")), carol} {
+		c, err := HolderCommitment(p, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Push(c)
+	}
+	root, proofSet, proofIndex, numLeaves := tree.Prove()
+	sks, pks := testAuthorities(t, 2)
+	aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+	vk := setup.VerifyingKey()
+
+	nonce, err := NewNonce(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vote := NullifierStatement{Domain: NullifierDomain(p, "vote-2026"), Nonce: nonce}
+	proof, public, err := ProveNullifier(setup, aggPK, aggSig, vote, carol, nil, proofSet, proofIndex, numLeaves, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := NewNullifierSet()
+	if err := VerifyNullifier(vk, proof, public, pks, vote, used); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+
+	// 同一持有者在同一域内再次证明（新的挑战）得到相同的 nullifier
+	vote2 := NullifierStatement{Domain: vote.Domain, Nonce: []byte{7}}
+	proof2, public2, err := ProveNullifier(setup, aggPK, aggSig, vote2, carol, nil, proofSet, proofIndex, numLeaves, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyNullifier(vk, proof2, public2, pks, vote2, used); !errors.Is(err, ErrNullifierUsed) {
+		t.Fatalf("double use not detected: %v", err)
+	}
+
+	// 证明不能用于另一个挑战：公开输入不变时挑战不符，改写公开输入时证明无效
+	if err := VerifyNullifier(vk, proof, public, pks, vote2, NewNullifierSet()); err == nil {
+		t.Fatal("proof accepted for another challenge")
+	}
+	replayed := *public
+	replayed.Nullifier = &NullifierInputs{Domain: vote2.Domain, Nonce: vote2.Nonce, Nullifier: public.Nullifier.Nullifier}
+	if err := VerifyNullifier(vk, proof, &replayed, pks, vote2, NewNullifierSet()); err == nil {
+		t.Fatal("proof replayed with another nonce")
+	}
+
+	// 不同域之间的 nullifier 无法关联
+	survey := NullifierStatement{Domain: NullifierDomain(p, "survey"), Nonce: nonce}
+	_, other, err := ProveNullifier(setup, aggPK, aggSig, survey, carol, nil, proofSet, proofIndex, numLeaves, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other.Nullifier.Nullifier, public.Nullifier.Nullifier) {
+		t.Fatal("nullifiers linkable across domains")
+	}
+
+	if _, _, err := ProveNullifier(setup, aggPK, aggSig, vote, alice, nil, proofSet, proofIndex, numLeaves, root); err == nil {
+		t.Fatal("proof generated with another holder's secret")
+	}
+	if _, _, err := ProveNullifier(setup, aggPK, aggSig, NullifierStatement{Domain: vote.Domain}, carol, nil, proofSet, proofIndex, numLeaves, root); err == nil {
+		t.Fatal("proof generated for a zero nonce")
+	}
+	if _, _, err := ProveHolder(setup, aggPK, aggSig, carol, nil, proofSet, proofIndex, numLeaves, root); err == nil {
+		t.Fatal("nullifier credential proven without a nullifier")
+	}
+	if _, err := NewAggregator(setup, 2); err == nil {
+		t.Fatal("aggregator built for nullifier proofs")
+	}
+
+	// 信封携带 nullifier，验证方由信封还原公开输入后检查挑战
+	e, err := NewEnvelope(&setup.Manifest, vk, proof, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := e.Encode(EnvelopeCBOR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := DecodeEnvelope(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEnvelope(vk, received, pks); err != nil {
+		t.Fatalf("nullifier envelope rejected: %v", err)
+	}
+	fromEnvelope, err := received.PublicInputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vote.Accept(fromEnvelope, NewNullifierSet()); err != nil {
+		t.Fatalf("envelope nullifier rejected: %v", err)
+	}
+	received.Nullifier = other.Nullifier
+	if err := received.Validate(); err == nil {
+		t.Fatal("envelope nullifier does not match its public inputs")
+	}
+}
+
+// nullifier 带有固定标签，与单属性的持有者叶子 H(secret, a) 形式不同
+func TestNullifierTag(t *testing.T) {
+	p := DefaultProfile
+	const depth = 2
+	ct := CredentialType{Name: "test", Mode: HashModePlain, Holder: true, Attributes: 1, Nullifier: true}
+	secret := HolderSecret(p, []byte("alice key"))
+	domain := NullifierDomain(p, "vote-2026")
+	nullifier, err := Nullifier(p, secret, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := HolderLeaf(p, secret, [][]byte{domain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(nullifier, leaf) {
+		t.Fatal("nullifier equals the holder leaf with the domain as attribute")
+	}
+
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(0)
+	tree.Push(leaf)
+	tree.Push([]byte("hello"))
+	root, proofSet, proofIndex, numLeaves := tree.Prove()
+	mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment := NullifierCircuit{
+		ValidCircuit: mw.Circuit(root, leaf),
+		Domain:       BytesToVariable(domain),
+		Nonce:        1,
+		Nullifier:    BytesToVariable(nullifier),
+	}
+	assignment.Holder = BytesArrayToVariables([][]byte{secret, domain})
+	circuit := ct.Placeholder(depth)
+	if err := test.IsSolved(circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("nullifier rejected: %v", err)
+	}
+	// 未加标签的 H(secret, domain) 即叶子本身，电路拒绝
+	assignment.Nullifier = BytesToVariable(leaf)
+	if err := test.IsSolved(circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("untagged nullifier accepted")
+	}
+	assignment.Nullifier, assignment.Nonce = BytesToVariable(nullifier), 0
+	if err := test.IsSolved(circuit, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("zero nonce accepted")
+	}
+
+	if _, err := (CredentialType{Name: "test", Nullifier: true}).NewTree(); err == nil {
+		t.Fatal("nullifier accepted without holder binding")
+	}
+}
+
+func TestNullifierSet(t *testing.T) {
+	p := DefaultProfile
+	domain := NullifierDomain(p, "vote-2026")
+	used := NewNullifierSet()
+	if err := used.Use(domain, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := used.Use(NullifierDomain(p, "survey"), []byte{1, 2}); err != nil {
+		t.Fatalf("nullifier of another domain rejected: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "nullifiers.json")
+	if err := used.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNullifierSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Use(domain, []byte{0, 1, 2}); !errors.Is(err, ErrNullifierUsed) {
+		t.Fatalf("reuse across runs not detected: %v", err)
+	}
+	if err := loaded.Use(domain, []byte{3}); err != nil {
+		t.Fatal(err)
+	}
+	empty, err := LoadNullifierSet(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || empty.Use(domain, []byte{1, 2}) != nil {
+		t.Fatalf("missing file is not an empty set: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+// NumericBits 是数值属性的比特数
+const NumericBits = 64
+
+// numericTag 标记数值属性的叶子，普通属性的叶子数据无法被当作数值
+var numericTag = []byte("DID.numeric")
+
+// NumericAttribute 是命名的数值属性，如 age = 20
+// A NumericAttribute is a named numeric attribute of a credential, e.g.
+// age = 20. Its leaf commits to a type tag, the name and the value, so
+// predicate circuits only accept leaves issued as that numeric attribute.
+type NumericAttribute struct {
+	Name  string
+	Value uint64
+}
+
+// Leaf 返回数值属性的叶子数据 H(tag, name, value)，由机构写入凭证树
+// Leaf returns the leaf data H(tag, name, value) issuers push into the
+// credential tree for the attribute.
+func (a NumericAttribute) Leaf(p Profile) []byte {
+	value := new(big.Int).SetUint64(a.Value).FillBytes(make([]byte, 32))
+	return sum(p.NewHash(), p.tag(numericTag), p.tag([]byte(a.Name)), value)
+}
+
+// PredicateOp 是数值属性上的比较
+// A PredicateOp is a comparison of a numeric attribute with public bounds.
+type PredicateOp uint8
+
+const (
+	// PredicateGE asserts value >= Lower.
+	PredicateGE PredicateOp = iota
+	// PredicateLE asserts value <= Upper.
+	PredicateLE
+	// PredicateInRange asserts Lower <= value <= Upper.
+	PredicateInRange
+)
+
+// String returns the name of the comparison.
+func (op PredicateOp) String() string {
+	switch op {
+	case PredicateGE:
+		return "ge"
+	case PredicateLE:
+		return "le"
+	case PredicateInRange:
+		return "range"
+	default:
+		return fmt.Sprintf("PredicateOp(%d)", uint8(op))
+	}
+}
+
+// RangePredicate 是对命名数值属性的公开约束，例如 age >= 18。未使用的边界必须为 0。
+// A RangePredicate is a public statement about a numeric attribute, e.g.
+// age >= 18 is {"age", PredicateGE, 18, 0}. The bound unused by Op must be
+// zero so that a predicate has a single public encoding.
+type RangePredicate struct {
+	Attribute string
+	Op        PredicateOp
+	Lower     uint64
+	Upper     uint64
+}
+
+// Validate checks the comparison and its bounds.
+func (p RangePredicate) Validate() error {
+	if p.Attribute == "" {
+		return errors.New("predicate names no attribute")
+	}
+	switch p.Op {
+	case PredicateGE:
+		if p.Upper != 0 {
+			return errors.New("ge predicate has an upper bound")
+		}
+	case PredicateLE:
+		if p.Lower != 0 {
+			return errors.New("le predicate has a lower bound")
+		}
+	case PredicateInRange:
+		if p.Lower > p.Upper {
+			return fmt.Errorf("empty range [%d, %d]", p.Lower, p.Upper)
+		}
+	default:
+		return fmt.Errorf("unknown predicate %v", p.Op)
+	}
+	return nil
+}
+
+// Holds reports whether v satisfies the predicate.
+func (p RangePredicate) Holds(v uint64) bool {
+	switch p.Op {
+	case PredicateGE:
+		return v >= p.Lower
+	case PredicateLE:
+		return v <= p.Upper
+	case PredicateInRange:
+		return p.Lower <= v && v <= p.Upper
+	default:
+		return false
+	}
+}
+
+// RangeCircuit 证明 merkle 树中名为 Attribute 的数值属性满足 Op，属性值与其位置都不公开。
+// 叶子在电路内由数值标签、属性名与属性值重新计算，因此属性值与 MerkleRoot 中的叶子绑定，
+// 其他属性或普通属性的叶子无法通过。
+// A RangeCircuit proves that the numeric attribute Attribute committed in a
+// leaf of the tree with root MerkleRoot satisfies a RangePredicate. The
+// value, leaf and path stay private. The leaf is recomputed in-circuit from
+// the numeric tag, the attribute name and Value (see NumericAttribute.Leaf),
+// so only leaves issued as that numeric attribute are accepted.
+type RangeCircuit struct {
+	MerkleRoot frontend.Variable `gnark:",public"`
+	Attribute  frontend.Variable `gnark:",public"`
+	Lower      frontend.Variable `gnark:",public"`
+	Upper      frontend.Variable `gnark:",public"`
+
+	Value  frontend.Variable   // 数值属性
+	Path   []frontend.Variable // Merkle 路径，按最大深度补 0
+	Helper []frontend.Variable // 方向位 (0=左, 1=右)，按最大深度补 0
+	Depth  frontend.Variable   // 路径的实际深度，见 MerkleWitness
+
+	Op    PredicateOp `gnark:"-"`
+	Mode  HashMode    `gnark:"-"`
+	Hash  HashFunc    `gnark:"-"`
+	Curve ecc.ID      `gnark:"-"`
+}
+
+// RangeCircuit 返回凭证类型 c 上最大深度为 depth 的 RangeCircuit，用于编译
+// RangeCircuit returns a RangeCircuit placeholder for proofs of depth up to
+// 'depth'.
+func (c CredentialType) RangeCircuit(op PredicateOp, depth int) RangeCircuit {
+	return RangeCircuit{
+		Path:   make([]frontend.Variable, depth),
+		Helper: make([]frontend.Variable, depth),
+		Op:     op,
+		Mode:   c.Mode,
+		Hash:   c.Hash,
+		Curve:  c.Curve,
+	}
+}
+
+func (c *RangeCircuit) Define(api frontend.API) error {
+	p := Profile{Curve: c.Curve, Hash: c.Hash}
+	tag := new(big.Int).SetBytes(p.tag(numericTag))
+	data, err := circuitSum(api, p, tag, c.Attribute, c.Value)
+	if err != nil {
+		return err
+	}
+	leaf, err := circuitLeafSum(api, p, c.Mode, data)
+	if err != nil {
+		return err
+	}
+	root, err := circuitPaddedPathRoot(api, p, c.Mode, leaf, c.Path, c.Helper, c.Depth)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(root, c.MerkleRoot)
+
+	// 所有数值都在 [0, 2^64) 内时，a - b 也在该范围内当且仅当 a >= b
+	rc := rangecheck.New(api)
+	rc.Check(c.Value, NumericBits)
+	switch c.Op {
+	case PredicateGE:
+		api.AssertIsEqual(c.Upper, 0)
+		rc.Check(c.Lower, NumericBits)
+		rc.Check(api.Sub(c.Value, c.Lower), NumericBits)
+	case PredicateLE:
+		api.AssertIsEqual(c.Lower, 0)
+		rc.Check(c.Upper, NumericBits)
+		rc.Check(api.Sub(c.Upper, c.Value), NumericBits)
+	case PredicateInRange:
+		rc.Check(c.Lower, NumericBits)
+		rc.Check(c.Upper, NumericBits)
+		rc.Check(api.Sub(c.Value, c.Lower), NumericBits)
+		rc.Check(api.Sub(c.Upper, c.Value), NumericBits)
+	default:
+		return fmt.Errorf("unknown predicate %v", c.Op)
+	}
+	return nil
+}
+
+// Assign 由原生 merkle 证明生成 RangeCircuit 的赋值，proofSet[0] 必须是属性值为 value 的
+// NumericAttribute 叶子，maxDepth 为电路的最大深度。属性不满足谓词时返回错误，不会生成无法通过的证明。
+// Assign returns the assignment of the type's RangeCircuit of depth
+// 'maxDepth' proving the predicate for the leaf proofSet[0] of a tree of
+// 'numLeaves' leaves built with ct.NewTree, as returned by Tree.Prove. The
+// leaf must be the NumericAttribute leaf of the predicate's attribute with
+// value 'value'.
+func (p RangePredicate) Assign(ct CredentialType, value uint64, root []byte, proofSet [][]byte, proofIndex, numLeaves uint64, maxDepth int) (*RangeCircuit, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if len(proofSet) == 0 {
+		return nil, errors.New("empty merkle proof")
+	}
+	leaf := NumericAttribute{Name: p.Attribute, Value: value}.Leaf(ct.Profile())
+	if !bytes.Equal(leaf, proofSet[0]) {
+		return nil, fmt.Errorf("leaf is not the numeric attribute %q with that value", p.Attribute)
+	}
+	if !p.Holds(value) {
+		return nil, errors.New("attribute does not satisfy the predicate")
+	}
+	mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	return &RangeCircuit{
+		MerkleRoot: BytesToVariable(root),
+		Attribute:  BytesToVariable(ct.Profile().tag([]byte(p.Attribute))),
+		Lower:      p.Lower,
+		Upper:      p.Upper,
+		Value:      value,
+		Path:       mw.Path,
+		Helper:     mw.Helper,
+		Depth:      mw.Depth,
+		Op:         p.Op,
+	}, nil
+}
+
+// Witness 返回验证方使用的公开 witness，只包含 root、属性名与谓词的边界
+// Witness returns the public witness of RangeCircuit for the predicate over
+// the tree with root 'root'.
+func (p RangePredicate) Witness(root []byte, curve ecc.ID) (witness.Witness, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	assignment := RangeCircuit{
+		MerkleRoot: BytesToVariable(root),
+		Attribute:  BytesToVariable(Profile{Curve: curve}.tag([]byte(p.Attribute))),
+		Lower:      p.Lower,
+		Upper:      p.Upper,
+	}
+	return frontend.NewWitness(&assignment, curve.ScalarField(), frontend.PublicOnly())
+}
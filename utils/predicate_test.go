@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+)
+
+// 凭证属性：第 index 个叶子为数值属性 attr，其余为普通属性
+func testNumericTree(t *testing.T, ct CredentialType, attr []byte, n, index uint64) ([]byte, [][]byte, uint64, uint64) {
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(index)
+	leaves := testLeaves(int(n))
+	leaves[index] = attr
+	for _, d := range leaves {
+		tree.Push(d)
+	}
+	return tree.Prove()
+}
+
+func TestRangeCircuit(t *testing.T) {
+	const maxDepth = 3
+	for _, mode := range []HashMode{HashModePlain, HashModeDomainTag} {
+		ct := CredentialType{Name: "test", Mode: mode}
+		age := NumericAttribute{Name: "age", Value: 20}
+		root, proofSet, proofIndex, numLeaves := testNumericTree(t, ct, age.Leaf(ct.Profile()), 4, 0)
+		assign := func(pred RangePredicate, value uint64) (*RangeCircuit, error) {
+			return pred.Assign(ct, value, root, proofSet, proofIndex, numLeaves, maxDepth)
+		}
+
+		for _, pred := range []RangePredicate{
+			{Attribute: "age", Op: PredicateGE, Lower: 18},
+			{Attribute: "age", Op: PredicateGE, Lower: 20},
+			{Attribute: "age", Op: PredicateLE, Upper: 20},
+			{Attribute: "age", Op: PredicateInRange, Lower: 18, Upper: 65},
+		} {
+			assignment, err := assign(pred, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			circuit := ct.RangeCircuit(pred.Op, maxDepth)
+			if err := test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("%v %+v: valid predicate rejected: %v", mode, pred, err)
+			}
+		}
+
+		for _, pred := range []RangePredicate{
+			{Attribute: "age", Op: PredicateGE, Lower: 21},
+			{Attribute: "age", Op: PredicateLE, Upper: 19},
+			{Attribute: "age", Op: PredicateInRange, Lower: 21, Upper: 65},
+		} {
+			if _, err := assign(pred, 20); err == nil {
+				t.Fatalf("%+v: assignment built for a false predicate", pred)
+			}
+			// 绕过原生检查，电路同样拒绝
+			assignment, _ := assign(RangePredicate{Attribute: "age", Op: PredicateInRange, Upper: 100}, 20)
+			assignment.Op, assignment.Lower, assignment.Upper = pred.Op, pred.Lower, pred.Upper
+			circuit := ct.RangeCircuit(pred.Op, maxDepth)
+			if err := test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+				t.Fatalf("%v %+v: false predicate accepted", mode, pred)
+			}
+		}
+
+		ge18 := RangePredicate{Attribute: "age", Op: PredicateGE, Lower: 18}
+		circuit := ct.RangeCircuit(PredicateGE, maxDepth)
+		// 属性值必须是树中的叶子
+		if _, err := assign(ge18, 30); err == nil {
+			t.Fatalf("%v: assignment built for a value not in the tree", mode)
+		}
+		assignment, _ := assign(ge18, 20)
+		assignment.Value = 30
+		if err := test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: value not in the tree accepted", mode)
+		}
+		// 叶子是另一个数值属性
+		if _, err := (RangePredicate{Attribute: "height", Op: PredicateGE, Lower: 18}).Assign(ct, 20, root, proofSet, proofIndex, numLeaves, maxDepth); err == nil {
+			t.Fatalf("%v: assignment built for another attribute", mode)
+		}
+		assignment, _ = assign(ge18, 20)
+		assignment.Attribute = BytesToVariable(ct.Profile().tag([]byte("height")))
+		if err := test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: leaf of another attribute accepted", mode)
+		}
+
+		// 普通属性的叶子数据即使能表示为 64 位整数也不是数值属性
+		gamma := []byte("gamma")
+		root, proofSet, proofIndex, numLeaves = testNumericTree(t, ct, gamma, 4, 1)
+		mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		forged := RangeCircuit{
+			MerkleRoot: BytesToVariable(root),
+			Attribute:  BytesToVariable(ct.Profile().tag([]byte("age"))),
+			Lower:      18,
+			Upper:      0,
+			Value:      BytesToVariable(gamma),
+			Path:       mw.Path,
+			Helper:     mw.Helper,
+			Depth:      mw.Depth,
+		}
+		if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%v: string leaf proven as a numeric attribute", mode)
+		}
+	}
+
+	if err := (RangePredicate{Op: PredicateGE, Lower: 18}).Validate(); err == nil {
+		t.Fatal("predicate without attribute accepted")
+	}
+}
+
+// 叶子数不是 2 的幂时最后的叶子落单，路径比其他叶子短，每个位置都必须能证明
+func TestRangeCircuitPositions(t *testing.T) {
+	const maxDepth = 3
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	age := NumericAttribute{Name: "age", Value: 20}
+	pred := RangePredicate{Attribute: "age", Op: PredicateGE, Lower: 18}
+	circuit := ct.RangeCircuit(pred.Op, maxDepth)
+	for n := uint64(1); n <= 7; n++ {
+		for index := uint64(0); index < n; index++ {
+			root, proofSet, proofIndex, numLeaves := testNumericTree(t, ct, age.Leaf(ct.Profile()), n, index)
+			assignment, err := pred.Assign(ct, age.Value, root, proofSet, proofIndex, numLeaves, maxDepth)
+			if err != nil {
+				t.Fatalf("n=%d index=%d: %v", n, index, err)
+			}
+			if err := test.IsSolved(&circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+				t.Fatalf("n=%d index=%d: valid predicate rejected: %v", n, index, err)
+			}
+		}
+	}
+}
+
+func TestRangeProof(t *testing.T) {
+	const maxDepth = 2
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	age := NumericAttribute{Name: "age", Value: 20}
+	root, proofSet, proofIndex, numLeaves := testNumericTree(t, ct, age.Leaf(ct.Profile()), 4, 0)
+	pred := RangePredicate{Attribute: "age", Op: PredicateGE, Lower: 18}
+
+	circuit := ct.RangeCircuit(pred.Op, maxDepth)
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment, err := pred.Assign(ct, age.Value, root, proofSet, proofIndex, numLeaves, maxDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := groth16.Prove(ccs, pk, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 验证方只知道 root 与谓词
+	public, err := pred.Witness(root, ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := groth16.Verify(proof, vk, public); err != nil {
+		t.Fatalf("range proof rejected: %v", err)
+	}
+	public, _ = RangePredicate{Attribute: "age", Op: PredicateGE, Lower: 21}.Witness(root, ecc.BN254)
+	if err := groth16.Verify(proof, vk, public); err == nil {
+		t.Fatal("range proof accepted for another threshold")
+	}
+	public, _ = RangePredicate{Attribute: "height", Op: PredicateGE, Lower: 18}.Witness(root, ecc.BN254)
+	if err := groth16.Verify(proof, vk, public); err == nil {
+		t.Fatal("range proof accepted for another attribute")
+	}
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381mimc "github.com/consensys/gnark-crypto/ecc/bls12-381/fr/mimc"
+	bls12381poseidon2 "github.com/consensys/gnark-crypto/ecc/bls12-381/fr/poseidon2"
+	bn254mimc "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	bn254poseidon2 "github.com/consensys/gnark-crypto/ecc/bn254/fr/poseidon2"
+	"github.com/consensys/gnark/frontend"
+	stdhash "github.com/consensys/gnark/std/hash"
+	stdmimc "github.com/consensys/gnark/std/hash/mimc"
+	stdposeidon2 "github.com/consensys/gnark/std/permutation/poseidon2"
+)
+
+// Profile 是证明系统的配置：曲线决定电路与原生哈希所在的标量域，哈希函数在该域上实现。
+// 原生的树、叶子哈希与电路都由同一个 Profile 派生，保证 merkle root 在电路内外一致。
+// A Profile fixes the proof system a credential lives in: the curve of the
+// circuit, whose scalar field is the field of the native hash, and the hash
+// function. Native trees and circuits derived from the same profile agree on
+// every root. The zero value is BN254 with MiMC, the historical profile.
+type Profile struct {
+	Curve ecc.ID
+	Hash  HashFunc
+}
+
+// DefaultProfile 是默认配置，也是服务端会话与 Mimc 使用的配置
+var DefaultProfile = Profile{Curve: ecc.BN254, Hash: HashMiMC}
+
+// SupportedCurves 是 Profile 支持的曲线
+var SupportedCurves = []ecc.ID{ecc.BN254, ecc.BLS12_381}
+
+// ParseProfile returns the profile with the given curve and hash names. Empty
+// names select the defaults.
+func ParseProfile(curve, hashName string) (Profile, error) {
+	var p Profile
+	if curve != "" {
+		id, err := ecc.IDFromString(curve)
+		if err != nil || id == ecc.UNKNOWN {
+			return Profile{}, fmt.Errorf("unknown curve %q", curve)
+		}
+		p.Curve = id
+	}
+	hf, err := ParseHashFunc(hashName)
+	if err != nil {
+		return Profile{}, err
+	}
+	p.Hash = hf
+	return p, p.Validate()
+}
+
+// CurveID 返回 Profile 的曲线，零值为 BN254
+// CurveID returns the curve of the profile, BN254 for the zero value.
+func (p Profile) CurveID() ecc.ID {
+	if p.Curve == ecc.UNKNOWN {
+		return ecc.BN254
+	}
+	return p.Curve
+}
+
+// String returns the profile as "curve/hash".
+func (p Profile) String() string {
+	return p.CurveID().String() + "/" + p.Hash.String()
+}
+
+// Validate checks that the curve is supported and the hash function known.
+func (p Profile) Validate() error {
+	if !p.Hash.Valid() {
+		return fmt.Errorf("profile %v: unknown hash function %v", p, p.Hash)
+	}
+	for _, c := range SupportedCurves {
+		if c == p.CurveID() {
+			return nil
+		}
+	}
+	return fmt.Errorf("profile %v: unsupported curve %v", p, p.CurveID())
+}
+
+// Field returns the scalar field of the curve, in which all hashes live.
+func (p Profile) Field() *big.Int {
+	return p.CurveID().ScalarField()
+}
+
+// CheckField 检查电路编译所用的域与 Profile 一致，不一致时电路内的哈希与原生哈希不同
+// CheckField returns an error if 'field' is not the scalar field of the
+// profile curve, e.g. when a circuit is compiled over another curve.
+func (p Profile) CheckField(field *big.Int) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if field.Cmp(p.Field()) != 0 {
+		return fmt.Errorf("profile %v: circuit field does not match the %v scalar field", p, p.CurveID())
+	}
+	return nil
+}
+
+// NewHash 返回原生哈希实例，可直接用于 New、New1 等
+// NewHash returns a fresh native hash over the profile field. Inputs are
+// written as 32-byte big endian field elements; shorter writes are left
+// padded to one element.
+func (p Profile) NewHash() hash.Hash {
+	if err := p.Validate(); err != nil {
+		panic(err)
+	}
+	switch {
+	case p.CurveID() == ecc.BN254 && p.Hash == HashMiMC:
+		return bn254mimc.NewMiMC()
+	case p.CurveID() == ecc.BN254:
+		return bn254poseidon2.NewMerkleDamgardHasher()
+	case p.Hash == HashMiMC:
+		return bls12381mimc.NewMiMC()
+	default:
+		return bls12381poseidon2.NewMerkleDamgardHasher()
+	}
+}
+
+// NewCircuitHash 返回电路内的哈希实例，电路的域与 Profile 不一致时在编译时返回错误
+// NewCircuitHash returns the in-circuit counterpart of NewHash. It fails when
+// the circuit is not compiled over the profile field.
+func (p Profile) NewCircuitHash(api frontend.API) (stdhash.FieldHasher, error) {
+	if err := p.CheckField(api.Compiler().Field()); err != nil {
+		return nil, err
+	}
+	if p.Hash == HashMiMC {
+		h, err := stdmimc.NewMiMC(api)
+		if err != nil {
+			return nil, err
+		}
+		return &h, nil
+	}
+	// std/hash/poseidon2 尚未提供这两条曲线的默认参数，这里使用与 gnark-crypto 相同的参数
+	var nbFullRounds, nbPartialRounds int
+	if p.CurveID() == ecc.BN254 {
+		params := bn254poseidon2.GetDefaultParameters()
+		nbFullRounds, nbPartialRounds = params.NbFullRounds, params.NbPartialRounds
+	} else {
+		params := bls12381poseidon2.GetDefaultParameters()
+		nbFullRounds, nbPartialRounds = params.NbFullRounds, params.NbPartialRounds
+	}
+	f, err := stdposeidon2.NewPoseidon2FromParameters(api, 2, nbFullRounds, nbPartialRounds)
+	if err != nil {
+		return nil, err
+	}
+	return stdhash.NewMerkleDamgardHasher(api, f, 0), nil
+}
+
+// Sum 对字符串属性进行哈希处理，返回 32 字节大端序的域元素
+// Sum hashes a string attribute into a field element of the profile.
+func (p Profile) Sum(data string) []byte {
+	return leafSum(p.NewHash(), []byte(data))
+}
+
+// tag 将标签或名称（如属性名）映射为标量域元素 sha256(data) mod r，只依赖曲线
+func (p Profile) tag(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	t := new(big.Int).SetBytes(digest[:])
+	return t.Mod(t, p.Field()).FillBytes(make([]byte, 32))
+}
+
+// checkBN254 检查 Profile 使用 BN254：以 bn254 fr.Element 表示值的结构不能用于其他曲线的标量域
+// checkBN254 returns an error unless the profile is valid and on BN254.
+// Structures whose values are bn254 field elements, such as FieldTree and
+// SparseTree, cannot hash in the field of another curve.
+func (p Profile) checkBN254() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if p.CurveID() != ecc.BN254 {
+		return fmt.Errorf("profile %v: only BN254 is supported, use a Tree for %v", p, p.CurveID())
+	}
+	return nil
+}
+
+// fieldCurve 返回标量域为 field 的受支持曲线
+func fieldCurve(field *big.Int) (ecc.ID, error) {
+	for _, c := range SupportedCurves {
+		if c.ScalarField().Cmp(field) == 0 {
+			return c, nil
+		}
+	}
+	return ecc.UNKNOWN, fmt.Errorf("no supported curve has scalar field %s", field)
+}
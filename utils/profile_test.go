@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+)
+
+// 每个 Profile 下原生 Tree 的证明都应被同一 Profile 的电路接受
+func TestProfileCircuit(t *testing.T) {
+	data := testLeaves(5)
+	for _, curve := range SupportedCurves {
+		for _, hf := range []HashFunc{HashMiMC, HashPoseidon2} {
+			ct := CredentialType{Name: "test", Curve: curve, Hash: hf, Mode: HashModeDomainTag}
+			tree, err := ct.NewTree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = tree.SetIndex(3)
+			for _, d := range data {
+				tree.Push(d)
+			}
+			root, proofSet, proofIndex, _ := tree.Prove()
+			depth := len(proofSet) - 1
+			assignment := ValidCircuit{
+				MerkleRoot: BytesToVariable(root),
+				Message:    0,
+				Leaf:       BytesToVariable(proofSet[0]),
+				Path:       BytesArrayToVariables(proofSet[1:]),
+				Helper:     IndexToHelper(proofIndex, depth),
+				Depth:      depth,
+			}
+			if err := test.IsSolved(ct.Placeholder(depth), &assignment, curve.ScalarField()); err != nil {
+				t.Fatalf("%v: circuit rejected native proof: %v", ct.Profile(), err)
+			}
+		}
+	}
+}
+
+func TestProfileMismatch(t *testing.T) {
+	// 默认 Profile 的电路不能在 BLS12-381 上编译
+	circuit := CredentialType{}.Circuit(2)
+	if _, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &circuit); err == nil {
+		t.Fatal("BN254 circuit compiled over BLS12-381")
+	}
+	circuit = CredentialType{Curve: ecc.BLS12_381}.Circuit(2)
+	if _, err := frontend.Compile(ecc.BLS12_381.ScalarField(), r1cs.NewBuilder, &circuit); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两条曲线上的同一哈希给出不同的结果
+	if string(Profile{Curve: ecc.BN254}.Sum("18")) == string(Profile{Curve: ecc.BLS12_381}.Sum("18")) {
+		t.Fatal("profiles on different curves hash alike")
+	}
+	if _, err := ParseProfile("bw6_761", "mimc"); err == nil {
+		t.Fatal("unsupported curve accepted")
+	}
+	if _, err := (CredentialType{Curve: ecc.BW6_761}).NewTree(); err == nil {
+		t.Fatal("tree created on an unsupported curve")
+	}
+	p, err := ParseProfile("bls12_381", "poseidon2")
+	if err != nil || p != (Profile{Curve: ecc.BLS12_381, Hash: HashPoseidon2}) {
+		t.Fatalf("unexpected profile %v: %v", p, err)
+	}
+}
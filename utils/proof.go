@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ProofVersion 是 Proof 编码格式的当前版本
+// ProofVersion is the current version of the Proof binary and JSON encodings.
+// Version 1 proofs carry no hash mode and are decoded as HashModePlain.
+const ProofVersion = 2
+
+const proofFlagLeafHashed = 1 << 0
+
+// Proof 是与构造方式无关的 merkle proof，可以编码后交给依赖方在本地验证。
+// A Proof is a portable Merkle inclusion proof. Path holds the sibling sums
+// ordered from the leaf up to the root. If LeafHashed is set, Leaf is already
+// the leaf sum (cached trees built with New), otherwise Leaf is the leaf data
+// and is hashed with Mode.LeafSum during verification.
+type Proof struct {
+	Index      uint64
+	NumLeaves  uint64
+	Mode       HashMode
+	LeafHashed bool
+	Leaf       []byte
+	Path       [][]byte
+}
+
+// NewProof 将 Prove/Prove1 的输出转换为 Proof
+// NewProof converts the output of Prove or Prove1, whose first element is the
+// leaf data, into a Proof. Set Mode on the result if the tree was built with
+// a hash mode other than HashModePlain.
+func NewProof(proofSet [][]byte, proofIndex uint64, numLeaves uint64) (*Proof, error) {
+	if len(proofSet) == 0 {
+		return nil, errors.New("empty proof set")
+	}
+	if proofIndex >= numLeaves {
+		return nil, fmt.Errorf("proof index %d out of range for %d leaves", proofIndex, numLeaves)
+	}
+	return &Proof{
+		Index:     proofIndex,
+		NumLeaves: numLeaves,
+		Leaf:      proofSet[0],
+		Path:      proofSet[1:],
+	}, nil
+}
+
+// Verify 验证 proof 是否属于给定的 merkle root
+// Verify returns true if the proof shows that Leaf is part of the tree with
+// the given root.
+func (p *Proof) Verify(h hash.Hash, merkleRoot []byte) bool {
+	leaf := p.Leaf
+	if !p.LeafHashed {
+		leaf = p.Mode.LeafSum(h, leaf)
+	}
+	return verifyProof(h, p.Mode, merkleRoot, leaf, p.Path, p.Index, p.NumLeaves)
+}
+
+// MarshalBinary encodes the proof as
+//
+//	version || flags || mode || uvarint(index) || uvarint(numLeaves) ||
+//	uvarint(len(leaf)) || leaf || uvarint(len(path)) || (uvarint(len(p)) || p)...
+func (p *Proof) MarshalBinary() ([]byte, error) {
+	var flags byte
+	if p.LeafHashed {
+		flags |= proofFlagLeafHashed
+	}
+	buf := []byte{ProofVersion, flags, byte(p.Mode)}
+	buf = binary.AppendUvarint(buf, p.Index)
+	buf = binary.AppendUvarint(buf, p.NumLeaves)
+	buf = appendBytes(buf, p.Leaf)
+	buf = binary.AppendUvarint(buf, uint64(len(p.Path)))
+	for _, s := range p.Path {
+		buf = appendBytes(buf, s)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a proof written by MarshalBinary.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("proof too short")
+	}
+	version, flags := data[0], data[1]
+	if flags&^proofFlagLeafHashed != 0 {
+		return fmt.Errorf("unknown proof flags %#x", flags)
+	}
+	mode := HashModePlain
+	switch version {
+	case 1:
+		data = data[2:]
+	case ProofVersion:
+		if len(data) < 3 {
+			return errors.New("proof too short")
+		}
+		mode = HashMode(data[2])
+		data = data[3:]
+	default:
+		return fmt.Errorf("unsupported proof version %d", version)
+	}
+	if !mode.Valid() {
+		return fmt.Errorf("unknown hash mode %v", mode)
+	}
+	r := &byteReader{data: data}
+	index := r.uvarint()
+	numLeaves := r.uvarint()
+	leaf := r.bytes()
+	n := r.uvarint()
+	if r.err == nil && n > uint64(len(r.data)) {
+		return errors.New("invalid proof path length")
+	}
+	var path [][]byte
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		path = append(path, r.bytes())
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) != 0 {
+		return errors.New("trailing bytes after proof")
+	}
+	*p = Proof{
+		Index:      index,
+		NumLeaves:  numLeaves,
+		Mode:       mode,
+		LeafHashed: flags&proofFlagLeafHashed != 0,
+		Leaf:       leaf,
+		Path:       path,
+	}
+	return nil
+}
+
+type proofJSON struct {
+	Version    int      `json:"version"`
+	Index      uint64   `json:"index"`
+	NumLeaves  uint64   `json:"num_leaves"`
+	Mode       string   `json:"mode,omitempty"`
+	LeafHashed bool     `json:"leaf_hashed,omitempty"`
+	Leaf       string   `json:"leaf"`
+	Path       []string `json:"path"`
+}
+
+// MarshalJSON encodes the proof with hex encoded leaf and path.
+func (p *Proof) MarshalJSON() ([]byte, error) {
+	v := proofJSON{
+		Version:    ProofVersion,
+		Index:      p.Index,
+		NumLeaves:  p.NumLeaves,
+		Mode:       p.Mode.String(),
+		LeafHashed: p.LeafHashed,
+		Leaf:       hex.EncodeToString(p.Leaf),
+		Path:       make([]string, len(p.Path)),
+	}
+	for i, s := range p.Path {
+		v.Path[i] = hex.EncodeToString(s)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a proof written by MarshalJSON.
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	var v proofJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Version != 1 && v.Version != ProofVersion {
+		return fmt.Errorf("unsupported proof version %d", v.Version)
+	}
+	mode, err := ParseHashMode(v.Mode)
+	if err != nil {
+		return err
+	}
+	leaf, err := hex.DecodeString(v.Leaf)
+	if err != nil {
+		return fmt.Errorf("invalid leaf: %v", err)
+	}
+	path := make([][]byte, len(v.Path))
+	for i, s := range v.Path {
+		if path[i], err = hex.DecodeString(s); err != nil {
+			return fmt.Errorf("invalid path element %d: %v", i, err)
+		}
+	}
+	*p = Proof{
+		Index:      v.Index,
+		NumLeaves:  v.NumLeaves,
+		Mode:       mode,
+		LeafHashed: v.LeafHashed,
+		Leaf:       leaf,
+		Path:       path,
+	}
+	return nil
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// byteReader 顺序读取 uvarint 与带长度前缀的字节串，遇到第一个错误后停止
+type byteReader struct {
+	data []byte
+	err  error
+}
+
+func (r *byteReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errors.New("invalid uvarint")
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *byteReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := append([]byte(nil), r.data[:n]...)
+	r.data = r.data[n:]
+	return b
+}
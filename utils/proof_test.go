@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+)
+
+func testLeaves(n int) [][]byte {
+	data := make([][]byte, n)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("leaf-%d", i))
+	}
+	return data
+}
+
+func TestVerifyProof(t *testing.T) {
+	h := mimc.NewMiMC()
+	for n := 1; n <= 17; n++ {
+		data := testLeaves(n)
+		for i := 0; i < n; i++ {
+			tree := New1(h)
+			if err := tree.SetIndex(uint64(i)); err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range data {
+				tree.Push1(d)
+			}
+			root, proofSet, proofIndex, numLeaves := tree.Prove1()
+			if !VerifyProof(h, root, proofSet[0], proofSet[1:], proofIndex, numLeaves) {
+				t.Fatalf("n=%d i=%d: valid proof rejected", n, i)
+			}
+			if VerifyProof(h, root, []byte("forged"), proofSet[1:], proofIndex, numLeaves) {
+				t.Fatalf("n=%d i=%d: forged leaf accepted", n, i)
+			}
+			if n > 1 && VerifyProof(h, root, proofSet[0], proofSet[1:], (proofIndex+1)%numLeaves, numLeaves) {
+				t.Fatalf("n=%d i=%d: wrong index accepted", n, i)
+			}
+		}
+	}
+}
+
+func TestProofEncoding(t *testing.T) {
+	h := mimc.NewMiMC()
+	tree := New1(h)
+	_ = tree.SetIndex(5)
+	for _, d := range testLeaves(11) {
+		tree.Push1(d)
+	}
+	root, proofSet, proofIndex, numLeaves := tree.Prove1()
+	proof, err := NewProof(proofSet, proofIndex, numLeaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bin, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromBin Proof
+	if err := fromBin.UnmarshalBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+	if !fromBin.Verify(h, root) {
+		t.Fatal("binary decoded proof rejected")
+	}
+	if err := fromBin.UnmarshalBinary(bin[:len(bin)-1]); err == nil {
+		t.Fatal("truncated proof accepted")
+	}
+
+	js, err := proof.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Proof
+	if err := fromJSON.UnmarshalJSON(js); err != nil {
+		t.Fatal(err)
+	}
+	if !fromJSON.Verify(h, root) {
+		t.Fatal("JSON decoded proof rejected")
+	}
+}
+
+func TestBuildProof(t *testing.T) {
+	h := mimc.NewMiMC()
+	for _, n := range []int{1, 4, 6} {
+		tree := New(h)
+		for i := 0; i < n; i++ {
+			tree.Push(frToBytes32(Mimc(fmt.Sprintf("attr-%d", i))))
+		}
+		root := tree.Root()
+		for i := 0; i < n; i++ {
+			proof, proofRoot, err := tree.BuildProof(uint64(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(root, proofRoot) {
+				t.Fatalf("n=%d: BuildProof root differs from Root", n)
+			}
+			if !proof.Verify(h, root) {
+				t.Fatalf("n=%d i=%d: BuildProof proof rejected", n, i)
+			}
+		}
+	}
+}
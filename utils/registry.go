@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark/constraint"
+)
+
+// RegistryFile 是注册表目录中记录弃用电路版本的文件名
+const RegistryFile = "registry.json"
+
+// ErrCircuitDeprecated 在证明针对已弃用的电路版本时返回
+var ErrCircuitDeprecated = errors.New("circuit version is deprecated")
+
+// RegistryEntry 是注册表中一个电路版本的约束系统与验证密钥
+// A RegistryEntry is one version of a circuit: its setup manifest, compiled
+// constraint system and verifying key with its digest. Deprecated holds the
+// reason the version was deprecated, empty while it is active.
+type RegistryEntry struct {
+	Manifest   SetupManifest
+	CCS        constraint.ConstraintSystem
+	VK         *VerifyingKey
+	VKHash     string
+	Deprecated string
+}
+
+// registryDeprecation 是 RegistryFile 中的一条弃用记录
+type registryDeprecation struct {
+	Circuit string `json:"circuit"`
+	Version int    `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+// Registry 将电路名与版本映射到约束系统与验证密钥。升级电路时旧版本仍留在注册表中，
+// 旧证明依然可以验证，直到该版本被弃用
+// A Registry maps circuit keys to the constraint systems and verifying keys
+// of every version of every circuit, so proofs made before an upgrade stay
+// verifiable. Verifiers pick the key from the proof envelope. Versions can
+// be deprecated, after which their proofs are rejected.
+type Registry struct {
+	mu      sync.RWMutex
+	dir     string
+	entries map[CircuitKey]*RegistryEntry
+}
+
+// NewRegistry returns an empty in-memory registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[CircuitKey]*RegistryEntry)}
+}
+
+// LoadRegistry 从目录 dir 加载注册表：每个子目录是 SetupArtifacts.Save 写入的一组产物，
+// 只加载约束系统与验证密钥；弃用记录保存在 dir 下的 RegistryFile 中
+// LoadRegistry loads every set of setup artifacts saved with Save in a
+// subdirectory of 'dir', reading only the constraint system and verifying
+// key, and the deprecations recorded in RegistryFile. Subdirectories without
+// a manifest are skipped. Later calls to Deprecate update RegistryFile.
+func LoadRegistry(dir string) (*Registry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRegistry()
+	r.dir = dir
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		sub := filepath.Join(dir, f.Name())
+		m, err := LoadSetupManifest(sub)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		curve, _ := m.CurveID()
+		b, _ := m.BackendID()
+		ccs := b.newCS(curve)
+		if err := readArtifact(sub, m, b.constraintKind(), ccs); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		vk := &VerifyingKey{Backend: b, Curve: curve, Key: b.newVerifyingKey(curve)}
+		if err := readArtifact(sub, m, artifactVK, vk.Key); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		if err := r.add(&RegistryEntry{Manifest: *m, CCS: ccs, VK: vk, VKHash: m.Digests[artifactVK]}); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, RegistryFile))
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var deprecations []registryDeprecation
+	if err := json.Unmarshal(data, &deprecations); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", RegistryFile, err)
+	}
+	for _, d := range deprecations {
+		key := CircuitKey{ID: d.Circuit, Version: d.Version}.normalize()
+		e, ok := r.entries[key]
+		if !ok {
+			return nil, fmt.Errorf("%s deprecates unknown circuit %s", RegistryFile, key)
+		}
+		e.Deprecated = d.Reason
+	}
+	return r, nil
+}
+
+// Register 将一组可信设置产物加入注册表，同一电路版本只能注册一次
+// Register adds the constraint system and verifying key of a set of setup
+// artifacts. A circuit version can only be registered once.
+func (r *Registry) Register(a *SetupArtifacts) error {
+	vk := a.VerifyingKey()
+	digest, err := vk.Digest()
+	if err != nil {
+		return fmt.Errorf("hash verifying key: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.add(&RegistryEntry{Manifest: a.Manifest, CCS: a.CCS, VK: vk, VKHash: digest})
+}
+
+func (r *Registry) add(e *RegistryEntry) error {
+	key := e.Manifest.Key()
+	if _, ok := r.entries[key]; ok {
+		return fmt.Errorf("circuit %s registered twice", key)
+	}
+	r.entries[key] = e
+	return nil
+}
+
+// Keys 返回全部电路版本，按电路名与版本排序
+// Keys returns the keys of all registered circuit versions, deprecated ones
+// included, sorted by id and version.
+func (r *Registry) Keys() []CircuitKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]CircuitKey, 0, len(r.entries))
+	for k := range r.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ID != keys[j].ID {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].Version < keys[j].Version
+	})
+	return keys
+}
+
+// Lookup returns the entry of a circuit version, deprecated or not.
+func (r *Registry) Lookup(key CircuitKey) (*RegistryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[key.normalize()]
+	if !ok {
+		return nil, fmt.Errorf("unknown circuit %s", key)
+	}
+	return e, nil
+}
+
+// Latest 返回电路 id 未弃用的最高版本，供证明者选择电路
+// Latest returns the highest version of circuit 'id' that is not
+// deprecated.
+func (r *Registry) Latest(id string) (*RegistryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var latest *RegistryEntry
+	for k, e := range r.entries {
+		if k.ID != id || e.Deprecated != "" {
+			continue
+		}
+		if latest == nil || k.Version > latest.Manifest.Key().Version {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no active version of circuit %q", id)
+	}
+	return latest, nil
+}
+
+// Deprecate 弃用一个电路版本，之后针对该版本的证明都会被拒绝。
+// 注册表由 LoadRegistry 加载时同时更新目录中的 RegistryFile
+// Deprecate marks a circuit version as deprecated for 'reason'; proofs for
+// it are rejected from then on. For a registry loaded with LoadRegistry the
+// deprecation is also written to RegistryFile.
+func (r *Registry) Deprecate(key CircuitKey, reason string) error {
+	if reason == "" {
+		return errors.New("deprecation needs a reason")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[key.normalize()]
+	if !ok {
+		return fmt.Errorf("unknown circuit %s", key)
+	}
+	previous := e.Deprecated
+	e.Deprecated = reason
+	if r.dir == "" {
+		return nil
+	}
+	if err := r.writeDeprecations(); err != nil {
+		e.Deprecated = previous
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) writeDeprecations() error {
+	deprecations := []registryDeprecation{}
+	for k, e := range r.entries {
+		if e.Deprecated != "" {
+			deprecations = append(deprecations, registryDeprecation{Circuit: k.ID, Version: k.Version, Reason: e.Deprecated})
+		}
+	}
+	sort.Slice(deprecations, func(i, j int) bool {
+		if deprecations[i].Circuit != deprecations[j].Circuit {
+			return deprecations[i].Circuit < deprecations[j].Circuit
+		}
+		return deprecations[i].Version < deprecations[j].Version
+	})
+	data, err := json.MarshalIndent(deprecations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, RegistryFile), data, 0644)
+}
+
+// VerifyingKey 按信封记录的电路版本选择验证密钥，密钥摘要必须与信封一致
+// VerifyingKey returns the verifying key of the circuit version the
+// envelope names, after checking its digest against the envelope. It fails
+// with ErrCircuitDeprecated for deprecated versions.
+func (r *Registry) VerifyingKey(e *Envelope) (*VerifyingKey, error) {
+	entry, err := r.Lookup(e.Key())
+	if err != nil {
+		return nil, err
+	}
+	if entry.Deprecated != "" {
+		return nil, fmt.Errorf("%s: %w: %s", e.Key(), ErrCircuitDeprecated, entry.Deprecated)
+	}
+	if entry.VKHash != e.VKHash {
+		return nil, fmt.Errorf("envelope is for verifying key %s, %s has %s", e.VKHash, e.Key(), entry.VKHash)
+	}
+	return entry.VK, nil
+}
+
+// Verify 用信封所指电路版本的验证密钥验证证明（见 VerifyEnvelope）
+// Verify checks the proof of an envelope with the verifying key the
+// registry holds for it and the trusted authority keys; see VerifyingKey and
+// VerifyEnvelope.
+func (r *Registry) Verify(e *Envelope, authorities []bls12381.G1Affine) error {
+	if e == nil {
+		return errors.New("missing envelope")
+	}
+	vk, err := r.VerifyingKey(e)
+	if err != nil {
+		return err
+	}
+	return VerifyEnvelope(vk, e, authorities)
+}
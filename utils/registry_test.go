@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	envelopes := make(map[int]*Envelope)
+	authorities := make(map[int][]bls12381.G1Affine)
+	for version, depth := range map[int]int{1: 2, 2: 3} {
+		ct := CredentialType{Name: "test", Mode: HashModeDomainTag, Version: version}
+		setup, err := Setup(ct, depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := setup.Save(filepath.Join(dir, setup.Manifest.Key().String())); err != nil {
+			t.Fatal(err)
+		}
+		proofs, publics, pks := testInnerProofs(t, setup, ct, 1)
+		authorities[version] = pks
+		e, err := NewEnvelope(&setup.Manifest, setup.VerifyingKey(), proofs[0], publics[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		envelopes[version] = e
+	}
+
+	r, err := LoadRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := r.Keys(); len(keys) != 2 || keys[0] != (CircuitKey{"test", 1}) || keys[1] != (CircuitKey{"test", 2}) {
+		t.Fatalf("unexpected circuits %v", keys)
+	}
+	// 升级后旧版本的证明仍然可以验证
+	for version, e := range envelopes {
+		if err := r.Verify(e, authorities[version]); err != nil {
+			t.Fatalf("v%d: %v", version, err)
+		}
+	}
+	if latest, err := r.Latest("test"); err != nil || latest.Manifest.Key().Version != 2 {
+		t.Fatalf("latest version not found: %v", err)
+	}
+
+	// 信封指向的电路版本与验证密钥必须一致
+	other := *envelopes[1]
+	other.CircuitVersion = 3
+	if err := r.Verify(&other, authorities[1]); err == nil {
+		t.Fatal("proof accepted for an unknown circuit version")
+	}
+	other = *envelopes[1]
+	other.VKHash = envelopes[2].VKHash
+	if err := r.Verify(&other, authorities[1]); err == nil {
+		t.Fatal("proof accepted for another verifying key")
+	}
+
+	// 弃用记录写入目录，重新加载后仍然有效
+	if err := r.Deprecate(CircuitKey{"test", 2}, "unsound"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Deprecate(CircuitKey{"other", 1}, "unsound"); err == nil {
+		t.Fatal("unknown circuit deprecated")
+	}
+	r, err = LoadRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Verify(envelopes[2], authorities[2]); !errors.Is(err, ErrCircuitDeprecated) {
+		t.Fatalf("proof for a deprecated circuit: %v", err)
+	}
+	if err := r.Verify(envelopes[1], authorities[1]); err != nil {
+		t.Fatal(err)
+	}
+	if latest, err := r.Latest("test"); err != nil || latest.Manifest.Key().Version != 1 {
+		t.Fatalf("deprecated version still latest: %v", err)
+	}
+
+	// 同一电路版本不能注册两次
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	setup, err := Setup(ct, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(setup); err == nil {
+		t.Fatal("circuit version registered twice")
+	}
+}
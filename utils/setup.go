@@ -0,0 +1,370 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+)
+
+// SetupVersion 是可信设置产物的格式版本，格式不兼容时递增
+const SetupVersion = 1
+
+// SetupManifestFile 是产物目录中清单文件的文件名
+const SetupManifestFile = "manifest.json"
+
+// SetupManifest 记录电路参数以及每个产物文件的 sha256，加载时逐一校验
+// A SetupManifest describes the circuit a set of setup artifacts was made
+// for and the sha256 of every artifact file, checked on load.
+type SetupManifest struct {
+	Version int    `json:"version"`
+	Circuit string `json:"circuit"`
+	Curve   string `json:"curve"`
+	Hash    string `json:"hash"`
+	Mode    string `json:"mode"`
+	Depth   int    `json:"depth"`
+	// 电路版本，为零时（包括旧版清单）视为 1
+	CircuitVersion int `json:"circuit_version,omitempty"`
+	// 聚合签名是否在电路内验证
+	Signature bool `json:"signature,omitempty"`
+	// 叶子是否绑定持有者，以及绑定叶子中的属性数
+	Holder     bool `json:"holder,omitempty"`
+	Attributes int  `json:"attributes,omitempty"`
+	// 证明是否输出域内的 nullifier
+	Nullifier bool `json:"nullifier,omitempty"`
+	// 证明后端，为空时为 groth16，与旧版清单兼容
+	Backend string            `json:"backend,omitempty"`
+	Files   map[string]string `json:"files"` // 产物类型 -> 文件名
+	Digests map[string]string `json:"digests"`
+}
+
+// 产物类型，约束系统的类型由 Backend.constraintKind 给出
+const (
+	artifactPK = "pk"
+	artifactVK = "vk"
+)
+
+// CredentialType returns the credential type the circuit was compiled for.
+func (m *SetupManifest) CredentialType() (CredentialType, error) {
+	hf, err := ParseHashFunc(m.Hash)
+	if err != nil {
+		return CredentialType{}, err
+	}
+	mode, err := ParseHashMode(m.Mode)
+	if err != nil {
+		return CredentialType{}, err
+	}
+	curve, err := m.CurveID()
+	if err != nil {
+		return CredentialType{}, err
+	}
+	ct := CredentialType{Name: m.Circuit, Curve: curve, Hash: hf, Mode: mode, Signature: m.Signature, Holder: m.Holder, Attributes: m.Attributes, Nullifier: m.Nullifier, Version: m.CircuitVersion}
+	return ct, ct.Validate()
+}
+
+// Key returns the id and version of the circuit of the artifacts.
+func (m *SetupManifest) Key() CircuitKey {
+	return CircuitKey{ID: m.Circuit, Version: m.CircuitVersion}.normalize()
+}
+
+// BackendID returns the proving backend of the artifacts.
+func (m *SetupManifest) BackendID() (Backend, error) {
+	return ParseBackend(m.Backend)
+}
+
+// CurveID returns the curve of the artifacts.
+func (m *SetupManifest) CurveID() (ecc.ID, error) {
+	curve, err := ecc.IDFromString(m.Curve)
+	if err != nil || curve == ecc.UNKNOWN {
+		return ecc.UNKNOWN, fmt.Errorf("unknown curve %q", m.Curve)
+	}
+	return curve, nil
+}
+
+// CircuitKey 标识一个电路：电路名与版本
+// A CircuitKey names a circuit: the credential type name it was set up for
+// and its version. Version 0 is version 1.
+type CircuitKey struct {
+	ID      string
+	Version int
+}
+
+func (k CircuitKey) normalize() CircuitKey {
+	if k.Version == 0 {
+		k.Version = 1
+	}
+	return k
+}
+
+// String returns the key as id@vN.
+func (k CircuitKey) String() string {
+	return fmt.Sprintf("%s@v%d", k.ID, k.normalize().Version)
+}
+
+// SetupArtifacts 是一次可信设置的全部产物
+// SetupArtifacts are the compiled constraint system of a credential circuit and the
+// proving and verifying keys generated for it by the backend of the manifest.
+type SetupArtifacts struct {
+	Manifest SetupManifest
+	CCS      constraint.ConstraintSystem
+	PK       Artifact
+	VK       Artifact
+}
+
+// VerifyingKey returns the verifying key of the artifacts with its backend
+// and curve.
+func (a *SetupArtifacts) VerifyingKey() *VerifyingKey {
+	b, _ := a.Manifest.BackendID()
+	curve, _ := a.Manifest.CurveID()
+	return &VerifyingKey{Backend: b, Curve: curve, Key: a.VK}
+}
+
+// VerifyingKey 是验证方所需的验证密钥及其后端与曲线
+// A VerifyingKey is a verifying key of either backend.
+type VerifyingKey struct {
+	Backend Backend
+	Curve   ecc.ID
+	Key     Artifact
+}
+
+// Digest 返回验证密钥序列化后的 sha256（十六进制），与清单中记录的 vk 摘要相同
+// Digest returns the hex sha256 of the serialized key, as recorded for the
+// key file in the setup manifest.
+func (vk *VerifyingKey) Digest() (string, error) {
+	h := sha256.New()
+	if _, err := vk.Key.WriteTo(h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Setup 编译凭证类型 ct 最大深度为 depth 的电路（见 CredentialType.Placeholder）并运行 groth16.Setup。
+// 该操作只需执行一次，结果通过 Save 写入文件，证明者与验证者通过 LoadSetup 加载。
+// Setup compiles the circuit of maximum depth 'depth' for credential type
+// 'ct' over the curve of its profile and runs the Groth16 setup. It is meant to
+// run once, from the setup command; provers and verifiers load the saved
+// artifacts.
+func Setup(ct CredentialType, depth int) (*SetupArtifacts, error) {
+	return setupBackend(BackendGroth16, ct, depth, nil)
+}
+
+// SetupPlonk 与 Setup 相同，但使用 PLONK 与 srs 提供的通用 KZG SRS，同一个 SRS 可用于不同的电路
+// SetupPlonk is Setup for the PLONK backend. The keys are derived from the
+// universal SRS returned by 'srs', so no circuit specific ceremony is needed;
+// see UnsafeSRS for tests and FileSRS for a ceremony output.
+func SetupPlonk(ct CredentialType, depth int, srs SRSProvider) (*SetupArtifacts, error) {
+	return setupBackend(BackendPlonk, ct, depth, srs)
+}
+
+func setupBackend(b Backend, ct CredentialType, depth int, srs SRSProvider) (*SetupArtifacts, error) {
+	m, ccs, err := compileSetup(b, ct, depth)
+	if err != nil {
+		return nil, err
+	}
+	pk, vk, err := b.setup(ccs, srs)
+	if err != nil {
+		return nil, fmt.Errorf("%v setup: %v", b, err)
+	}
+	return &SetupArtifacts{
+		Manifest: *m,
+		CCS:      ccs,
+		PK:       pk,
+		VK:       vk,
+	}, nil
+}
+
+// compileSetup 编译凭证类型 ct 最大深度为 depth 的电路，返回尚未记录产物文件的清单与约束系统
+func compileSetup(b Backend, ct CredentialType, depth int) (*SetupManifest, constraint.ConstraintSystem, error) {
+	if err := ct.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if depth < 0 {
+		return nil, nil, fmt.Errorf("invalid depth %d", depth)
+	}
+	curve := ct.Profile().CurveID()
+	ccs, err := frontend.Compile(curve.ScalarField(), b.newBuilder(), ct.Placeholder(depth))
+	if err != nil {
+		return nil, nil, fmt.Errorf("compile circuit: %v", err)
+	}
+	name := ct.Name
+	if name == "" {
+		name = "valid"
+	}
+	m := &SetupManifest{
+		Version:        SetupVersion,
+		Circuit:        name,
+		Curve:          curve.String(),
+		Hash:           ct.Hash.String(),
+		Mode:           ct.Mode.String(),
+		Depth:          depth,
+		CircuitVersion: ct.Version,
+		Signature:      ct.Signature,
+		Holder:         ct.Holder,
+		Attributes:     ct.Attributes,
+		Nullifier:      ct.Nullifier,
+	}
+	if b != BackendGroth16 {
+		m.Backend = b.String()
+	}
+	return m, ccs, nil
+}
+
+// Save 将产物写入目录 dir，文件名包含电路名与电路版本
+// Save writes the artifacts and their manifest to 'dir'. File names carry
+// the circuit name and version (see Key) so several versions of a circuit
+// can live side by side.
+func (a *SetupArtifacts) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	m := a.Manifest
+	b, err := m.BackendID()
+	if err != nil {
+		return err
+	}
+	m.Files = make(map[string]string)
+	m.Digests = make(map[string]string)
+	for _, art := range []struct {
+		kind string
+		w    io.WriterTo
+	}{
+		{b.constraintKind(), a.CCS},
+		{artifactPK, a.PK},
+		{artifactVK, a.VK},
+	} {
+		name := fmt.Sprintf("%s.v%d.%s", m.Circuit, m.Key().Version, art.kind)
+		digest, err := writeArtifact(filepath.Join(dir, name), art.w)
+		if err != nil {
+			return fmt.Errorf("write %s: %v", name, err)
+		}
+		m.Files[art.kind] = name
+		m.Digests[art.kind] = digest
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, SetupManifestFile), data, 0644); err != nil {
+		return err
+	}
+	a.Manifest = m
+	return nil
+}
+
+func writeArtifact(path string, w io.WriterTo) (string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := w.WriteTo(io.MultiWriter(f, h)); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadSetupManifest reads and checks the manifest in 'dir'.
+func LoadSetupManifest(dir string) (*SetupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SetupManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m SetupManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid setup manifest: %v", err)
+	}
+	if m.Version != SetupVersion {
+		return nil, fmt.Errorf("unsupported setup version %d, expected %d", m.Version, SetupVersion)
+	}
+	if _, err := m.CurveID(); err != nil {
+		return nil, err
+	}
+	if _, err := m.CredentialType(); err != nil {
+		return nil, err
+	}
+	if _, err := m.BackendID(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// readArtifact 校验文件的 sha256 后再反序列化
+func readArtifact(dir string, m *SetupManifest, kind string, r io.ReaderFrom) error {
+	name, ok := m.Files[kind]
+	if !ok || filepath.Base(name) != name {
+		return fmt.Errorf("setup manifest has no valid %s file", kind)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != m.Digests[kind] {
+		return fmt.Errorf("integrity check failed for %s", name)
+	}
+	if _, err := r.ReadFrom(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("read %s: %v", name, err)
+	}
+	return nil
+}
+
+// LoadSetup 加载证明者所需的全部产物
+// LoadSetup loads the artifacts saved in 'dir' by Save, checking their
+// integrity against the manifest.
+func LoadSetup(dir string) (*SetupArtifacts, error) {
+	m, err := LoadSetupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	curve, _ := m.CurveID()
+	b, _ := m.BackendID()
+	a := &SetupArtifacts{
+		Manifest: *m,
+		CCS:      b.newCS(curve),
+		PK:       b.newProvingKey(curve),
+		VK:       b.newVerifyingKey(curve),
+	}
+	if err := readArtifact(dir, m, b.constraintKind(), a.CCS); err != nil {
+		return nil, err
+	}
+	if err := readArtifact(dir, m, artifactPK, a.PK); err != nil {
+		return nil, err
+	}
+	if err := readArtifact(dir, m, artifactVK, a.VK); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadVerifyingKey 只加载验证密钥，供验证方使用
+// LoadVerifyingKey loads only the verifying key saved in 'dir', which is all
+// a verifier needs.
+func LoadVerifyingKey(dir string) (*SetupManifest, *VerifyingKey, error) {
+	m, err := LoadSetupManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	curve, _ := m.CurveID()
+	b, _ := m.BackendID()
+	vk := &VerifyingKey{Backend: b, Curve: curve, Key: b.newVerifyingKey(curve)}
+	if err := readArtifact(dir, m, artifactVK, vk.Key); err != nil {
+		return nil, nil, err
+	}
+	return m, vk, nil
+}
+
+// errNoSetup 在未提供可信设置产物时返回
+var errNoSetup = errors.New("no setup artifacts, run the setup command first")
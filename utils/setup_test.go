@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+func TestSetupArtifacts(t *testing.T) {
+	for _, b := range []Backend{BackendGroth16, BackendPlonk} {
+		testSetupArtifacts(t, b)
+	}
+}
+
+func testSetupArtifacts(t *testing.T, b Backend) {
+	ct := CredentialType{Name: "test", Curve: ecc.BN254, Hash: HashPoseidon2, Mode: HashModeDomainTag}
+	artifacts, err := setupBackend(b, ct, 2, UnsafeSRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := artifacts.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	// 文件名带电路版本，零值版本记为 1
+	if name := artifacts.Manifest.Files[artifactVK]; name != "test.v1.vk" {
+		t.Fatalf("verifying key saved as %s", name)
+	}
+	v2 := *artifacts
+	v2.Manifest.CircuitVersion = 2
+	if err := v2.Save(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if name := v2.Manifest.Files[artifactVK]; name != "test.v2.vk" {
+		t.Fatalf("verifying key of version 2 saved as %s", name)
+	}
+
+	setup, err := LoadSetup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := setup.Manifest.CredentialType()
+	if backend, _ := setup.Manifest.BackendID(); err != nil || loaded != ct || setup.Manifest.Depth != 2 || backend != b {
+		t.Fatalf("manifest does not describe the circuit: %+v", setup.Manifest)
+	}
+
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(1)
+	for _, d := range testLeaves(4) {
+		tree.Push(d)
+	}
+	root, proofSet, proofIndex, _ := tree.Prove()
+	assignment := ValidCircuit{
+		MerkleRoot: BytesToVariable(root),
+		Message:    0,
+		Leaf:       BytesToVariable(proofSet[0]),
+		Path:       BytesArrayToVariables(proofSet[1:]),
+		Helper:     IndexToHelper(proofIndex, 2),
+		Depth:      2,
+	}
+	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := b.prove(setup.CCS, setup.PK, witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, vk, err := LoadVerifyingKey(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.verify(proof, vk.Key, publicWitness); err != nil {
+		t.Fatalf("proof rejected with the saved verifying key: %v", err)
+	}
+
+	// 篡改验证密钥文件后加载应失败
+	vkFile := filepath.Join(dir, setup.Manifest.Files[artifactVK])
+	data, err := os.ReadFile(vkFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 1
+	if err := os.WriteFile(vkFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadVerifyingKey(dir); err == nil {
+		t.Fatal("tampered verifying key loaded")
+	}
+	if _, err := LoadSetup(dir); err == nil {
+		t.Fatal("tampered setup loaded")
+	}
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// ShareScheme 是叶子哈希的秘密分享方式
+// A ShareScheme tells how the leaf hashes of a session are secret shared.
+type ShareScheme uint8
+
+const (
+	// ShareSchemeAdditive splits a secret into shares that sum to it.
+	ShareSchemeAdditive ShareScheme = iota
+	// ShareSchemeShamir evaluates a random polynomial of degree Threshold-1
+	// with the secret as constant term at x = 1..Parties. Combine still needs
+	// the submissions of all Parties clients, in order: the secret is
+	// recovered from the first Threshold shares and the others must lie on
+	// the same polynomial. Here Shamir sharing is a consistency check on the
+	// submissions, not a way to tolerate missing clients.
+	ShareSchemeShamir
+)
+
+// String returns the name of the share scheme.
+func (s ShareScheme) String() string {
+	switch s {
+	case ShareSchemeAdditive:
+		return "additive"
+	case ShareSchemeShamir:
+		return "shamir"
+	default:
+		return fmt.Sprintf("ShareScheme(%d)", uint8(s))
+	}
+}
+
+// ShareConfig 描述一个会话中叶子的分享方式。一个分组内的 Parties 个客户端各自提交
+// Parties 个份额，第 i 个客户端提交的第 j 个份额是第 j 个客户端叶子哈希的第 i 个份额。
+// A ShareConfig is the share metadata of a session. Clients are grouped by
+// Parties. Within a group, client i submits Parties shares, the j-th being its
+// share of the leaf hash of client j. Recombining column j over the group
+// gives the leaf of client j.
+type ShareConfig struct {
+	Scheme  ShareScheme
+	Parties int
+	// 仅用于 Shamir：多项式次数为 Threshold-1。还原时仍需全部 Parties 个提交，
+	// 超出门限的份额只用于一致性检查，缺少提交的客户端无法由门限容忍
+	Threshold int
+}
+
+// DefaultShareConfig 是配对客户端之间的两方加法分享，与原有的 64 字节叶子格式一致
+// DefaultShareConfig is the two-party additive sharing used by paired clients,
+// i.e. the historical 64-byte submission.
+var DefaultShareConfig = ShareConfig{Scheme: ShareSchemeAdditive, Parties: 2, Threshold: 2}
+
+// Validate checks the config.
+func (c ShareConfig) Validate() error {
+	if c.Parties < 1 {
+		return fmt.Errorf("invalid number of parties %d", c.Parties)
+	}
+	switch c.Scheme {
+	case ShareSchemeAdditive:
+		return nil
+	case ShareSchemeShamir:
+		if c.Threshold < 1 || c.Threshold > c.Parties {
+			return fmt.Errorf("invalid Shamir threshold %d for %d parties", c.Threshold, c.Parties)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown share scheme %v", c.Scheme)
+	}
+}
+
+// SubmissionSize returns the number of bytes submitted by each client.
+func (c ShareConfig) SubmissionSize() int {
+	return c.Parties * fr.Bytes
+}
+
+// ParseShares 解析一次提交，每个份额必须是规范编码的域元素
+// ParseShares decodes a submission into its shares. Every share must be the
+// canonical 32-byte big endian encoding of a field element.
+func (c ShareConfig) ParseShares(data []byte) ([]fr.Element, error) {
+	if len(data) != c.SubmissionSize() {
+		return nil, fmt.Errorf("invalid data length: expected %d bytes, got %d", c.SubmissionSize(), len(data))
+	}
+	shares := make([]fr.Element, c.Parties)
+	for i := range shares {
+		if err := shares[i].SetBytesCanonical(data[i*fr.Bytes : (i+1)*fr.Bytes]); err != nil {
+			return nil, fmt.Errorf("share %d is not a canonical field element: %v", i, err)
+		}
+	}
+	return shares, nil
+}
+
+// Combine 还原一个分组的叶子，submissions[i] 为分组内第 i 个客户端的提交，Shamir 分享时同样需要全部 Parties 个提交
+// Combine recombines the leaves of one group, submissions[i] being the data
+// of the i-th client of the group. It returns one leaf per client. All
+// Parties submissions are required for both schemes (see ShareSchemeShamir).
+func (c ShareConfig) Combine(submissions [][]byte) ([]fr.Element, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if len(submissions) != c.Parties {
+		return nil, fmt.Errorf("expected %d submissions, got %d", c.Parties, len(submissions))
+	}
+	shares := make([][]fr.Element, len(submissions))
+	for i, data := range submissions {
+		s, err := c.ParseShares(data)
+		if err != nil {
+			return nil, fmt.Errorf("client %d: %v", i, err)
+		}
+		shares[i] = s
+	}
+
+	leaves := make([]fr.Element, c.Parties)
+	column := make([]fr.Element, c.Parties)
+	for j := range leaves {
+		for i := range shares {
+			column[i] = shares[i][j]
+		}
+		leaf, err := c.combineColumn(column)
+		if err != nil {
+			return nil, fmt.Errorf("leaf %d: %v", j, err)
+		}
+		leaves[j] = leaf
+	}
+	return leaves, nil
+}
+
+func (c ShareConfig) combineColumn(shares []fr.Element) (fr.Element, error) {
+	var secret fr.Element
+	if c.Scheme == ShareSchemeAdditive {
+		for i := range shares {
+			secret.Add(&secret, &shares[i])
+		}
+		return secret, nil
+	}
+
+	xs := shamirPoints(len(shares))
+	var zero fr.Element
+	secret = lagrangeAt(xs[:c.Threshold], shares[:c.Threshold], &zero)
+	// 多余的份额必须落在同一多项式上
+	for i := c.Threshold; i < len(shares); i++ {
+		y := lagrangeAt(xs[:c.Threshold], shares[:c.Threshold], &xs[i])
+		if !y.Equal(&shares[i]) {
+			return fr.Element{}, errors.New("inconsistent Shamir shares")
+		}
+	}
+	return secret, nil
+}
+
+// Split 将秘密拆分为 Parties 个份额，供客户端使用
+// Split shares a secret among the parties of the config.
+func (c ShareConfig) Split(secret fr.Element) ([]fr.Element, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	shares := make([]fr.Element, c.Parties)
+	if c.Scheme == ShareSchemeAdditive {
+		last := secret
+		for i := 0; i < c.Parties-1; i++ {
+			if _, err := shares[i].SetRandom(); err != nil {
+				return nil, err
+			}
+			last.Sub(&last, &shares[i])
+		}
+		shares[c.Parties-1] = last
+		return shares, nil
+	}
+
+	coeffs := make([]fr.Element, c.Threshold)
+	coeffs[0] = secret
+	for i := 1; i < len(coeffs); i++ {
+		if _, err := coeffs[i].SetRandom(); err != nil {
+			return nil, err
+		}
+	}
+	xs := shamirPoints(c.Parties)
+	for i := range shares {
+		// Horner
+		for k := len(coeffs) - 1; k >= 0; k-- {
+			shares[i].Mul(&shares[i], &xs[i]).Add(&shares[i], &coeffs[k])
+		}
+	}
+	return shares, nil
+}
+
+// shamirPoints 返回各方的求值点 x = 1..n
+func shamirPoints(n int) []fr.Element {
+	xs := make([]fr.Element, n)
+	for i := range xs {
+		xs[i].SetUint64(uint64(i + 1))
+	}
+	return xs
+}
+
+// lagrangeAt 在 x 处计算经过 (xs[i], ys[i]) 的插值多项式的值
+func lagrangeAt(xs, ys []fr.Element, x *fr.Element) fr.Element {
+	var res fr.Element
+	for i := range xs {
+		var num, den, d fr.Element
+		num.SetOne()
+		den.SetOne()
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			d.Sub(x, &xs[j])
+			num.Mul(&num, &d)
+			d.Sub(&xs[i], &xs[j])
+			den.Mul(&den, &d)
+		}
+		num.Div(&num, &den)
+		num.Mul(&num, &ys[i])
+		res.Add(&res, &num)
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// shareSubmissions 模拟一个分组内各客户端的提交：第 i 个客户端持有每个叶子的第 i 个份额
+func shareSubmissions(t *testing.T, cfg ShareConfig, leaves []fr.Element) [][]byte {
+	t.Helper()
+	submissions := make([][]byte, cfg.Parties)
+	for j := range leaves {
+		shares, err := cfg.Split(leaves[j])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range shares {
+			b := shares[i].Bytes()
+			submissions[i] = append(submissions[i], b[:]...)
+		}
+	}
+	return submissions
+}
+
+func TestShareCombine(t *testing.T) {
+	configs := []ShareConfig{
+		DefaultShareConfig,
+		{Scheme: ShareSchemeAdditive, Parties: 5},
+		{Scheme: ShareSchemeShamir, Parties: 3, Threshold: 3},
+		{Scheme: ShareSchemeShamir, Parties: 5, Threshold: 3},
+	}
+	for _, cfg := range configs {
+		leaves := make([]fr.Element, cfg.Parties)
+		for i := range leaves {
+			leaves[i].SetRandom()
+		}
+		submissions := shareSubmissions(t, cfg, leaves)
+		got, err := cfg.Combine(submissions)
+		if err != nil {
+			t.Fatalf("%+v: %v", cfg, err)
+		}
+		for i := range leaves {
+			if !got[i].Equal(&leaves[i]) {
+				t.Fatalf("%+v: leaf %d not recovered", cfg, i)
+			}
+		}
+
+		if cfg.Scheme == ShareSchemeShamir && cfg.Parties > cfg.Threshold {
+			// 门限只决定多项式次数，缺少提交时不还原
+			if _, err := cfg.Combine(submissions[:cfg.Threshold]); err == nil {
+				t.Fatalf("%+v: group recombined from %d submissions", cfg, cfg.Threshold)
+			}
+			submissions[cfg.Parties-1][31] ^= 1
+			if _, err := cfg.Combine(submissions); err == nil {
+				t.Fatalf("%+v: inconsistent shares accepted", cfg)
+			}
+		}
+	}
+}
+
+func TestShareValidation(t *testing.T) {
+	cfg := DefaultShareConfig
+	if _, err := cfg.ParseShares(make([]byte, 32)); err == nil {
+		t.Fatal("short submission accepted")
+	}
+	data := make([]byte, cfg.SubmissionSize())
+	for i := range data[:32] {
+		data[i] = 0xff
+	}
+	if _, err := cfg.ParseShares(data); err == nil {
+		t.Fatal("non canonical share accepted")
+	}
+	if _, err := cfg.Combine([][]byte{make([]byte, 64)}); err == nil {
+		t.Fatal("incomplete group accepted")
+	}
+	for _, bad := range []ShareConfig{
+		{Scheme: ShareSchemeAdditive},
+		{Scheme: ShareSchemeShamir, Parties: 2, Threshold: 3},
+		{Scheme: ShareScheme(7), Parties: 2},
+	} {
+		if bad.Validate() == nil {
+			t.Fatalf("%+v accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// SparseTreeDepth 是稀疏 merkle 树的深度，每个 254 位的域元素键都有自己的叶子位置
+// SparseTreeDepth is the depth of a SparseTree. Every 254-bit field element
+// key owns a distinct leaf, so membership and non-membership are both shown
+// with a path of SparseTreeDepth siblings.
+const SparseTreeDepth = fr.Bits
+
+// A SparseTree is a sparse Merkle tree keyed by bn254 field elements and
+// hashed with MiMC. Leaves and nodes are hashed as in HashModeDomainTag:
+//
+//	leaf  = MiMC(leafTag, key, value)
+//	node  = MiMC(nodeTag, left, right)
+//	empty = 0 for leaves, MiMC(nodeTag, empty, empty) above
+//
+// At height i the i-th bit of the key (least significant first) selects the
+// right (1) or the left (0) child. Only non-empty nodes are stored.
+// 稀疏 merkle 树，用于撤销列表、黑名单等需要证明“不在集合中”的场景
+type SparseTree struct {
+	hash   hash.Hash
+	leaves map[fr.Element]fr.Element
+	nodes  map[sparseNodeKey]fr.Element
+}
+
+// sparseNodeKey 定位一个内部节点：高度以及 key >> height
+type sparseNodeKey struct {
+	height int
+	prefix [4]uint64
+}
+
+// A SparseProof shows that Key maps to Value in the tree (Exists is true) or
+// that Key is not in the tree (Exists is false and Value is zero). Siblings
+// are ordered from the leaf level up to the root.
+type SparseProof struct {
+	Key      fr.Element   `json:"key"`
+	Value    fr.Element   `json:"value"`
+	Exists   bool         `json:"exists"`
+	Siblings []fr.Element `json:"siblings"`
+}
+
+var (
+	sparseEmptyOnce   sync.Once
+	sparseEmptyHashes [SparseTreeDepth + 1]fr.Element
+)
+
+// sparseEmpty 返回高度为 height 的空子树的根
+func sparseEmpty(height int) *fr.Element {
+	sparseEmptyOnce.Do(func() {
+		h := mimc.NewMiMC()
+		for i := 0; i < SparseTreeDepth; i++ {
+			sparseEmptyHashes[i+1] = sparseNodeSum(h, &sparseEmptyHashes[i], &sparseEmptyHashes[i])
+		}
+	})
+	return &sparseEmptyHashes[height]
+}
+
+func sparseLeafSum(h hash.Hash, key, value *fr.Element) fr.Element {
+	k, v := key.Bytes(), value.Bytes()
+	var res fr.Element
+	res.SetBytes(sum(h, leafDomainTag, k[:], v[:]))
+	return res
+}
+
+func sparseNodeSum(h hash.Hash, left, right *fr.Element) fr.Element {
+	l, r := left.Bytes(), right.Bytes()
+	var res fr.Element
+	res.SetBytes(HashModeDomainTag.NodeSum(h, l[:], r[:]))
+	return res
+}
+
+// keyBit 返回 key 的第 i 位（低位在前）
+func keyBit(key *fr.Element, i int) uint64 {
+	return (key.Bits()[i/64] >> uint(i%64)) & 1
+}
+
+// keyPrefix 返回 key >> height
+func keyPrefix(key *fr.Element, height int) [4]uint64 {
+	bits := key.Bits()
+	var res [4]uint64
+	words, shift := height/64, uint(height%64)
+	for i := 0; i+words < 4; i++ {
+		res[i] = bits[i+words] >> shift
+		if shift != 0 && i+words+1 < 4 {
+			res[i] |= bits[i+words+1] << (64 - shift)
+		}
+	}
+	return res
+}
+
+// NewSparseTree 创建空的稀疏 merkle 树，只支持 BN254 与 MiMC，其他 Profile 返回错误
+// NewSparseTree creates an empty SparseTree for profile 'p'. Keys, values and
+// proofs are bn254 field elements hashed with MiMC, so any profile other than
+// DefaultProfile is rejected.
+func NewSparseTree(p Profile) (*SparseTree, error) {
+	if err := p.checkBN254(); err != nil {
+		return nil, err
+	}
+	if p.Hash != HashMiMC {
+		return nil, fmt.Errorf("profile %v: sparse trees are hashed with %v", p, HashMiMC)
+	}
+	return &SparseTree{
+		hash:   p.NewHash(),
+		leaves: make(map[fr.Element]fr.Element),
+		nodes:  make(map[sparseNodeKey]fr.Element),
+	}, nil
+}
+
+// Root returns the Merkle root of the tree.
+func (t *SparseTree) Root() fr.Element {
+	return t.node(SparseTreeDepth, [4]uint64{})
+}
+
+// Get returns the value stored under key.
+func (t *SparseTree) Get(key fr.Element) (fr.Element, bool) {
+	v, ok := t.leaves[key]
+	return v, ok
+}
+
+// Insert adds a new key. It fails if the key is already present.
+func (t *SparseTree) Insert(key, value fr.Element) error {
+	if _, ok := t.leaves[key]; ok {
+		return errors.New("key already present in sparse tree")
+	}
+	t.leaves[key] = value
+	t.update(&key)
+	return nil
+}
+
+// Update changes the value of an existing key.
+func (t *SparseTree) Update(key, value fr.Element) error {
+	if _, ok := t.leaves[key]; !ok {
+		return errors.New("key not present in sparse tree")
+	}
+	t.leaves[key] = value
+	t.update(&key)
+	return nil
+}
+
+// Delete removes a key, turning its leaf back into an empty leaf.
+func (t *SparseTree) Delete(key fr.Element) error {
+	if _, ok := t.leaves[key]; !ok {
+		return errors.New("key not present in sparse tree")
+	}
+	delete(t.leaves, key)
+	t.update(&key)
+	return nil
+}
+
+// Prove returns a membership proof if key is present and a non-membership
+// proof otherwise.
+func (t *SparseTree) Prove(key fr.Element) *SparseProof {
+	proof := &SparseProof{
+		Key:      key,
+		Siblings: make([]fr.Element, SparseTreeDepth),
+	}
+	proof.Value, proof.Exists = t.leaves[key]
+	for height := 0; height < SparseTreeDepth; height++ {
+		prefix := keyPrefix(&key, height)
+		prefix[0] ^= 1
+		proof.Siblings[height] = t.node(height, prefix)
+	}
+	return proof
+}
+
+// node 返回节点的哈希，未存储的节点为空子树
+func (t *SparseTree) node(height int, prefix [4]uint64) fr.Element {
+	if v, ok := t.nodes[sparseNodeKey{height, prefix}]; ok {
+		return v
+	}
+	return *sparseEmpty(height)
+}
+
+func (t *SparseTree) setNode(height int, prefix [4]uint64, v fr.Element) {
+	k := sparseNodeKey{height, prefix}
+	if v.Equal(sparseEmpty(height)) {
+		delete(t.nodes, k)
+	} else {
+		t.nodes[k] = v
+	}
+}
+
+// update 重新计算 key 所在路径上的所有节点
+func (t *SparseTree) update(key *fr.Element) {
+	curr := *sparseEmpty(0)
+	if v, ok := t.leaves[*key]; ok {
+		curr = sparseLeafSum(t.hash, key, &v)
+	}
+	t.setNode(0, keyPrefix(key, 0), curr)
+	for height := 0; height < SparseTreeDepth; height++ {
+		prefix := keyPrefix(key, height)
+		sibling := prefix
+		sibling[0] ^= 1
+		s := t.node(height, sibling)
+		if keyBit(key, height) == 0 {
+			curr = sparseNodeSum(t.hash, &curr, &s)
+		} else {
+			curr = sparseNodeSum(t.hash, &s, &curr)
+		}
+		t.setNode(height+1, keyPrefix(key, height+1), curr)
+	}
+}
+
+// Verify returns true if the proof is valid for the given root.
+func (p *SparseProof) Verify(root fr.Element) bool {
+	if len(p.Siblings) != SparseTreeDepth {
+		return false
+	}
+	h := mimc.NewMiMC()
+	curr := *sparseEmpty(0)
+	if p.Exists {
+		curr = sparseLeafSum(h, &p.Key, &p.Value)
+	} else if !p.Value.IsZero() {
+		return false
+	}
+	for height := 0; height < SparseTreeDepth; height++ {
+		if keyBit(&p.Key, height) == 0 {
+			curr = sparseNodeSum(h, &curr, &p.Siblings[height])
+		} else {
+			curr = sparseNodeSum(h, &p.Siblings[height], &curr)
+		}
+	}
+	return curr.Equal(&root)
+}
+
+// SparseMerkleProof 是 SparseProof 在电路内的对应结构
+// SparseMerkleProof is the in-circuit counterpart of SparseProof. Exists must
+// be 1 for a membership proof and 0 for a non-membership proof.
+type SparseMerkleProof struct {
+	Key      frontend.Variable
+	Value    frontend.Variable
+	Exists   frontend.Variable
+	Siblings [SparseTreeDepth]frontend.Variable
+}
+
+// Assign 将原生 proof 转换为电路赋值
+// Assign returns the circuit assignment of a native proof.
+func (p *SparseProof) Assign() SparseMerkleProof {
+	var res SparseMerkleProof
+	res.Key = p.Key.BigInt(new(big.Int))
+	res.Value = p.Value.BigInt(new(big.Int))
+	res.Exists = 0
+	if p.Exists {
+		res.Exists = 1
+	}
+	for i := range res.Siblings {
+		res.Siblings[i] = p.Siblings[i].BigInt(new(big.Int))
+	}
+	return res
+}
+
+// AssertRoot asserts that the proof is valid for root.
+func (p *SparseMerkleProof) AssertRoot(api frontend.API, root frontend.Variable) error {
+	api.AssertIsBoolean(p.Exists)
+	// 非成员证明中 Value 必须为 0
+	api.AssertIsEqual(api.Mul(api.Sub(1, p.Exists), p.Value), 0)
+
+	leaf, err := circuitSum(api, Profile{Hash: HashMiMC}, new(big.Int).SetBytes(leafDomainTag), p.Key, p.Value)
+	if err != nil {
+		return err
+	}
+	curr := api.Select(p.Exists, leaf, 0)
+
+	bits := api.ToBinary(p.Key, SparseTreeDepth)
+	for i := 0; i < SparseTreeDepth; i++ {
+		left := api.Select(bits[i], p.Siblings[i], curr)
+		right := api.Select(bits[i], curr, p.Siblings[i])
+		curr, err = circuitNodeSum(api, Profile{Hash: HashMiMC}, HashModeDomainTag, left, right)
+		if err != nil {
+			return err
+		}
+	}
+	api.AssertIsEqual(curr, root)
+	return nil
+}
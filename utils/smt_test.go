@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type sparseCircuit struct {
+	Root  frontend.Variable `gnark:",public"`
+	Proof SparseMerkleProof
+}
+
+func (c *sparseCircuit) Define(api frontend.API) error {
+	return c.Proof.AssertRoot(api, c.Root)
+}
+
+func TestSparseTree(t *testing.T) {
+	tree, err := NewSparseTree(DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := tree.Root()
+
+	keys := make([]fr.Element, 5)
+	for i := range keys {
+		keys[i].SetRandom()
+		var v fr.Element
+		v.SetUint64(uint64(i))
+		if err := tree.Insert(keys[i], v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tree.Insert(keys[0], fr.Element{}); err == nil {
+		t.Fatal("duplicate insert accepted")
+	}
+	root := tree.Root()
+
+	for i := range keys {
+		proof := tree.Prove(keys[i])
+		if !proof.Exists || !proof.Verify(root) {
+			t.Fatalf("membership proof %d rejected", i)
+		}
+	}
+
+	var absent fr.Element
+	absent.SetRandom()
+	proof := tree.Prove(absent)
+	if proof.Exists || !proof.Verify(root) {
+		t.Fatal("non-membership proof rejected")
+	}
+	proof.Exists = true
+	if proof.Verify(root) {
+		t.Fatal("forged membership proof accepted")
+	}
+
+	var v fr.Element
+	v.SetUint64(42)
+	if err := tree.Update(keys[1], v); err != nil {
+		t.Fatal(err)
+	}
+	if r := tree.Root(); r.Equal(&root) {
+		t.Fatal("update did not change the root")
+	}
+	if tree.Prove(keys[1]).Verify(root) {
+		t.Fatal("proof against stale root accepted")
+	}
+
+	for i := range keys {
+		if err := tree.Delete(keys[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := tree.Root(); !r.Equal(&empty) || len(tree.nodes) != 0 {
+		t.Fatal("deleting every key did not restore the empty tree")
+	}
+}
+
+// 稀疏树的键与值是 bn254 域元素，只支持默认 Profile
+func TestSparseTreeProfile(t *testing.T) {
+	for _, p := range []Profile{{Curve: ecc.BLS12_381}, {Hash: HashPoseidon2}} {
+		if _, err := NewSparseTree(p); err == nil {
+			t.Fatalf("sparse tree created for profile %v", p)
+		}
+	}
+}
+
+func TestSparseTreeCircuit(t *testing.T) {
+	tree, err := NewSparseTree(DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var member, absent, value fr.Element
+	member.SetRandom()
+	absent.SetRandom()
+	value.SetUint64(7)
+	if err := tree.Insert(member, value); err != nil {
+		t.Fatal(err)
+	}
+	root := tree.Root()
+
+	for _, key := range []fr.Element{member, absent} {
+		assignment := sparseCircuit{Root: root.String(), Proof: tree.Prove(key).Assign()}
+		if err := test.IsSolved(&sparseCircuit{}, &assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 将成员证明伪装为非成员证明应被拒绝
+	assignment := sparseCircuit{Root: root.String(), Proof: tree.Prove(member).Assign()}
+	assignment.Proof.Exists = 0
+	assignment.Proof.Value = 0
+	if err := test.IsSolved(&sparseCircuit{}, &assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("circuit accepted non-membership of a member")
+	}
+}
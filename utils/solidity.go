@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	groth16bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	plonkbn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"golang.org/x/crypto/sha3"
+)
+
+//go:generate go test -run TestSolidityVerifier -update-solidity
+
+// SolidityCurve 是 EVM 预编译合约支持配对运算的曲线
+// SolidityCurve is the only curve with pairing precompiles on the EVM.
+const SolidityCurve = ecc.BN254
+
+// Solidity 验证合约的入口，Groth16 验证失败时 revert，PLONK 返回 bool
+const (
+	solidityGroth16Verify = "verifyProof(uint256[8],uint256[%d])"
+	solidityPlonkVerify   = "Verify(bytes,uint256[])"
+)
+
+// SolidityContract 返回 ExportSolidity 生成的合约名
+// SolidityContract returns the name of the contract ExportSolidity writes
+// for keys of backend 'b'.
+func SolidityContract(b Backend) string {
+	if b == BackendPlonk {
+		return "PlonkVerifier"
+	}
+	return "Verifier"
+}
+
+// ExportSolidity 将 BN254 上的验证密钥导出为 Solidity 验证合约。
+// 合约只验证证明本身，聚合签名需由调用方在链下或通过其他合约检查。
+// ExportSolidity writes a Solidity contract verifying proofs of the key
+// 'vk', which must be on SolidityCurve. The contract checks the proof only:
+// the aggregate signature over the root is left to the caller. Groth16 keys
+// of circuits with commitments are rejected, since their proofs would need
+// a Keccak hash-to-field.
+func ExportSolidity(vk *VerifyingKey, w io.Writer) error {
+	if vk == nil || vk.Key == nil {
+		return errors.New("missing verifying key")
+	}
+	if vk.Curve != SolidityCurve {
+		return fmt.Errorf("solidity verifiers need keys on %s, got %s", SolidityCurve, vk.Curve)
+	}
+	switch vk.Backend {
+	case BackendGroth16:
+		k, ok := vk.Key.(*groth16bn254.VerifyingKey)
+		if !ok {
+			return fmt.Errorf("not a %v verifying key", vk.Backend)
+		}
+		if len(k.CommitmentKeys) > 0 {
+			return errors.New("groth16 circuits with commitments are not supported")
+		}
+		return k.ExportSolidity(w)
+	case BackendPlonk:
+		k, ok := vk.Key.(*plonkbn254.VerifyingKey)
+		if !ok {
+			return fmt.Errorf("not a %v verifying key", vk.Backend)
+		}
+		return k.ExportSolidity(w)
+	default:
+		return fmt.Errorf("unknown backend %v", vk.Backend)
+	}
+}
+
+// SolidityCalldata 返回以 public 调用 ExportSolidity 合约验证 proof 的 ABI 编码 calldata
+// SolidityCalldata returns the ABI encoded call to the contract written by
+// ExportSolidity that verifies 'proof' against 'public': verifyProof for
+// Groth16, which reverts on invalid proofs, and Verify for PLONK, which
+// returns a bool.
+func SolidityCalldata(proof *ZKProof, public *PublicInputs) ([]byte, error) {
+	if proof == nil || proof.Proof == nil || public == nil {
+		return nil, errors.New("missing proof or public inputs")
+	}
+	if proof.Curve != SolidityCurve {
+		return nil, fmt.Errorf("solidity verifiers need proofs on %s, got %s", SolidityCurve, proof.Curve)
+	}
+	w, err := public.Witness(proof.Curve)
+	if err != nil {
+		return nil, fmt.Errorf("build public witness: %v", err)
+	}
+	values, err := witnessValues(w)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]byte, 0, 32*len(values))
+	for _, v := range values {
+		inputs = append(inputs, v.FillBytes(make([]byte, 32))...)
+	}
+
+	switch proof.Backend {
+	case BackendGroth16:
+		p, ok := proof.Proof.(*groth16bn254.Proof)
+		if !ok {
+			return nil, fmt.Errorf("not a %v proof", proof.Backend)
+		}
+		if len(p.Commitments) > 0 {
+			return nil, errors.New("groth16 proofs with commitments are not supported")
+		}
+		// uint256[8] 与 uint256[N] 都是定长数组，按顺序直接编码
+		data := soliditySelector(fmt.Sprintf(solidityGroth16Verify, len(values)))
+		data = append(data, p.MarshalSolidity()...)
+		return append(data, inputs...), nil
+	case BackendPlonk:
+		p, ok := proof.Proof.(*plonkbn254.Proof)
+		if !ok {
+			return nil, fmt.Errorf("not a %v proof", proof.Backend)
+		}
+		raw := p.MarshalSolidity()
+		padded := (len(raw) + 31) / 32 * 32
+		// 两个动态参数：先是偏移，再是 bytes 与 uint256[] 的长度和内容
+		data := soliditySelector(solidityPlonkVerify)
+		data = append(data, abiWord(64)...)
+		data = append(data, abiWord(uint64(64+32+padded))...)
+		data = append(data, abiWord(uint64(len(raw)))...)
+		data = append(data, raw...)
+		data = append(data, make([]byte, padded-len(raw))...)
+		data = append(data, abiWord(uint64(len(values)))...)
+		return append(data, inputs...), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %v", proof.Backend)
+	}
+}
+
+// soliditySelector 返回函数签名的 4 字节选择器
+func soliditySelector(signature string) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+	return h.Sum(nil)[:4]
+}
+
+// abiWord 将整数编码为 32 字节大端序的 ABI 字
+func abiWord(v uint64) []byte {
+	word := make([]byte, 32)
+	binary.BigEndian.PutUint64(word[24:], v)
+	return word
+}
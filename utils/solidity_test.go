@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"flag"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// 合约入口的 ABI，用于交叉检查 SolidityCalldata 的编码
+const testVerifierABI = `[
+	{"type":"function","name":"verifyProof","stateMutability":"view","outputs":[],
+	 "inputs":[{"name":"proof","type":"uint256[8]"},{"name":"input","type":"uint256[1]"}]},
+	{"type":"function","name":"Verify","stateMutability":"view","outputs":[{"name":"","type":"bool"}],
+	 "inputs":[{"name":"proof","type":"bytes"},{"name":"public_inputs","type":"uint256[]"}]}
+]`
+
+func testSolidityProof(t *testing.T, b Backend) (*SetupArtifacts, *ZKProof, *PublicInputs) {
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	setup, err := setupBackend(b, ct, 2, UnsafeSRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, publics, _ := testInnerProofs(t, setup, ct, 1)
+	return setup, proofs[0], publics[0]
+}
+
+func TestSolidityCalldata(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testVerifierABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []Backend{BackendGroth16, BackendPlonk} {
+		setup, proof, public := testSolidityProof(t, b)
+		var contract bytes.Buffer
+		if err := ExportSolidity(setup.VerifyingKey(), &contract); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(contract.String(), "contract "+SolidityContract(b)+" ") {
+			t.Fatalf("%v: contract %s not exported", b, SolidityContract(b))
+		}
+
+		calldata, err := SolidityCalldata(proof, public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := new(big.Int).SetBytes(public.MerkleRoot)
+		var expected []byte
+		if b == BackendGroth16 {
+			var points [8]*big.Int
+			raw := calldata[4 : 4+8*32]
+			for i := range points {
+				points[i] = new(big.Int).SetBytes(raw[32*i : 32*(i+1)])
+			}
+			expected, err = parsed.Pack("verifyProof", points, [1]*big.Int{root})
+		} else {
+			raw := calldata[4+3*32:]
+			n := new(big.Int).SetBytes(calldata[4+2*32 : 4+3*32]).Int64()
+			expected, err = parsed.Pack("Verify", raw[:n], []*big.Int{root})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(calldata, expected) {
+			t.Fatalf("%v: calldata differs from the ABI encoding", b)
+		}
+	}
+
+	// EVM 上只有 BN254 的配对预编译
+	ct := CredentialType{Name: "test", Curve: ecc.BLS12_381}
+	setup, err := Setup(ct, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportSolidity(setup.VerifyingKey(), new(bytes.Buffer)); err == nil {
+		t.Fatal("solidity verifier exported for bls12-381")
+	}
+}
+
+// updateSolidity 用 PATH 中的 solc 重新生成 testdata/solidity 下的验证密钥、合约、字节码与证明
+var updateSolidity = flag.Bool("update-solidity", false, "regenerate testdata/solidity, needs solc in PATH")
+
+// TestSolidityVerifier 在本地模拟 EVM 上部署导出的合约并验证证明。
+// 合约字节码与证明检入 testdata/solidity，由 go generate 重新生成
+func TestSolidityVerifier(t *testing.T) {
+	for _, b := range []Backend{BackendGroth16, BackendPlonk} {
+		dir := filepath.Join("testdata", "solidity", b.String())
+		if *updateSolidity {
+			updateSolidityTestdata(t, b, dir)
+		}
+		_, vk, err := LoadVerifyingKey(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var contract bytes.Buffer
+		if err := ExportSolidity(vk, &contract); err != nil {
+			t.Fatal(err)
+		}
+		source, err := os.ReadFile(filepath.Join(dir, "verifier.sol"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contract.Bytes(), source) {
+			t.Fatalf("%v: exported contract differs from testdata, run go generate", b)
+		}
+		bin, err := os.ReadFile(filepath.Join(dir, SolidityContract(b)+".bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := hex.DecodeString(strings.TrimSpace(string(bin)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "proof.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := DecodeEnvelope(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := e.ZKProof()
+		if err != nil {
+			t.Fatal(err)
+		}
+		public, err := e.PublicInputs()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		call := deployTestContract(t, code)
+		calldata, err := SolidityCalldata(proof, public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Groth16 验证失败时 revert，PLONK 返回 bool
+		accepted := func(data []byte) bool {
+			out, err := call(data)
+			return err == nil && (b == BackendGroth16 || len(out) == 32 && out[31] == 1)
+		}
+		if !accepted(calldata) {
+			t.Fatalf("%v: valid proof rejected on chain", b)
+		}
+		forged := *public
+		forged.MerkleRoot = HashModePlain.LeafSum(DefaultProfile.NewHash(), public.MerkleRoot)
+		calldata, _ = SolidityCalldata(proof, &forged)
+		if accepted(calldata) {
+			t.Fatalf("%v: proof accepted on chain for another root", b)
+		}
+	}
+}
+
+// updateSolidityTestdata 重新生成目录 dir 中的测试数据，只保留验证方需要的产物
+func updateSolidityTestdata(t *testing.T, b Backend, dir string) {
+	solc, err := exec.LookPath("solc")
+	if err != nil {
+		t.Fatal("-update-solidity needs solc in PATH")
+	}
+	setup, proof, public := testSolidityProof(t, b)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := setup.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []string{b.constraintKind(), artifactPK} {
+		if err := os.Remove(filepath.Join(dir, setup.Manifest.Files[kind])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, err := NewEnvelope(&setup.Manifest, setup.VerifyingKey(), proof, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := e.Encode(EnvelopeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "proof.json"), append(data, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, "verifier.sol"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportSolidity(setup.VerifyingKey(), f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if out, err := exec.Command(solc, "--optimize", "--bin", "-o", dir, filepath.Join(dir, "verifier.sol")).CombinedOutput(); err != nil {
+		t.Fatalf("solc: %v\n%s", err, out)
+	}
+	// 只保留验证合约的字节码，solc 还会输出库与接口
+	bins, _ := filepath.Glob(filepath.Join(dir, "*.bin"))
+	for _, bin := range bins {
+		if filepath.Base(bin) != SolidityContract(b)+".bin" {
+			os.Remove(bin)
+		}
+	}
+}
+
+// deployTestContract 在模拟链上部署合约，返回调用它的函数
+func deployTestContract(t *testing.T, code []byte) func([]byte) ([]byte, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	balance, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	sim := simulated.NewBackend(types.GenesisAlloc{from: {Balance: balance}})
+	t.Cleanup(func() { sim.Close() })
+	client := sim.Client()
+	ctx := context.Background()
+
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 10_000_000, gasPrice, code), types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("contract deployment failed")
+	}
+	return func(data []byte) ([]byte, error) {
+		return client.CallContract(ctx, ethereum.CallMsg{From: from, To: &receipt.ContractAddress, Data: data}, nil)
+	}
+}
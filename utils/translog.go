@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+// treeHeadPrefix 是树头签名消息的前缀，避免与 merkle root 签名混淆
+var treeHeadPrefix = []byte("DID-transparency-log-tree-head-v1")
+
+// LogEntry 记录一次凭证签发：会话、merkle root、聚合签名以及参与签名的机构公钥
+// A LogEntry records one issued credential root together with the aggregate
+// signature of the authorities over it.
+type LogEntry struct {
+	Session   uint64
+	Root      []byte
+	Signature bls12381.G2Affine
+	Signers   []bls12381.G1Affine
+}
+
+// MarshalBinary encodes the entry as the leaf data of the log:
+//
+//	uint64(session) || uvarint(len(root)) || root || signature ||
+//	uvarint(len(signers)) || signers...
+//
+// Points use their compressed encoding.
+func (e *LogEntry) MarshalBinary() ([]byte, error) {
+	buf := binary.BigEndian.AppendUint64(nil, e.Session)
+	buf = appendBytes(buf, e.Root)
+	sig := e.Signature.Bytes()
+	buf = append(buf, sig[:]...)
+	buf = binary.AppendUvarint(buf, uint64(len(e.Signers)))
+	for i := range e.Signers {
+		pk := e.Signers[i].Bytes()
+		buf = append(buf, pk[:]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes an entry encoded by MarshalBinary, as returned to
+// auditors by the log service.
+func (e *LogEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("log entry too short")
+	}
+	var entry LogEntry
+	entry.Session = binary.BigEndian.Uint64(data)
+	r := byteReader{data: data[8:]}
+	entry.Root = r.bytes()
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) < bls12381.SizeOfG2AffineCompressed {
+		return errors.New("unexpected end of data")
+	}
+	if _, err := entry.Signature.SetBytes(r.data[:bls12381.SizeOfG2AffineCompressed]); err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	r.data = r.data[bls12381.SizeOfG2AffineCompressed:]
+	n := r.uvarint()
+	if r.err != nil {
+		return r.err
+	}
+	if n != uint64(len(r.data))/bls12381.SizeOfG1AffineCompressed || len(r.data)%bls12381.SizeOfG1AffineCompressed != 0 {
+		return fmt.Errorf("%d signers do not match %d bytes of keys", n, len(r.data))
+	}
+	entry.Signers = make([]bls12381.G1Affine, n)
+	for i := range entry.Signers {
+		if _, err := entry.Signers[i].SetBytes(r.data[:bls12381.SizeOfG1AffineCompressed]); err != nil {
+			return fmt.Errorf("invalid signer %d: %v", i, err)
+		}
+		r.data = r.data[bls12381.SizeOfG1AffineCompressed:]
+	}
+	*e = entry
+	return nil
+}
+
+// Verify checks the aggregate signature of the entry over its root.
+func (e *LogEntry) Verify() error {
+	if len(e.Signers) == 0 {
+		return errors.New("log entry has no signer")
+	}
+	aggPK, _ := Aggregate(e.Signers, nil)
+	if !VerifyAggregateSignature(aggPK, e.Signature, e.Root) {
+		return errors.New("invalid aggregate signature on log entry")
+	}
+	return nil
+}
+
+// TreeHead 是日志在某一大小下的树头
+// A TreeHead commits to the first Size entries of the log.
+type TreeHead struct {
+	Size      uint64
+	Timestamp int64
+	Root      []byte
+}
+
+// Message returns the bytes signed by the authorities for the tree head.
+func (th *TreeHead) Message() []byte {
+	buf := append([]byte(nil), treeHeadPrefix...)
+	buf = binary.BigEndian.AppendUint64(buf, th.Size)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(th.Timestamp))
+	return append(buf, th.Root...)
+}
+
+// SignedTreeHead 是由多个机构 BLS 联合签名的树头
+// A SignedTreeHead is a tree head co-signed by several authorities. Signature
+// is the aggregate of the signatures of Signers over Message.
+type SignedTreeHead struct {
+	TreeHead
+	Signers   []bls12381.G1Affine
+	Signature bls12381.G2Affine
+}
+
+// CoSign 聚合各机构对树头的签名
+// CoSign aggregates the signatures of the authorities over the tree head.
+func CoSign(th TreeHead, pks []bls12381.G1Affine, signatures []bls12381.G2Affine) (*SignedTreeHead, error) {
+	if len(pks) == 0 || len(pks) != len(signatures) {
+		return nil, fmt.Errorf("need one signature per signer, got %d signers and %d signatures", len(pks), len(signatures))
+	}
+	_, aggSig := Aggregate(pks, signatures)
+	return &SignedTreeHead{
+		TreeHead:  th,
+		Signers:   append([]bls12381.G1Affine(nil), pks...),
+		Signature: aggSig,
+	}, nil
+}
+
+// Verify 检查树头至少由 threshold 个受信任的机构签名。
+// Verify checks that the tree head is signed by at least 'threshold' distinct
+// authorities from 'trusted'. Trusted keys must come with a proof of
+// possession, otherwise aggregating public keys allows rogue key attacks.
+func (sth *SignedTreeHead) Verify(trusted []bls12381.G1Affine, threshold int) error {
+	seen := make(map[[bls12381.SizeOfG1AffineCompressed]byte]bool)
+	for i := range sth.Signers {
+		key := sth.Signers[i].Bytes()
+		if seen[key] {
+			return errors.New("duplicate signer on tree head")
+		}
+		seen[key] = true
+		found := false
+		for j := range trusted {
+			if trusted[j].Equal(&sth.Signers[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("tree head signed by unknown authority %d", i)
+		}
+	}
+	if len(sth.Signers) < threshold {
+		return fmt.Errorf("tree head has %d signers, need %d", len(sth.Signers), threshold)
+	}
+	aggPK, _ := Aggregate(sth.Signers, nil)
+	if !VerifyAggregateSignature(aggPK, sth.Signature, sth.Message()) {
+		return errors.New("invalid aggregate signature on tree head")
+	}
+	return nil
+}
+
+// TransparencyLog 是只追加的签发日志，叶子哈希与内部节点按 RFC 6962 计算，
+// 树的形状与 Tree 相同。
+// A TransparencyLog is an append-only log of LogEntry values. Leaves and
+// nodes are hashed as in HashModeRFC6962 and the tree has the same shape as
+// Tree, so inclusion proofs can be checked with Proof and VerifyCachedProof.
+type TransparencyLog struct {
+	mu       sync.Mutex // hash.Hash 不是并发安全的，读操作同样需要互斥
+	hash     hash.Hash
+	entries  []LogEntry
+	leaves   [][]byte
+	sessions map[uint64]int
+}
+
+// NewTransparencyLog creates an empty log. The provided hash will be used for
+// all hashing operations within the log.
+func NewTransparencyLog(h hash.Hash) *TransparencyLog {
+	return &TransparencyLog{
+		hash:     h,
+		sessions: make(map[uint64]int),
+	}
+}
+
+// Append 验证聚合签名后追加条目，同一会话只能记录一次
+// Append verifies the entry and appends it to the log, returning its index.
+// A session can only be logged once; a second entry for the same session is
+// rejected as a conflicting issuance.
+func (l *TransparencyLog) Append(e LogEntry) (uint64, error) {
+	if err := e.Verify(); err != nil {
+		return 0, err
+	}
+	data, err := e.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i, ok := l.sessions[e.Session]; ok {
+		if bytes.Equal(l.entries[i].Root, e.Root) {
+			return 0, fmt.Errorf("session %d already logged at index %d", e.Session, i)
+		}
+		return 0, fmt.Errorf("conflicting root for session %d already logged at index %d", e.Session, i)
+	}
+	e.Root = append([]byte(nil), e.Root...)
+	e.Signers = append([]bls12381.G1Affine(nil), e.Signers...)
+	l.sessions[e.Session] = len(l.entries)
+	l.entries = append(l.entries, e)
+	l.leaves = append(l.leaves, HashModeRFC6962.LeafSum(l.hash, data))
+	return uint64(len(l.entries) - 1), nil
+}
+
+// Size returns the number of entries in the log.
+func (l *TransparencyLog) Size() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.entries))
+}
+
+// Entry returns the entry at index i.
+func (l *TransparencyLog) Entry(i uint64) (LogEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i >= uint64(len(l.entries)) {
+		return LogEntry{}, fmt.Errorf("index %d out of range for log of size %d", i, len(l.entries))
+	}
+	return l.entries[i], nil
+}
+
+// Lookup returns the index of the entry logged for a session.
+func (l *TransparencyLog) Lookup(session uint64) (uint64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i, ok := l.sessions[session]
+	return uint64(i), ok
+}
+
+// Root returns the Merkle root of the first 'size' entries.
+func (l *TransparencyLog) Root(size uint64) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size == 0 || size > uint64(len(l.leaves)) {
+		return nil, fmt.Errorf("invalid tree size %d for log of size %d", size, len(l.leaves))
+	}
+	return subTreeRoot(l.hash, HashModeRFC6962, l.leaves[:size]), nil
+}
+
+// TreeHead returns the unsigned tree head of the current log.
+func (l *TransparencyLog) TreeHead(timestamp int64) (TreeHead, error) {
+	size := l.Size()
+	root, err := l.Root(size)
+	if err != nil {
+		return TreeHead{}, err
+	}
+	return TreeHead{Size: size, Timestamp: timestamp, Root: root}, nil
+}
+
+// InclusionProof 返回第 index 条记录在大小为 size 的树中的包含证明
+// InclusionProof returns a proof that the entry at 'index' is part of the
+// tree of the first 'size' entries. The leaf of the proof is the encoded
+// entry.
+func (l *TransparencyLog) InclusionProof(index, size uint64) (*Proof, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if size == 0 || size > uint64(len(l.leaves)) || index >= size {
+		return nil, fmt.Errorf("invalid index %d for tree size %d", index, size)
+	}
+	data, err := l.entries[index].MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{
+		Index:     index,
+		NumLeaves: size,
+		Mode:      HashModeRFC6962,
+		Leaf:      data,
+		Path:      subTreePath(l.hash, HashModeRFC6962, l.leaves[:size], index),
+	}, nil
+}
+
+// ConsistencyProof 返回大小为 first 的树是大小为 second 的树前缀的证明（RFC 6962 2.1.2）
+// ConsistencyProof returns the RFC 6962 consistency proof between the trees
+// of the first 'first' and 'second' entries.
+func (l *TransparencyLog) ConsistencyProof(first, second uint64) ([][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if first == 0 || first > second || second > uint64(len(l.leaves)) {
+		return nil, fmt.Errorf("invalid consistency range %d..%d for log of size %d", first, second, len(l.leaves))
+	}
+	return consistencySubProof(l.hash, first, l.leaves[:second], true), nil
+}
+
+func consistencySubProof(h hash.Hash, m uint64, sums [][]byte, complete bool) [][]byte {
+	n := uint64(len(sums))
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{subTreeRoot(h, HashModeRFC6962, sums)}
+	}
+	k := splitPoint(uint64(len(sums)))
+	if m <= k {
+		return append(consistencySubProof(h, m, sums[:k], complete), subTreeRoot(h, HashModeRFC6962, sums[k:]))
+	}
+	return append(consistencySubProof(h, m-k, sums[k:], false), subTreeRoot(h, HashModeRFC6962, sums[:k]))
+}
+
+// VerifyConsistency 验证一致性证明（RFC 9162 2.1.4.2）
+// VerifyConsistency returns true if 'proof' shows that the tree of size
+// 'first' with root 'firstRoot' is a prefix of the tree of size 'second' with
+// root 'secondRoot'.
+func VerifyConsistency(h hash.Hash, first, second uint64, firstRoot, secondRoot []byte, proof [][]byte) bool {
+	if first == 0 || first > second {
+		return false
+	}
+	if first == second {
+		return len(proof) == 0 && bytes.Equal(firstRoot, secondRoot)
+	}
+	if len(proof) == 0 {
+		return false
+	}
+	// 若 first 为 2 的幂，旧树根本身就是证明的第一个节点
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = HashModeRFC6962.NodeSum(h, c, fr)
+			sr = HashModeRFC6962.NodeSum(h, c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = HashModeRFC6962.NodeSum(h, sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(fr, firstRoot) && bytes.Equal(sr, secondRoot)
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	blsfr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+)
+
+func testAuthorities(t *testing.T, n int) ([]blsfr.Element, []bls12381.G1Affine) {
+	_, _, g1Gen, _ := bls12381.Generators()
+	sks := make([]blsfr.Element, n)
+	pks := make([]bls12381.G1Affine, n)
+	for i := range sks {
+		if _, err := sks[i].SetRandom(); err != nil {
+			t.Fatal(err)
+		}
+		pks[i].ScalarMultiplication(&g1Gen, sks[i].BigInt(new(big.Int)))
+	}
+	return sks, pks
+}
+
+func testCoSign(t *testing.T, sks []blsfr.Element, message []byte) []bls12381.G2Affine {
+	sigs := make([]bls12381.G2Affine, len(sks))
+	for i := range sks {
+		sig, err := Sign(sks[i], message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs[i] = sig
+	}
+	return sigs
+}
+
+func TestTransparencyLog(t *testing.T) {
+	h := sha256.New()
+	sks, pks := testAuthorities(t, 3)
+	log := NewTransparencyLog(h)
+
+	roots := make([][]byte, 0)
+	const n = 9
+	for i := uint64(0); i < n; i++ {
+		root := sha256.Sum256([]byte(fmt.Sprintf("credential root %d", i)))
+		_, aggSig := Aggregate(pks, testCoSign(t, sks, root[:]))
+		if _, err := log.Append(LogEntry{Session: i, Root: root[:], Signature: aggSig, Signers: pks}); err != nil {
+			t.Fatal(err)
+		}
+		logRoot, err := log.Root(i + 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, logRoot)
+	}
+
+	// 同一会话的第二次签发应被拒绝
+	other := sha256.Sum256([]byte("conflicting root"))
+	_, aggSig := Aggregate(pks, testCoSign(t, sks, other[:]))
+	if _, err := log.Append(LogEntry{Session: 3, Root: other[:], Signature: aggSig, Signers: pks}); err == nil {
+		t.Fatal("conflicting entry accepted")
+	}
+	if _, err := log.Append(LogEntry{Session: 100, Root: other[:], Signature: aggSig, Signers: pks[:2]}); err == nil {
+		t.Fatal("entry with wrong signer set accepted")
+	}
+
+	for size := uint64(1); size <= n; size++ {
+		for i := uint64(0); i < size; i++ {
+			proof, err := log.InclusionProof(i, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proof.Verify(h, roots[size-1]) {
+				t.Fatalf("inclusion proof %d in tree of size %d rejected", i, size)
+			}
+		}
+		// 审计方从证明中的叶子解码出签发记录
+		var entry LogEntry
+		proof, _ := log.InclusionProof(size-1, size)
+		if err := entry.UnmarshalBinary(proof.Leaf); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Session != size-1 || entry.Verify() != nil {
+			t.Fatalf("entry %d decoded as session %d", size-1, entry.Session)
+		}
+		if err := entry.UnmarshalBinary(proof.Leaf[:len(proof.Leaf)-1]); err == nil {
+			t.Fatal("truncated entry decoded")
+		}
+		for first := uint64(1); first <= size; first++ {
+			proof, err := log.ConsistencyProof(first, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyConsistency(h, first, size, roots[first-1], roots[size-1], proof) {
+				t.Fatalf("consistency proof %d..%d rejected", first, size)
+			}
+			if first < size && VerifyConsistency(h, first, size, roots[size-1], roots[size-1], proof) {
+				t.Fatalf("consistency proof %d..%d accepted with wrong first root", first, size)
+			}
+		}
+	}
+
+	th, err := log.TreeHead(1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sth, err := CoSign(th, pks, testCoSign(t, sks, th.Message()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sth.Verify(pks, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := sth.Verify(pks[:2], 2); err == nil {
+		t.Fatal("tree head with untrusted signer accepted")
+	}
+	sth.Size--
+	if err := sth.Verify(pks, 3); err == nil {
+		t.Fatal("modified tree head accepted")
+	}
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"hash"
+)
+
+// VerifyProof 在电路之外验证 merkle proof，叶子数据会先经过 leafSum。
+// VerifyProof takes a Merkle root, the leaf data, the sibling sums proving the
+// leaf and the position of the leaf, and returns true if the leaf is part of
+// the tree with the given root. proofSet holds the sibling sums ordered from
+// the leaf up to the root, i.e. the output of Prove without its first element.
+// The leaf is hashed with leafSum first, matching trees built with New1 and
+// Push1. False is returned if the root is nil or 'proofIndex' is not smaller
+// than 'numLeaves'.
+func VerifyProof(h hash.Hash, merkleRoot []byte, leaf []byte, proofSet [][]byte, proofIndex uint64, numLeaves uint64) bool {
+	return verifyProof(h, HashModePlain, merkleRoot, leafSum(h, leaf), proofSet, proofIndex, numLeaves)
+}
+
+// VerifyProofMode is VerifyProof for trees whose hash mode was changed with
+// SetHashMode.
+func VerifyProofMode(h hash.Hash, mode HashMode, merkleRoot []byte, leaf []byte, proofSet [][]byte, proofIndex uint64, numLeaves uint64) bool {
+	return verifyProof(h, mode, merkleRoot, mode.LeafSum(h, leaf), proofSet, proofIndex, numLeaves)
+}
+
+// VerifyCachedProof 与 VerifyProof 相同，但叶子已经是叶子哈希（对应 New 创建的树）。
+// VerifyCachedProof is VerifyProof for cached trees built with New, where the
+// data pushed for each leaf is already the leaf sum and is not hashed again.
+func VerifyCachedProof(h hash.Hash, merkleRoot []byte, leafHash []byte, proofSet [][]byte, proofIndex uint64, numLeaves uint64) bool {
+	return verifyProof(h, HashModePlain, merkleRoot, leafHash, proofSet, proofIndex, numLeaves)
+}
+
+func verifyProof(h hash.Hash, mode HashMode, merkleRoot []byte, sum []byte, proofSet [][]byte, proofIndex uint64, numLeaves uint64) bool {
+	if merkleRoot == nil {
+		return false
+	}
+	if proofIndex >= numLeaves {
+		return false
+	}
+
+	// In a Merkle tree, every node except the root node has a sibling.
+	// Combining the two siblings in the correct order will create the parent
+	// node. Each hash in the proof set is the sibling of the node above the
+	// previous sibling, starting with the sibling of the leaf. proofSet[i] is
+	// the sibling at height i.
+	height := 0
+
+	// While the current subtree (of height 'height' + 1) is complete,
+	// determine the position of the next sibling using the complete subtree
+	// algorithm. 'stableEnd' tells us the ending index of the last full
+	// subtree. It gets initialized to 'proofIndex' because the first full
+	// subtree is the leaf itself.
+	stableEnd := proofIndex
+	for {
+		// Determine if the subtree is complete. This is accomplished by
+		// rounding down the proofIndex to the nearest 1 << (height+1), adding
+		// 1 << (height+1), and comparing the result to the number of leaves
+		// in the Merkle tree.
+		size := uint64(1) << uint(height+1)
+		subTreeStartIndex := (proofIndex / size) * size
+		subTreeEndIndex := subTreeStartIndex + size - 1
+		if subTreeEndIndex >= numLeaves {
+			// If the Merkle tree does not have a leaf at index
+			// 'subTreeEndIndex', then the subtree of the current height is not
+			// a complete subtree.
+			break
+		}
+		stableEnd = subTreeEndIndex
+
+		// Determine if the proofIndex is in the first or the second half of
+		// the subtree.
+		if len(proofSet) <= height {
+			return false
+		}
+		if proofIndex-subTreeStartIndex < size/2 {
+			sum = mode.NodeSum(h, sum, proofSet[height])
+		} else {
+			sum = mode.NodeSum(h, proofSet[height], sum)
+		}
+		height++
+	}
+
+	// Determine if the next hash belongs to an orphan that was elevated. This
+	// is the case IFF 'stableEnd' (the last index of the largest full subtree)
+	// is not equal to the last index of the Merkle tree. All smaller subtrees
+	// were collapsed into a single right sibling.
+	if stableEnd != numLeaves-1 {
+		if len(proofSet) <= height {
+			return false
+		}
+		sum = mode.NodeSum(h, sum, proofSet[height])
+		height++
+	}
+
+	// All remaining elements in the proof set will belong to a left sibling.
+	for height < len(proofSet) {
+		sum = mode.NodeSum(h, proofSet[height], sum)
+		height++
+	}
+
+	return bytes.Equal(sum, merkleRoot)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// MerkleWitness 是 ValidCircuit 中由 merkle 证明决定的部分：叶子、按最大深度补齐的路径与方向位，以及实际深度
+// A MerkleWitness is the part of a ValidCircuit assignment taken from a tree
+// proof: the leaf, the path and helper bits padded with zeros to the
+// circuit's maximum depth, and the actual depth of the proof.
+type MerkleWitness struct {
+	Leaf   frontend.Variable
+	Path   []frontend.Variable
+	Helper []frontend.Variable
+	Depth  int
+}
+
+// MerkleWitness 将 ct.NewTree 生成的证明转换为最大深度为 maxDepth 的电路输入。
+// 方向位由叶子数与叶子位置决定，最后一个叶子落单（叶子数不是 2 的幂）时路径比其他叶子短。
+// MerkleWitness converts a proof of the tree built by NewTree, as returned
+// by Tree.Prove, into the inputs of the type's ValidCircuit of depth
+// 'maxDepth'. The helper bits follow the shape of a tree of 'numLeaves'
+// leaves, so proofs of any leaf in any tree up to that depth are accepted.
+func (c CredentialType) MerkleWitness(proofSet [][]byte, proofIndex, numLeaves uint64, maxDepth int) (*MerkleWitness, error) {
+	if len(proofSet) == 0 {
+		return nil, errors.New("empty merkle proof")
+	}
+	if proofIndex >= numLeaves {
+		return nil, fmt.Errorf("leaf index %d out of range for %d leaves", proofIndex, numLeaves)
+	}
+	helper := proofHelper(numLeaves, proofIndex)
+	depth := len(proofSet) - 1
+	if len(helper) != depth {
+		return nil, fmt.Errorf("proof of depth %d does not match a tree of %d leaves", depth, numLeaves)
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("proof depth %d exceeds the maximum depth %d", depth, maxDepth)
+	}
+	// 电路的 Leaf 为叶子数据，叶子哈希在电路内计算
+	w := &MerkleWitness{
+		Leaf:   BytesToVariable(proofSet[0]),
+		Path:   make([]frontend.Variable, maxDepth),
+		Helper: make([]frontend.Variable, maxDepth),
+		Depth:  depth,
+	}
+	for i := 0; i < maxDepth; i++ {
+		w.Path[i], w.Helper[i] = 0, 0
+		if i < depth {
+			w.Path[i], w.Helper[i] = BytesToVariable(proofSet[i+1]), helper[i]
+		}
+	}
+	return w, nil
+}
+
+// Circuit 返回 root 与 message 对应的 ValidCircuit 赋值
+// Circuit returns the ValidCircuit assignment proving the witness against
+// 'root'.
+func (w *MerkleWitness) Circuit(root, message []byte) ValidCircuit {
+	return ValidCircuit{
+		MerkleRoot: BytesToVariable(root),
+		Message:    BytesToVariable(message),
+		Leaf:       w.Leaf,
+		Path:       w.Path,
+		Helper:     w.Helper,
+		Depth:      w.Depth,
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// 同一个最大深度的电路接受任意叶子数、任意位置（包括落单叶子）的证明
+func TestMerkleWitness(t *testing.T) {
+	const maxDepth = 4
+	for _, mode := range []HashMode{HashModePlain, HashModeRFC6962} {
+		ct := CredentialType{Name: "test", Mode: mode}
+		circuit := ct.Circuit(maxDepth)
+		for n := 1; n <= 9; n++ {
+			for index := 0; index < n; index++ {
+				tree, err := ct.NewTree()
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = tree.SetIndex(uint64(index))
+				for _, d := range testLeaves(n) {
+					tree.Push(d)
+				}
+				root, proofSet, proofIndex, numLeaves := tree.Prove()
+				w, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assignment := w.Circuit(root, nil)
+				if err := test.IsSolved(&circuit, &assignment, ecc.BN254.ScalarField()); err != nil {
+					t.Fatalf("%v n=%d: proof of leaf %d rejected: %v", mode, n, index, err)
+				}
+				if w.Depth == 0 {
+					continue
+				}
+
+				forged := w.Circuit(root, nil)
+				forged.Depth = w.Depth - 1
+				if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+					t.Fatalf("%v n=%d leaf %d: shorter depth accepted", mode, n, index)
+				}
+				forged = w.Circuit(root, nil)
+				forged.Helper = append([]frontend.Variable(nil), w.Helper...)
+				forged.Helper[0] = 1 - w.Helper[0].(uint8)
+				if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+					t.Fatalf("%v n=%d leaf %d: flipped helper accepted", mode, n, index)
+				}
+			}
+		}
+	}
+
+	ct := CredentialType{Name: "test", Mode: HashModeRFC6962}
+	circuit := ct.Circuit(maxDepth)
+	tree, _ := ct.NewTree()
+	_ = tree.SetIndex(2)
+	for _, d := range testLeaves(5) {
+		tree.Push(d)
+	}
+	root, proofSet, proofIndex, numLeaves := tree.Prove()
+	w, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, maxDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 补齐部分必须为 0，深度不能超过最大深度
+	forged := w.Circuit(root, nil)
+	forged.Path = append([]frontend.Variable(nil), w.Path...)
+	forged.Path[maxDepth-1] = 1
+	if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("non-zero padding accepted")
+	}
+	forged = w.Circuit(root, nil)
+	forged.Depth = maxDepth + 1
+	if err := test.IsSolved(&circuit, &forged, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("depth above the maximum accepted")
+	}
+
+	if _, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, w.Depth-1); err == nil {
+		t.Fatal("witness built above the maximum depth")
+	}
+	if _, err := ct.MerkleWitness(proofSet, proofIndex, 9, maxDepth); err == nil {
+		t.Fatal("witness built for another tree shape")
+	}
+	if _, err := ct.MerkleWitness(proofSet, numLeaves, numLeaves, maxDepth); err == nil {
+		t.Fatal("witness built for a leaf out of range")
+	}
+}
+
+// Prove 接受深度小于设置深度的证明
+func TestProveMaxDepth(t *testing.T) {
+	ct := CredentialType{Name: "test", Mode: HashModeDomainTag}
+	setup, err := Setup(ct, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sks, pks := testAuthorities(t, 2)
+	for _, n := range []int{3, 8} {
+		tree, _ := ct.NewTree()
+		_ = tree.SetIndex(uint64(n - 1))
+		data := testLeaves(n)
+		for _, d := range data {
+			tree.Push(d)
+		}
+		root, proofSet, proofIndex, numLeaves := tree.Prove()
+		aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+		proof, public, err := Prove(setup, aggPK, aggSig, data[n-1], proofSet, proofIndex, numLeaves, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(setup.VerifyingKey(), proof, public, pks); err != nil {
+			t.Fatalf("n=%d: proof of the last leaf rejected: %v", n, err)
+		}
+	}
+}
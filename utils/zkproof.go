@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark"
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/emulated/sw_bls12381"
+)
+
+// =============================
+// 第三部分：证明生成与验证
+// =============================
+
+// PublicInputs 是验证方验证证明所需的全部公开数据
+// 签名在电路内验证时 AggSig 为空，验证方只需聚合公钥；证明输出 nullifier 时 Nullifier 非空。
+// PublicInputs are the public data a relying party needs, next to the
+// verifying key, to check a ZKProof: the credential root and the aggregate
+// signature of the authorities over it. AggSig is nil when the signature was
+// verified in-circuit by a SignedCircuit. Nullifier is set for proofs made
+// by ProveNullifier.
+type PublicInputs struct {
+	MerkleRoot []byte             `json:"merkle_root"`
+	AggPK      bls12381.G1Affine  `json:"agg_pk"`
+	AggSig     *bls12381.G2Affine `json:"agg_sig,omitempty"`
+	Nullifier  *NullifierInputs   `json:"nullifier,omitempty"`
+}
+
+// Witness returns the public witness of the circuit the inputs are for:
+// a SignedCircuit when AggSig is nil, a ValidCircuit otherwise, or their
+// NullifierCircuit counterparts when Nullifier is set.
+func (p *PublicInputs) Witness(curve ecc.ID) (witness.Witness, error) {
+	return frontend.NewWitness(p.assignment(), curve.ScalarField(), frontend.PublicOnly())
+}
+
+// assignment 返回只含公开部分的电路赋值
+func (p *PublicInputs) assignment() frontend.Circuit {
+	valid := ValidCircuit{
+		MerkleRoot: BytesToVariable(p.MerkleRoot),
+	}
+	signature := AggregateSignature{AggPK: sw_bls12381.NewG1Affine(p.AggPK)}
+	if p.Nullifier != nil {
+		n := NullifierCircuit{
+			ValidCircuit: valid,
+			Domain:       BytesToVariable(p.Nullifier.Domain),
+			Nonce:        BytesToVariable(p.Nullifier.Nonce),
+			Nullifier:    BytesToVariable(p.Nullifier.Nullifier),
+		}
+		if p.AggSig != nil {
+			return &n
+		}
+		return &SignedNullifierCircuit{NullifierCircuit: n, Signature: signature}
+	}
+	if p.AggSig != nil {
+		return &valid
+	}
+	return &SignedCircuit{ValidCircuit: valid, Signature: signature}
+}
+
+// ZKProof 是可序列化的证明，记录生成它的后端与曲线，验证方据此选择后端
+// A ZKProof is a proof of a credential circuit together with the backend and
+// curve it was made with. Verifiers pick the backend from the proof.
+type ZKProof struct {
+	Backend Backend
+	Curve   ecc.ID
+	Proof   Artifact
+}
+
+// MarshalBinary encodes the proof as uvarint(backend) || uvarint(curve) ||
+// proof.
+func (p *ZKProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(binary.AppendUvarint(nil, uint64(p.Backend)))
+	buf.Write(binary.AppendUvarint(nil, uint64(p.Curve)))
+	if _, err := p.Proof.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a proof encoded by MarshalBinary.
+func (p *ZKProof) UnmarshalBinary(data []byte) error {
+	r := byteReader{data: data}
+	bid := r.uvarint()
+	id := r.uvarint()
+	if r.err != nil {
+		return r.err
+	}
+	b := Backend(bid)
+	if bid > uint64(^uint8(0)) || !b.Valid() {
+		return fmt.Errorf("unknown backend id %d", bid)
+	}
+	curve := ecc.ID(id)
+	if id > uint64(^uint16(0)) || !implementedCurve(curve) {
+		return fmt.Errorf("unknown curve id %d", id)
+	}
+	proof := b.newProof(curve)
+	if _, err := proof.ReadFrom(bytes.NewReader(r.data)); err != nil {
+		return fmt.Errorf("invalid %v proof: %v", b, err)
+	}
+	p.Backend, p.Curve, p.Proof = b, curve, proof
+	return nil
+}
+
+func implementedCurve(id ecc.ID) bool {
+	for _, c := range gnark.Curves() {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Prove 证明 proofSet 中的叶子属于 root 对应的凭证，并返回证明与公开输入。
+// 证明深度可以小于设置的最大深度，电路输入由 MerkleWitness 补齐。
+// 聚合签名在生成证明前验证，失败时返回错误；凭证类型要求时签名同时在电路内验证。
+// Prove proves with the loaded setup that the leaf proofSet[0] is part of the
+// credential tree of 'numLeaves' leaves with root 'root', as returned by
+// Tree.Prove, up to the maximum depth of the setup, after checking
+// the aggregate signature of the authorities over the root. When the setup
+// is for a signed credential type, the signature is also proven in-circuit
+// and left out of the public inputs. The returned proof and public inputs are
+// all a verifier needs besides the verifying key. Holder bound credential
+// types are proven with ProveHolder, or ProveNullifier when they output
+// nullifiers.
+func Prove(setup *SetupArtifacts, aggPK bls12381.G1Affine, aggSig bls12381.G2Affine, attr []byte, proofSet [][]byte, proofIndex, numLeaves uint64, root []byte) (*ZKProof, *PublicInputs, error) {
+	return prove(setup, aggPK, aggSig, attr, nil, nil, proofSet, proofIndex, numLeaves, root)
+}
+
+// ProveHolder 与 Prove 相同，但用于绑定持有者的凭证类型：叶子 proofSet[0] 必须是
+// HolderLeaf(secret, attributes)，电路同时证明持有者知道其中的秘密
+// ProveHolder is Prove for holder bound credential types. The leaf
+// proofSet[0] must be HolderLeaf(secret, attributes) and the circuit also
+// proves knowledge of the secret, so a copied leaf and path are useless.
+func ProveHolder(setup *SetupArtifacts, aggPK bls12381.G1Affine, aggSig bls12381.G2Affine, secret []byte, attributes [][]byte, proofSet [][]byte, proofIndex, numLeaves uint64, root []byte) (*ZKProof, *PublicInputs, error) {
+	if len(proofSet) == 0 {
+		return nil, nil, errors.New("empty merkle proof")
+	}
+	return prove(setup, aggPK, aggSig, proofSet[0], append([][]byte{secret}, attributes...), nil, proofSet, proofIndex, numLeaves, root)
+}
+
+// ProveNullifier 与 ProveHolder 相同，但用于输出 nullifier 的凭证类型：证明回应验证方的挑战 s，
+// 公开输入中的 nullifier 在 s.Domain 内对该持有者固定
+// ProveNullifier is ProveHolder for credential types that output
+// nullifiers. The proof answers the verifier's statement 's' and its public
+// inputs carry the holder's nullifier in s.Domain, which the verifier checks
+// against its challenge and records with VerifyNullifier.
+func ProveNullifier(setup *SetupArtifacts, aggPK bls12381.G1Affine, aggSig bls12381.G2Affine, s NullifierStatement, secret []byte, attributes [][]byte, proofSet [][]byte, proofIndex, numLeaves uint64, root []byte) (*ZKProof, *PublicInputs, error) {
+	if len(proofSet) == 0 {
+		return nil, nil, errors.New("empty merkle proof")
+	}
+	return prove(setup, aggPK, aggSig, proofSet[0], append([][]byte{secret}, attributes...), &s, proofSet, proofIndex, numLeaves, root)
+}
+
+// prove 生成证明，holder 为持有者秘密与属性，不绑定持有者时为 nil；statement 为验证方的挑战，不输出 nullifier 时为 nil
+func prove(setup *SetupArtifacts, aggPK bls12381.G1Affine, aggSig bls12381.G2Affine, attr []byte, holder [][]byte, statement *NullifierStatement, proofSet [][]byte, proofIndex, numLeaves uint64, root []byte) (*ZKProof, *PublicInputs, error) {
+	if setup == nil {
+		return nil, nil, errNoSetup
+	}
+	if !VerifyAggregateSignature(aggPK, aggSig, root) {
+		return nil, nil, errors.New("invalid aggregate signature over the merkle root")
+	}
+	ct, err := setup.Manifest.CredentialType()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ct.Holder != (holder != nil) || ct.Nullifier != (statement != nil) {
+		return nil, nil, fmt.Errorf("credential type %q: holder binding is %v and nullifier is %v, use Prove, ProveHolder or ProveNullifier accordingly", ct.Name, ct.Holder, ct.Nullifier)
+	}
+	if holder != nil {
+		if len(holder) != 1+ct.Attributes {
+			return nil, nil, fmt.Errorf("credential type %q binds %d attributes, got %d", ct.Name, ct.Attributes, len(holder)-1)
+		}
+		leaf, err := HolderLeaf(ct.Profile(), holder[0], holder[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !bytes.Equal(leaf, proofSet[0]) {
+			return nil, nil, errors.New("secret and attributes do not match the credential leaf")
+		}
+	}
+	curve, err := setup.Manifest.CurveID()
+	if err != nil {
+		return nil, nil, err
+	}
+	mw, err := ct.MerkleWitness(proofSet, proofIndex, numLeaves, setup.Manifest.Depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	valid := mw.Circuit(root, attr)
+	if holder != nil {
+		valid.Holder = BytesArrayToVariables(holder)
+	}
+	public := &PublicInputs{
+		MerkleRoot: append([]byte(nil), root...),
+		AggPK:      aggPK,
+	}
+	var assignment frontend.Circuit = &valid
+	if statement != nil {
+		if err := statement.Validate(ct.Profile()); err != nil {
+			return nil, nil, err
+		}
+		nullifier, err := Nullifier(ct.Profile(), holder[0], statement.Domain)
+		if err != nil {
+			return nil, nil, err
+		}
+		public.Nullifier = &NullifierInputs{
+			Domain:    append([]byte(nil), statement.Domain...),
+			Nonce:     append([]byte(nil), statement.Nonce...),
+			Nullifier: nullifier,
+		}
+		n := NullifierCircuit{
+			ValidCircuit: valid,
+			Domain:       BytesToVariable(statement.Domain),
+			Nonce:        BytesToVariable(statement.Nonce),
+			Nullifier:    BytesToVariable(nullifier),
+		}
+		assignment = &n
+		if ct.Signature {
+			assignment = &SignedNullifierCircuit{NullifierCircuit: n, Signature: NewAggregateSignature(aggPK, aggSig)}
+		}
+	} else if ct.Signature {
+		assignment = &SignedCircuit{
+			ValidCircuit: valid,
+			Signature:    NewAggregateSignature(aggPK, aggSig),
+		}
+	}
+	if !ct.Signature {
+		public.AggSig = &aggSig
+	}
+	w, err := frontend.NewWitness(assignment, curve.ScalarField())
+	if err != nil {
+		return nil, nil, fmt.Errorf("build witness: %v", err)
+	}
+	b, err := setup.Manifest.BackendID()
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := b.prove(setup.CCS, setup.PK, w)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v prove: %v", b, err)
+	}
+	return &ZKProof{Backend: b, Curve: curve, Proof: proof}, public, nil
+}
+
+// Verify 只使用公开数据验证证明：聚合公钥必须来自受信任的机构，机构的聚合签名（若未在电路内验证）
+// 以及证明本身，后端由证明决定
+// Verify checks a proof made by Prove using only public data: the verifying
+// key loaded with LoadVerifyingKey, the proof and its public inputs. The
+// aggregate public key of the inputs must be the aggregate of 'authorities',
+// the keys the verifier trusts, whether the signature is checked natively or
+// in-circuit. The backend is the one recorded in the proof and must match
+// the key.
+func Verify(vk *VerifyingKey, proof *ZKProof, public *PublicInputs, authorities []bls12381.G1Affine) error {
+	if vk == nil || vk.Key == nil || proof == nil || proof.Proof == nil || public == nil {
+		return errors.New("missing verifying key, proof or public inputs")
+	}
+	if vk.Backend != proof.Backend {
+		return fmt.Errorf("%v proof does not match %v verifying key", proof.Backend, vk.Backend)
+	}
+	if vk.Curve != proof.Curve {
+		return fmt.Errorf("proof on %s does not match verifying key on %s", proof.Curve, vk.Curve)
+	}
+	if err := checkAuthorities(public.AggPK, authorities); err != nil {
+		return err
+	}
+	if public.AggSig != nil && !VerifyAggregateSignature(public.AggPK, *public.AggSig, public.MerkleRoot) {
+		return errors.New("invalid aggregate signature over the merkle root")
+	}
+	w, err := public.Witness(proof.Curve)
+	if err != nil {
+		return fmt.Errorf("build public witness: %v", err)
+	}
+	if err := proof.Backend.verify(proof.Proof, vk.Key, w); err != nil {
+		return fmt.Errorf("%v verify: %v", proof.Backend, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProveVerify(t *testing.T) {
+	for _, curve := range SupportedCurves {
+		for _, b := range []Backend{BackendGroth16, BackendPlonk} {
+			testProveVerify(t, b, CredentialType{Name: "test", Curve: curve})
+		}
+	}
+}
+
+func testProveVerify(t *testing.T, b Backend, ct CredentialType) {
+	setup, err := setupBackend(b, ct, 2, UnsafeSRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := ct.NewTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.SetIndex(2)
+	data := testLeaves(4)
+	for _, d := range data {
+		tree.Push(d)
+	}
+	root, proofSet, proofIndex, numLeaves := tree.Prove()
+
+	sks, pks := testAuthorities(t, 3)
+	aggPK, aggSig := Aggregate(pks, testCoSign(t, sks, root))
+	proof, public, err := Prove(setup, aggPK, aggSig, data[2], proofSet, proofIndex, numLeaves, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 验证方只拿到序列化后的证明
+	encoded, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ZKProof
+	if err := decoded.UnmarshalBinary(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(setup.VerifyingKey(), &decoded, public, pks); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+
+	forged := *public
+	forged.MerkleRoot = HashModePlain.LeafSum(ct.Profile().NewHash(), root)
+	// 即使机构确实签署了另一个 root，证明也不能用于该 root
+	_, forgedSig := Aggregate(pks, testCoSign(t, sks, forged.MerkleRoot))
+	forged.AggSig = &forgedSig
+	if err := Verify(setup.VerifyingKey(), &decoded, &forged, pks); err == nil {
+		t.Fatal("proof accepted for another root")
+	}
+	// 其他机构用自己的密钥签署同一个 root，证明与签名本身有效，但聚合公钥不受信任
+	foreignSks, foreignPks := testAuthorities(t, 3)
+	foreignPK, foreignSig := Aggregate(foreignPks, testCoSign(t, foreignSks, root))
+	foreignProof, foreignPublic, err := Prove(setup, foreignPK, foreignSig, data[2], proofSet, proofIndex, numLeaves, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(setup.VerifyingKey(), foreignProof, foreignPublic, foreignPks); err != nil {
+		t.Fatalf("proof rejected with its own authorities: %v", err)
+	}
+	if err := Verify(setup.VerifyingKey(), foreignProof, foreignPublic, pks); err == nil {
+		t.Fatal("proof accepted from untrusted authorities")
+	}
+	if err := Verify(setup.VerifyingKey(), &decoded, public, pks[:2]); err == nil {
+		t.Fatal("proof accepted with part of the trusted authorities")
+	}
+	if err := Verify(setup.VerifyingKey(), &decoded, public, nil); err == nil {
+		t.Fatal("proof accepted without trusted authorities")
+	}
+	if _, _, err := Prove(setup, pks[0], aggSig, data[2], proofSet, proofIndex, numLeaves, root); err == nil {
+		t.Fatal("proof generated with a wrong aggregate public key")
+	}
+	if _, _, err := Prove(setup, aggPK, aggSig, data[2], proofSet[:2], proofIndex, numLeaves, root); err == nil {
+		t.Fatal("proof generated with a wrong depth")
+	}
+	// 后端由证明决定，与验证密钥不一致时拒绝
+	other := *setup.VerifyingKey()
+	other.Backend = BackendPlonk - b
+	if err := Verify(&other, &decoded, public, pks); err == nil {
+		t.Fatal("proof accepted with a verifying key of another backend")
+	}
+	if err := decoded.UnmarshalBinary([]byte{0xff, 0x01}); err == nil {
+		t.Fatal("invalid proof decoded")
+	}
+}
+
+func TestAuthoritiesFile(t *testing.T) {
+	_, pks := testAuthorities(t, 3)
+	path := filepath.Join(t.TempDir(), "authorities.json")
+	if err := SaveAuthorities(path, pks); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadAuthorities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggPK, _ := Aggregate(pks, nil)
+	if len(loaded) != len(pks) || checkAuthorities(aggPK, loaded) != nil {
+		t.Fatal("authorities changed in round trip")
+	}
+	if err := os.WriteFile(path, []byte(`["00"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAuthorities(path); err == nil {
+		t.Fatal("invalid authority key loaded")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"DID/utils"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// verify 使用可信设置的验证密钥验证 user 命令写入的证明信封（JSON 或 CBOR），
+// 信封记录的电路与验证密钥摘要必须与产物目录一致。
+// 指定 -registry 时从电路注册表中按信封记录的电路版本选择验证密钥。
+// 证明中的聚合公钥必须是 -authorities 中受信任机构公钥之和。
+// 证明输出 nullifier 时，必须回应 -domain 域内的挑战 -nonce（由 -new-nonce 生成），
+// 其 nullifier 记录在 -nullifiers 中，同一凭证在同一域内只能使用一次。
+func main() {
+	setupDir := flag.String("setup", "setup-artifacts", "setup 命令生成的产物目录")
+	registryDir := flag.String("registry", "", "电路注册表目录，每个子目录为一组 setup 产物，指定时忽略 -setup")
+	envelopePath := flag.String("envelope", "proof.json", "user 命令写入的证明信封")
+	authoritiesPath := flag.String("authorities", "authorities.json", "受信任机构的公钥列表（SaveAuthorities 格式）")
+	domainName := flag.String("domain", "", "nullifier 的域名称（如 vote-2026），证明输出 nullifier 时必需")
+	nonceHex := flag.String("nonce", "", "发给持有者的挑战（十六进制），证明输出 nullifier 时必需")
+	nullifiersPath := flag.String("nullifiers", "nullifiers.json", "已接受的 nullifier 记录，用于检测重复使用")
+	newNonce := flag.Bool("new-nonce", false, "为 -setup 的电路生成新的挑战并退出")
+	flag.Parse()
+
+	if *newNonce {
+		m, _, err := utils.LoadVerifyingKey(*setupDir)
+		if err != nil {
+			log.Fatalf("[Verify] 无法加载验证密钥: %v", err)
+		}
+		ct, err := m.CredentialType()
+		if err != nil {
+			log.Fatalf("[Verify] %v", err)
+		}
+		nonce, err := utils.NewNonce(ct.Profile())
+		if err != nil {
+			log.Fatalf("[Verify] 无法生成挑战: %v", err)
+		}
+		fmt.Printf("%x\n", nonce)
+		return
+	}
+
+	authorities, err := utils.LoadAuthorities(*authoritiesPath)
+	if err != nil {
+		log.Fatalf("[Verify] 无法加载受信任机构公钥: %v", err)
+	}
+
+	data, err := os.ReadFile(*envelopePath)
+	if err != nil {
+		log.Fatalf("[Verify] 无法读取证明信封: %v", err)
+	}
+	envelope, err := utils.DecodeEnvelope(data)
+	if err != nil {
+		log.Fatalf("[Verify] 无法解析证明信封: %v", err)
+	}
+	if *registryDir != "" {
+		registry, err := utils.LoadRegistry(*registryDir)
+		if err != nil {
+			log.Fatalf("[Verify] 无法加载电路注册表: %v", err)
+		}
+		if err := registry.Verify(envelope, authorities); err != nil {
+			log.Fatalf("[Verify] 证明验证失败: %v", err)
+		}
+	} else {
+		m, vk, err := utils.LoadVerifyingKey(*setupDir)
+		if err != nil {
+			log.Fatalf("[Verify] 无法加载验证密钥: %v", err)
+		}
+		if envelope.Key() != m.Key() {
+			log.Fatalf("[Verify] 证明针对电路 %s，产物目录为 %s", envelope.Key(), m.Key())
+		}
+		if err := utils.VerifyEnvelope(vk, envelope, authorities); err != nil {
+			log.Fatalf("[Verify] 证明验证失败: %v", err)
+		}
+	}
+	if envelope.Nullifier != nil || *domainName != "" {
+		if err := acceptNullifier(envelope, *domainName, *nonceHex, *nullifiersPath); err != nil {
+			log.Fatalf("[Verify] nullifier 检查失败: %v", err)
+		}
+	}
+	for _, in := range envelope.Public {
+		fmt.Printf("%s: 0x%x\n", in.Name, in.Value)
+	}
+	fmt.Printf("ZK Proof verified for circuit %s\n", envelope.Key())
+}
+
+// acceptNullifier 检查已验证的证明回应了本次挑战，并记录其 nullifier
+func acceptNullifier(envelope *utils.Envelope, domainName, nonceHex, nullifiersPath string) error {
+	if domainName == "" || nonceHex == "" {
+		return errors.New("证明输出 nullifier，需要通过 -domain 与 -nonce 指定域与挑战")
+	}
+	nonce, err := hex.DecodeString(nonceHex)
+	if err != nil {
+		return fmt.Errorf("无法解析挑战: %v", err)
+	}
+	proof, err := envelope.ZKProof()
+	if err != nil {
+		return err
+	}
+	public, err := envelope.PublicInputs()
+	if err != nil {
+		return err
+	}
+	used, err := utils.LoadNullifierSet(nullifiersPath)
+	if err != nil {
+		return err
+	}
+	statement := utils.NullifierStatement{Domain: utils.NullifierDomain(utils.Profile{Curve: proof.Curve}, domainName), Nonce: nonce}
+	if err := statement.Accept(public, used); err != nil {
+		return err
+	}
+	return used.Save(nullifiersPath)
+}